@@ -19,11 +19,16 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"nuts-foundation/nuts-monitor/client"
 	"nuts-foundation/nuts-monitor/client/diagnostics"
 	"nuts-foundation/nuts-monitor/config"
 	"nuts-foundation/nuts-monitor/data"
+	"nuts-foundation/nuts-monitor/graph"
 	"sort"
 	"time"
 )
@@ -36,13 +41,18 @@ const (
 )
 
 type Wrapper struct {
-	Config    config.Config
-	Client    client.HTTPClient
-	DataStore *data.Store
+	// Config holds the current config of the monitor
+	Config *config.Watcher
+	// Nodes contains the monitored nodes, the first node is used when a request doesn't specify a node
+	Nodes []Node
 }
 
-func (w Wrapper) Diagnostics(ctx context.Context, _ DiagnosticsRequestObject) (DiagnosticsResponseObject, error) {
-	diagnostics, err := w.Client.Diagnostics(ctx)
+func (w Wrapper) Diagnostics(ctx context.Context, request DiagnosticsRequestObject) (DiagnosticsResponseObject, error) {
+	node, err := w.node(request.Params.Node)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics, err := node.Client.Diagnostics(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -51,47 +61,84 @@ func (w Wrapper) Diagnostics(ctx context.Context, _ DiagnosticsRequestObject) (D
 }
 
 func (w Wrapper) CheckHealth(ctx context.Context, _ CheckHealthRequestObject) (CheckHealthResponseObject, error) {
-	upResponse := CheckHealth200JSONResponse{
-		Status: UP,
-	}
-	downResponse := CheckHealth503JSONResponse{
-		Status: DOWN,
-	}
+	details := map[string]diagnostics.HealthCheckResult{}
+	up := true
 
-	if w.Config.NutsNodeAddr != "" {
-		h, err := w.Client.CheckHealth(ctx)
-		if err != nil {
-			var errString interface{} = err.Error()
-			downResponse.Details = map[string]diagnostics.HealthCheckResult{
-				"node": {
-					Details: &errString,
-					Status:  "UNKNOWN",
-				},
-			}
-			return downResponse, nil
+	for _, node := range w.Nodes {
+		if node.Client.CurrentConfig().NutsNodeAddr == "" {
+			continue
 		}
-		if h.Status != UP {
-			downResponse.Details = map[string]diagnostics.HealthCheckResult{
-				"node": {
-					Status: h.Status,
-				},
-			}
-			return downResponse, nil
+		result := nodeHealth(ctx, node)
+		details[w.healthKey("node", node)] = result
+		if result.Status != UP {
+			up = false
+			continue
+		}
+		if node.Consistency != nil {
+			details[w.healthKey("consistency", node)] = consistencyHealth(node.Consistency)
 		}
+	}
 
-		upResponse.Details = map[string]diagnostics.HealthCheckResult{
-			"node": {
-				Status: h.Status,
-			},
+	if !up {
+		return CheckHealth503JSONResponse{Status: DOWN, Details: details}, nil
+	}
+	return CheckHealth200JSONResponse{Status: UP, Details: details}, nil
+}
+
+// healthKey returns the key of a health check result. The node name is only added when multiple nodes are monitored.
+func (w Wrapper) healthKey(check string, node Node) string {
+	if len(w.Nodes) == 1 {
+		return check
+	}
+	return check + "." + node.Name
+}
+
+// nodeHealth checks the health of a single node
+func nodeHealth(ctx context.Context, node Node) diagnostics.HealthCheckResult {
+	h, err := node.Client.CheckHealth(ctx)
+	if err != nil {
+		var errString interface{} = err.Error()
+		return diagnostics.HealthCheckResult{
+			Details: &errString,
+			Status:  "UNKNOWN",
 		}
+	}
+	return diagnostics.HealthCheckResult{
+		Status: h.Status,
+	}
+}
 
+// consistencyHealth converts the last consistency report to a health check result.
+// Diverged peers do not affect the overall status, since they indicate a problem elsewhere in the network.
+func consistencyHealth(consistency *data.ConsistencyChecker) diagnostics.HealthCheckResult {
+	report := consistency.Report()
+	if report.Timestamp.IsZero() {
+		return diagnostics.HealthCheckResult{Status: "UNKNOWN"}
+	}
+	if len(report.Diverged) > 0 {
+		var details interface{} = report.Diverged
+		return diagnostics.HealthCheckResult{Status: DOWN, Details: &details}
 	}
-	return upResponse, nil
+	return diagnostics.HealthCheckResult{Status: UP}
 }
 
-func (w Wrapper) NetworkTopology(ctx context.Context, _ NetworkTopologyRequestObject) (NetworkTopologyResponseObject, error) {
+func (w Wrapper) NetworkTopology(ctx context.Context, request NetworkTopologyRequestObject) (NetworkTopologyResponseObject, error) {
+	format := "json"
+	if request.Params.Format != nil {
+		format = *request.Params.Format
+	}
+	switch format {
+	case "json", "dot", "graphml", "jgf":
+	default:
+		return NetworkTopology400TextResponse(fmt.Sprintf("unsupported format: %s", format)), nil
+	}
+	node, err := w.node(request.Params.Node)
+	if err != nil {
+		return nil, err
+	}
+
 	ts := client.TopologyService{
-		HTTPClient: w.Client,
+		HTTPClient: node.Client,
 	}
 
 	networkTopology, err := ts.NetworkTopology(ctx)
@@ -99,12 +146,120 @@ func (w Wrapper) NetworkTopology(ctx context.Context, _ NetworkTopologyRequestOb
 		return nil, err
 	}
 
+	switch format {
+	case "dot":
+		return NetworkTopology200TextResponse(networkTopology.DOT()), nil
+	case "graphml":
+		data, err := networkTopology.GraphML()
+		if err != nil {
+			return nil, err
+		}
+		return NetworkTopology200ApplicationGraphmlXmlResponse{Body: bytes.NewReader(data), ContentLength: int64(len(data))}, nil
+	case "jgf":
+		return NetworkTopology200ApplicationVndJgfJSONResponse(networkTopology.JSONGraph()), nil
+	}
 	return NetworkTopology200JSONResponse(networkTopology), nil
 }
 
-func (w Wrapper) AggregatedTransactions(_ context.Context, _ AggregatedTransactionsRequestObject) (AggregatedTransactionsResponseObject, error) {
+func (w Wrapper) NetworkAnalysis(ctx context.Context, request NetworkAnalysisRequestObject) (NetworkAnalysisResponseObject, error) {
+	node, err := w.node(request.Params.Node)
+	if err != nil {
+		return nil, err
+	}
+	ts := client.TopologyService{
+		HTTPClient: node.Client,
+	}
+
+	networkTopology, err := ts.NetworkTopology(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return NetworkAnalysis200JSONResponse(topologyGraph(networkTopology).Analyze()), nil
+}
+
+// topologyGraph converts the network topology to a graph, using the PeerIDs as vertices
+func topologyGraph(topology client.NetworkTopology) *graph.Graph {
+	vertices := make([]string, 0, len(topology.Peers))
+	for _, p := range topology.Peers {
+		vertices = append(vertices, p.PeerID)
+	}
+	edges := make([][2]string, 0, len(topology.Edges))
+	for _, e := range topology.Edges {
+		edges = append(edges, e)
+	}
+	return graph.New(vertices, edges)
+}
+
+func (w Wrapper) NetworkConsistency(_ context.Context, request NetworkConsistencyRequestObject) (NetworkConsistencyResponseObject, error) {
+	node, err := w.node(request.Params.Node)
+	if err != nil {
+		return nil, err
+	}
+	return NetworkConsistency200JSONResponse(node.Consistency.Report()), nil
+}
+
+// defaultDAGRadius is the number of LC values rendered before and after a transaction when no radius is given
+const defaultDAGRadius = 10
+
+func (w Wrapper) RenderDAG(ctx context.Context, request RenderDAGRequestObject) (RenderDAGResponseObject, error) {
+	var start, end int
+	params := request.Params
+	node, err := w.node(params.Node)
+	if err != nil {
+		return nil, err
+	}
+	if params.Transaction != nil {
+		radius := defaultDAGRadius
+		if params.Radius != nil {
+			radius = *params.Radius
+		}
+		jws, err := node.Client.Transaction(ctx, *params.Transaction)
+		if err != nil {
+			// the node responds with 404 for an unknown and 400 for an invalid reference, other errors are the node's
+			var statusErr client.StatusError
+			if errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusBadRequest) {
+				return RenderDAG400TextResponse(fmt.Sprintf("unknown transaction: %s", *params.Transaction)), nil
+			}
+			return RenderDAG502TextResponse(fmt.Sprintf("failed to retrieve transaction: %s", err)), nil
+		}
+		transaction, err := data.FromJWS(jws)
+		if err != nil {
+			return RenderDAG400TextResponse(fmt.Sprintf("failed to parse transaction: %s", err)), nil
+		}
+		start = transaction.LC - radius
+		if start < 0 {
+			start = 0
+		}
+		end = transaction.LC + radius + 1
+	} else {
+		if params.Start == nil || params.End == nil {
+			return RenderDAG400TextResponse("either transaction or start and end are required"), nil
+		}
+		start, end = *params.Start, *params.End
+	}
+
+	if start < 0 || end <= start {
+		return RenderDAG400TextResponse(fmt.Sprintf("invalid range [%d, %d)", start, end)), nil
+	}
+	if maxRange := w.Config.Current().DAGRenderMaxRange; end-start > maxRange {
+		return RenderDAG400TextResponse(fmt.Sprintf("range [%d, %d) exceeds the maximum of %d", start, end, maxRange)), nil
+	}
+
+	dag, err := node.Client.RenderDAG(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return RenderDAG200JSONResponse(dag), nil
+}
+
+func (w Wrapper) AggregatedTransactions(_ context.Context, request AggregatedTransactionsRequestObject) (AggregatedTransactionsResponseObject, error) {
+	node, err := w.node(request.Params.Node)
+	if err != nil {
+		return nil, err
+	}
 	// get data from the store
-	dataPoints := w.DataStore.GetTransactions()
+	dataPoints := node.DataStore.GetTransactions()
 
 	// convert the data points to the response object
 	response := AggregatedTransactions{
@@ -133,9 +288,13 @@ func (w Wrapper) AggregatedTransactions(_ context.Context, _ AggregatedTransacti
 	return AggregatedTransactions200JSONResponse(response), nil
 }
 
-func (w Wrapper) TransactionCounts(_ context.Context, _ TransactionCountsRequestObject) (TransactionCountsResponseObject, error) {
+func (w Wrapper) TransactionCounts(_ context.Context, request TransactionCountsRequestObject) (TransactionCountsResponseObject, error) {
+	node, err := w.node(request.Params.Node)
+	if err != nil {
+		return nil, err
+	}
 	// get counts from the store
-	mapping, count := w.DataStore.GetTransactionCounts()
+	mapping, count := node.DataStore.GetTransactionCounts()
 
 	// create the basic response object
 	response := TransactionCounts200JSONResponse{
@@ -168,6 +327,58 @@ func (w Wrapper) TransactionCounts(_ context.Context, _ TransactionCountsRequest
 	return response, nil
 }
 
+func (w Wrapper) ClockSkew(_ context.Context, request ClockSkewRequestObject) (ClockSkewResponseObject, error) {
+	node, err := w.node(request.Params.Node)
+	if err != nil {
+		return nil, err
+	}
+	return ClockSkew200JSONResponse(node.DataStore.GetClockSkew()), nil
+}
+
+func (w Wrapper) SigningKeys(_ context.Context, request SigningKeysRequestObject) (SigningKeysResponseObject, error) {
+	node, err := w.node(request.Params.Node)
+	if err != nil {
+		return nil, err
+	}
+	return SigningKeys200JSONResponse(node.DataStore.GetKeys()), nil
+}
+
+func (w Wrapper) CredentialActivity(_ context.Context, request CredentialActivityRequestObject) (CredentialActivityResponseObject, error) {
+	node, err := w.node(request.Params.Node)
+	if err != nil {
+		return nil, err
+	}
+	return CredentialActivity200JSONResponse(node.DataStore.GetCredentials()), nil
+}
+
+func (w Wrapper) Anomalies(_ context.Context, request AnomaliesRequestObject) (AnomaliesResponseObject, error) {
+	node, err := w.node(request.Params.Node)
+	if err != nil {
+		return nil, err
+	}
+	return Anomalies200JSONResponse(node.DataStore.GetAnomalies()), nil
+}
+
+// defaultDIDChangesLimit is the number of DID document changes returned when no limit is given
+const defaultDIDChangesLimit = 100
+
+func (w Wrapper) DidChanges(_ context.Context, request DidChangesRequestObject) (DidChangesResponseObject, error) {
+	node, err := w.node(request.Params.Node)
+	if err != nil {
+		return nil, err
+	}
+	params := request.Params
+	query := ""
+	if params.Did != nil {
+		query = *params.Did
+	}
+	limit := defaultDIDChangesLimit
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+	return DidChanges200JSONResponse(node.DataStore.GetDIDChanges(query, params.Unexpected != nil && *params.Unexpected, limit)), nil
+}
+
 func toDataPoint(cty string, dp data.DataPoint) DataPoint {
 	return DataPoint{
 		ContentType: cty,
@@ -0,0 +1,49 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"context"
+	"nuts-foundation/nuts-monitor/config"
+)
+
+// ConfigOverview contains the effective, redacted configuration of the monitor
+type ConfigOverview struct {
+	Config map[string]interface{} `json:"config"`
+	// Sources contains the source of every option: default, file, env or flag
+	Sources map[string]string `json:"sources"`
+	// Reload contains the result of the config reloads
+	Reload ReloadStatus `json:"reload"`
+}
+
+func (w Wrapper) ConfigOverview(_ context.Context, _ ConfigOverviewRequestObject) (ConfigOverviewResponseObject, error) {
+	current := w.Config.Current()
+	return ConfigOverview200JSONResponse(ConfigOverview{
+		Config:  current.Map(),
+		Sources: current.Sources(),
+		Reload:  w.Config.Status(),
+	}), nil
+}
+
+func (w Wrapper) ReloadConfig(_ context.Context, _ ReloadConfigRequestObject) (ReloadConfigResponseObject, error) {
+	if err := w.Config.Reload(config.TriggerAPI); err != nil {
+		return ReloadConfig400TextResponse(err.Error()), nil
+	}
+	return ReloadConfig200JSONResponse(w.Config.Status()), nil
+}
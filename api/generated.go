@@ -7,9 +7,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/oapi-codegen/runtime"
 	strictecho "github.com/oapi-codegen/runtime/strictmiddleware/echo"
 )
 
@@ -139,23 +141,152 @@ type VDR struct {
 	DidDocumentsCount int `json:"did_documents_count"`
 }
 
+// AnomaliesParams defines parameters for Anomalies.
+type AnomaliesParams struct {
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
+// RenderDAGParams defines parameters for RenderDAG.
+type RenderDAGParams struct {
+	// Start LC value from where to start rendering (inclusive)
+	Start *int `form:"start,omitempty" json:"start,omitempty"`
+
+	// End LC value up to where to render (exclusive)
+	End *int `form:"end,omitempty" json:"end,omitempty"`
+
+	// Transaction reference of the transaction to render the DAG around, takes precedence over start and end
+	Transaction *string `form:"transaction,omitempty" json:"transaction,omitempty"`
+
+	// Radius number of LC values to render before and after the transaction, defaults to 10
+	Radius *int `form:"radius,omitempty" json:"radius,omitempty"`
+
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
+// DiagnosticsParams defines parameters for Diagnostics.
+type DiagnosticsParams struct {
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
+// DidChangesParams defines parameters for DidChanges.
+type DidChangesParams struct {
+	// Did only return the changes of DIDs that contain this value
+	Did *string `form:"did,omitempty" json:"did,omitempty"`
+
+	// Unexpected only return unexpected changes
+	Unexpected *bool `form:"unexpected,omitempty" json:"unexpected,omitempty"`
+
+	// Limit maximum number of changes to return, defaults to 100
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
+// NetworkAnalysisParams defines parameters for NetworkAnalysis.
+type NetworkAnalysisParams struct {
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
+// NetworkConsistencyParams defines parameters for NetworkConsistency.
+type NetworkConsistencyParams struct {
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
+// NetworkTopologyParams defines parameters for NetworkTopology.
+type NetworkTopologyParams struct {
+	// Format export format, one of json, dot, graphml or jgf
+	Format *string `form:"format,omitempty" json:"format,omitempty"`
+
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
+// AggregatedTransactionsParams defines parameters for AggregatedTransactions.
+type AggregatedTransactionsParams struct {
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
+// ClockSkewParams defines parameters for ClockSkew.
+type ClockSkewParams struct {
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
+// TransactionCountsParams defines parameters for TransactionCounts.
+type TransactionCountsParams struct {
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
+// CredentialActivityParams defines parameters for CredentialActivity.
+type CredentialActivityParams struct {
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
+// SigningKeysParams defines parameters for SigningKeys.
+type SigningKeysParams struct {
+	// Node name of the monitored node, defaults to the first configured node
+	Node *string `form:"node,omitempty" json:"node,omitempty"`
+}
+
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
 	// More elaborate health check to conform the app is (probably) functioning correctly
 	// (GET /health)
 	CheckHealth(ctx echo.Context) error
+	// Returns the anomalies in the transaction rates
+	// (GET /web/anomalies)
+	Anomalies(ctx echo.Context, params AnomaliesParams) error
+	// Returns the effective configuration of the monitor
+	// (GET /web/config)
+	ConfigOverview(ctx echo.Context) error
+	// Reloads the configuration
+	// (POST /web/config/reload)
+	ReloadConfig(ctx echo.Context) error
+	// Renders a slice of the DAG
+	// (GET /web/dag)
+	RenderDAG(ctx echo.Context, params RenderDAGParams) error
 	// Returns the node key diagnostics
 	// (GET /web/diagnostics)
-	Diagnostics(ctx echo.Context) error
+	Diagnostics(ctx echo.Context, params DiagnosticsParams) error
+	// Returns the changes of DID documents
+	// (GET /web/dids/changes)
+	DidChanges(ctx echo.Context, params DidChangesParams) error
+	// Analyses the network topology for partitions and single points of failure
+	// (GET /web/network/analysis)
+	NetworkAnalysis(ctx echo.Context, params NetworkAnalysisParams) error
+	// Compares the transaction count of our node with the counts of its peers
+	// (GET /web/network/consistency)
+	NetworkConsistency(ctx echo.Context, params NetworkConsistencyParams) error
 	// Returns the network as a graph model
 	// (GET /web/network_topology)
-	NetworkTopology(ctx echo.Context) error
+	NetworkTopology(ctx echo.Context, params NetworkTopologyParams) error
+	// Compares all monitored nodes
+	// (GET /web/nodes)
+	NodesOverview(ctx echo.Context) error
 	// Returns the transactions aggregated by time
 	// (GET /web/transactions/aggregated)
-	AggregatedTransactions(ctx echo.Context) error
+	AggregatedTransactions(ctx echo.Context, params AggregatedTransactionsParams) error
+	// Returns the signers and transactions with an implausible signature time
+	// (GET /web/transactions/clockskew)
+	ClockSkew(ctx echo.Context, params ClockSkewParams) error
 	// Return the number of transactions per node and total known nodes
 	// (GET /web/transactions/counts)
-	TransactionCounts(ctx echo.Context) error
+	TransactionCounts(ctx echo.Context, params TransactionCountsParams) error
+	// Returns the credential issuance and revocation activity
+	// (GET /web/transactions/credentials)
+	CredentialActivity(ctx echo.Context, params CredentialActivityParams) error
+	// Returns the statistics per signing key
+	// (GET /web/transactions/keys)
+	SigningKeys(ctx echo.Context, params SigningKeysParams) error
 }
 
 // ServerInterfaceWrapper converts echo contexts to parameters.
@@ -172,12 +303,178 @@ func (w *ServerInterfaceWrapper) CheckHealth(ctx echo.Context) error {
 	return err
 }
 
+// Anomalies converts echo context to params.
+func (w *ServerInterfaceWrapper) Anomalies(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params AnomaliesParams
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.Anomalies(ctx, params)
+	return err
+}
+
+// ConfigOverview converts echo context to params.
+func (w *ServerInterfaceWrapper) ConfigOverview(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.ConfigOverview(ctx)
+	return err
+}
+
+// ReloadConfig converts echo context to params.
+func (w *ServerInterfaceWrapper) ReloadConfig(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.ReloadConfig(ctx)
+	return err
+}
+
+// RenderDAG converts echo context to params.
+func (w *ServerInterfaceWrapper) RenderDAG(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params RenderDAGParams
+	// ------------- Optional query parameter "start" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "start", ctx.QueryParams(), &params.Start)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter start: %s", err))
+	}
+
+	// ------------- Optional query parameter "end" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "end", ctx.QueryParams(), &params.End)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter end: %s", err))
+	}
+
+	// ------------- Optional query parameter "transaction" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "transaction", ctx.QueryParams(), &params.Transaction)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter transaction: %s", err))
+	}
+
+	// ------------- Optional query parameter "radius" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "radius", ctx.QueryParams(), &params.Radius)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter radius: %s", err))
+	}
+
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.RenderDAG(ctx, params)
+	return err
+}
+
 // Diagnostics converts echo context to params.
 func (w *ServerInterfaceWrapper) Diagnostics(ctx echo.Context) error {
 	var err error
 
+	// Parameter object where we will unmarshal all parameters from the context
+	var params DiagnosticsParams
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.Diagnostics(ctx, params)
+	return err
+}
+
+// DidChanges converts echo context to params.
+func (w *ServerInterfaceWrapper) DidChanges(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params DidChangesParams
+	// ------------- Optional query parameter "did" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "did", ctx.QueryParams(), &params.Did)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter did: %s", err))
+	}
+
+	// ------------- Optional query parameter "unexpected" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "unexpected", ctx.QueryParams(), &params.Unexpected)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter unexpected: %s", err))
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", ctx.QueryParams(), &params.Limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter limit: %s", err))
+	}
+
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
 	// Invoke the callback with all the unmarshaled arguments
-	err = w.Handler.Diagnostics(ctx)
+	err = w.Handler.DidChanges(ctx, params)
+	return err
+}
+
+// NetworkAnalysis converts echo context to params.
+func (w *ServerInterfaceWrapper) NetworkAnalysis(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params NetworkAnalysisParams
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.NetworkAnalysis(ctx, params)
+	return err
+}
+
+// NetworkConsistency converts echo context to params.
+func (w *ServerInterfaceWrapper) NetworkConsistency(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params NetworkConsistencyParams
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.NetworkConsistency(ctx, params)
 	return err
 }
 
@@ -185,8 +482,33 @@ func (w *ServerInterfaceWrapper) Diagnostics(ctx echo.Context) error {
 func (w *ServerInterfaceWrapper) NetworkTopology(ctx echo.Context) error {
 	var err error
 
+	// Parameter object where we will unmarshal all parameters from the context
+	var params NetworkTopologyParams
+	// ------------- Optional query parameter "format" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "format", ctx.QueryParams(), &params.Format)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter format: %s", err))
+	}
+
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
 	// Invoke the callback with all the unmarshaled arguments
-	err = w.Handler.NetworkTopology(ctx)
+	err = w.Handler.NetworkTopology(ctx, params)
+	return err
+}
+
+// NodesOverview converts echo context to params.
+func (w *ServerInterfaceWrapper) NodesOverview(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.NodesOverview(ctx)
 	return err
 }
 
@@ -194,8 +516,35 @@ func (w *ServerInterfaceWrapper) NetworkTopology(ctx echo.Context) error {
 func (w *ServerInterfaceWrapper) AggregatedTransactions(ctx echo.Context) error {
 	var err error
 
+	// Parameter object where we will unmarshal all parameters from the context
+	var params AggregatedTransactionsParams
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.AggregatedTransactions(ctx, params)
+	return err
+}
+
+// ClockSkew converts echo context to params.
+func (w *ServerInterfaceWrapper) ClockSkew(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ClockSkewParams
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
 	// Invoke the callback with all the unmarshaled arguments
-	err = w.Handler.AggregatedTransactions(ctx)
+	err = w.Handler.ClockSkew(ctx, params)
 	return err
 }
 
@@ -203,8 +552,53 @@ func (w *ServerInterfaceWrapper) AggregatedTransactions(ctx echo.Context) error
 func (w *ServerInterfaceWrapper) TransactionCounts(ctx echo.Context) error {
 	var err error
 
+	// Parameter object where we will unmarshal all parameters from the context
+	var params TransactionCountsParams
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.TransactionCounts(ctx, params)
+	return err
+}
+
+// CredentialActivity converts echo context to params.
+func (w *ServerInterfaceWrapper) CredentialActivity(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params CredentialActivityParams
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
+	// Invoke the callback with all the unmarshaled arguments
+	err = w.Handler.CredentialActivity(ctx, params)
+	return err
+}
+
+// SigningKeys converts echo context to params.
+func (w *ServerInterfaceWrapper) SigningKeys(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params SigningKeysParams
+	// ------------- Optional query parameter "node" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "node", ctx.QueryParams(), &params.Node)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid format for parameter node: %s", err))
+	}
+
 	// Invoke the callback with all the unmarshaled arguments
-	err = w.Handler.TransactionCounts(ctx)
+	err = w.Handler.SigningKeys(ctx, params)
 	return err
 }
 
@@ -223,110 +617,387 @@ type EchoRouter interface {
 	TRACE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
 }
 
-// RegisterHandlers adds each server route to the EchoRouter.
-func RegisterHandlers(router EchoRouter, si ServerInterface) {
-	RegisterHandlersWithBaseURL(router, si, "")
+// RegisterHandlers adds each server route to the EchoRouter.
+func RegisterHandlers(router EchoRouter, si ServerInterface) {
+	RegisterHandlersWithBaseURL(router, si, "")
+}
+
+// Registers handlers, and prepends BaseURL to the paths, so that the paths
+// can be served under a prefix.
+func RegisterHandlersWithBaseURL(router EchoRouter, si ServerInterface, baseURL string) {
+
+	wrapper := ServerInterfaceWrapper{
+		Handler: si,
+	}
+
+	router.GET(baseURL+"/health", wrapper.CheckHealth)
+	router.GET(baseURL+"/web/anomalies", wrapper.Anomalies)
+	router.GET(baseURL+"/web/config", wrapper.ConfigOverview)
+	router.POST(baseURL+"/web/config/reload", wrapper.ReloadConfig)
+	router.GET(baseURL+"/web/dag", wrapper.RenderDAG)
+	router.GET(baseURL+"/web/diagnostics", wrapper.Diagnostics)
+	router.GET(baseURL+"/web/dids/changes", wrapper.DidChanges)
+	router.GET(baseURL+"/web/network/analysis", wrapper.NetworkAnalysis)
+	router.GET(baseURL+"/web/network/consistency", wrapper.NetworkConsistency)
+	router.GET(baseURL+"/web/network_topology", wrapper.NetworkTopology)
+	router.GET(baseURL+"/web/nodes", wrapper.NodesOverview)
+	router.GET(baseURL+"/web/transactions/aggregated", wrapper.AggregatedTransactions)
+	router.GET(baseURL+"/web/transactions/clockskew", wrapper.ClockSkew)
+	router.GET(baseURL+"/web/transactions/counts", wrapper.TransactionCounts)
+	router.GET(baseURL+"/web/transactions/credentials", wrapper.CredentialActivity)
+	router.GET(baseURL+"/web/transactions/keys", wrapper.SigningKeys)
+
+}
+
+type CheckHealthRequestObject struct {
+}
+
+type CheckHealthResponseObject interface {
+	VisitCheckHealthResponse(w http.ResponseWriter) error
+}
+
+type CheckHealth200JSONResponse CheckHealthResponse
+
+func (response CheckHealth200JSONResponse) VisitCheckHealthResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type CheckHealth503JSONResponse CheckHealthResponse
+
+func (response CheckHealth503JSONResponse) VisitCheckHealthResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(503)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type AnomaliesRequestObject struct {
+	Params AnomaliesParams
+}
+
+type AnomaliesResponseObject interface {
+	VisitAnomaliesResponse(w http.ResponseWriter) error
+}
+
+type Anomalies200JSONResponse AnomalyReport
+
+func (response Anomalies200JSONResponse) VisitAnomaliesResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type ConfigOverviewRequestObject struct {
+}
+
+type ConfigOverviewResponseObject interface {
+	VisitConfigOverviewResponse(w http.ResponseWriter) error
+}
+
+type ConfigOverview200JSONResponse ConfigOverview
+
+func (response ConfigOverview200JSONResponse) VisitConfigOverviewResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type ReloadConfigRequestObject struct {
+}
+
+type ReloadConfigResponseObject interface {
+	VisitReloadConfigResponse(w http.ResponseWriter) error
+}
+
+type ReloadConfig200JSONResponse ReloadStatus
+
+func (response ReloadConfig200JSONResponse) VisitReloadConfigResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type ReloadConfig400TextResponse string
+
+func (response ReloadConfig400TextResponse) VisitReloadConfigResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(400)
+
+	_, err := w.Write([]byte(response))
+	return err
+}
+
+type RenderDAGRequestObject struct {
+	Params RenderDAGParams
+}
+
+type RenderDAGResponseObject interface {
+	VisitRenderDAGResponse(w http.ResponseWriter) error
+}
+
+type RenderDAG200JSONResponse DAG
+
+func (response RenderDAG200JSONResponse) VisitRenderDAGResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type RenderDAG400TextResponse string
+
+func (response RenderDAG400TextResponse) VisitRenderDAGResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(400)
+
+	_, err := w.Write([]byte(response))
+	return err
+}
+
+type RenderDAG502TextResponse string
+
+func (response RenderDAG502TextResponse) VisitRenderDAGResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(502)
+
+	_, err := w.Write([]byte(response))
+	return err
+}
+
+type DiagnosticsRequestObject struct {
+	Params DiagnosticsParams
+}
+
+type DiagnosticsResponseObject interface {
+	VisitDiagnosticsResponse(w http.ResponseWriter) error
+}
+
+type Diagnostics200JSONResponse Diagnostics
+
+func (response Diagnostics200JSONResponse) VisitDiagnosticsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type DidChangesRequestObject struct {
+	Params DidChangesParams
+}
+
+type DidChangesResponseObject interface {
+	VisitDidChangesResponse(w http.ResponseWriter) error
+}
+
+type DidChanges200JSONResponse []DIDChange
+
+func (response DidChanges200JSONResponse) VisitDidChangesResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type NetworkAnalysisRequestObject struct {
+	Params NetworkAnalysisParams
+}
+
+type NetworkAnalysisResponseObject interface {
+	VisitNetworkAnalysisResponse(w http.ResponseWriter) error
+}
+
+type NetworkAnalysis200JSONResponse NetworkAnalysis
+
+func (response NetworkAnalysis200JSONResponse) VisitNetworkAnalysisResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type NetworkConsistencyRequestObject struct {
+	Params NetworkConsistencyParams
+}
+
+type NetworkConsistencyResponseObject interface {
+	VisitNetworkConsistencyResponse(w http.ResponseWriter) error
+}
+
+type NetworkConsistency200JSONResponse ConsistencyReport
+
+func (response NetworkConsistency200JSONResponse) VisitNetworkConsistencyResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type NetworkTopologyRequestObject struct {
+	Params NetworkTopologyParams
+}
+
+type NetworkTopologyResponseObject interface {
+	VisitNetworkTopologyResponse(w http.ResponseWriter) error
+}
+
+type NetworkTopology200ApplicationGraphmlXmlResponse struct {
+	Body          io.Reader
+	ContentLength int64
+}
+
+func (response NetworkTopology200ApplicationGraphmlXmlResponse) VisitNetworkTopologyResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/graphml+xml")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(200)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type NetworkTopology200JSONResponse NetworkTopology
+
+func (response NetworkTopology200JSONResponse) VisitNetworkTopologyResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type NetworkTopology200ApplicationVndJgfJSONResponse JSONGraph
+
+func (response NetworkTopology200ApplicationVndJgfJSONResponse) VisitNetworkTopologyResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/vnd.jgf+json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
 }
 
-// Registers handlers, and prepends BaseURL to the paths, so that the paths
-// can be served under a prefix.
-func RegisterHandlersWithBaseURL(router EchoRouter, si ServerInterface, baseURL string) {
+type NetworkTopology200TextResponse string
 
-	wrapper := ServerInterfaceWrapper{
-		Handler: si,
-	}
+func (response NetworkTopology200TextResponse) VisitNetworkTopologyResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+	w.WriteHeader(200)
 
-	router.GET(baseURL+"/health", wrapper.CheckHealth)
-	router.GET(baseURL+"/web/diagnostics", wrapper.Diagnostics)
-	router.GET(baseURL+"/web/network_topology", wrapper.NetworkTopology)
-	router.GET(baseURL+"/web/transactions/aggregated", wrapper.AggregatedTransactions)
-	router.GET(baseURL+"/web/transactions/counts", wrapper.TransactionCounts)
+	_, err := w.Write([]byte(response))
+	return err
+}
+
+type NetworkTopology400TextResponse string
+
+func (response NetworkTopology400TextResponse) VisitNetworkTopologyResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(400)
 
+	_, err := w.Write([]byte(response))
+	return err
 }
 
-type CheckHealthRequestObject struct {
+type NodesOverviewRequestObject struct {
 }
 
-type CheckHealthResponseObject interface {
-	VisitCheckHealthResponse(w http.ResponseWriter) error
+type NodesOverviewResponseObject interface {
+	VisitNodesOverviewResponse(w http.ResponseWriter) error
 }
 
-type CheckHealth200JSONResponse CheckHealthResponse
+type NodesOverview200JSONResponse NodesOverview
 
-func (response CheckHealth200JSONResponse) VisitCheckHealthResponse(w http.ResponseWriter) error {
+func (response NodesOverview200JSONResponse) VisitNodesOverviewResponse(w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 
 	return json.NewEncoder(w).Encode(response)
 }
 
-type CheckHealth503JSONResponse CheckHealthResponse
+type AggregatedTransactionsRequestObject struct {
+	Params AggregatedTransactionsParams
+}
 
-func (response CheckHealth503JSONResponse) VisitCheckHealthResponse(w http.ResponseWriter) error {
+type AggregatedTransactionsResponseObject interface {
+	VisitAggregatedTransactionsResponse(w http.ResponseWriter) error
+}
+
+type AggregatedTransactions200JSONResponse AggregatedTransactions
+
+func (response AggregatedTransactions200JSONResponse) VisitAggregatedTransactionsResponse(w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(503)
+	w.WriteHeader(200)
 
 	return json.NewEncoder(w).Encode(response)
 }
 
-type DiagnosticsRequestObject struct {
+type ClockSkewRequestObject struct {
+	Params ClockSkewParams
 }
 
-type DiagnosticsResponseObject interface {
-	VisitDiagnosticsResponse(w http.ResponseWriter) error
+type ClockSkewResponseObject interface {
+	VisitClockSkewResponse(w http.ResponseWriter) error
 }
 
-type Diagnostics200JSONResponse Diagnostics
+type ClockSkew200JSONResponse ClockSkewReport
 
-func (response Diagnostics200JSONResponse) VisitDiagnosticsResponse(w http.ResponseWriter) error {
+func (response ClockSkew200JSONResponse) VisitClockSkewResponse(w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 
 	return json.NewEncoder(w).Encode(response)
 }
 
-type NetworkTopologyRequestObject struct {
+type TransactionCountsRequestObject struct {
+	Params TransactionCountsParams
 }
 
-type NetworkTopologyResponseObject interface {
-	VisitNetworkTopologyResponse(w http.ResponseWriter) error
+type TransactionCountsResponseObject interface {
+	VisitTransactionCountsResponse(w http.ResponseWriter) error
 }
 
-type NetworkTopology200JSONResponse NetworkTopology
+type TransactionCounts200JSONResponse TransactionCounts
 
-func (response NetworkTopology200JSONResponse) VisitNetworkTopologyResponse(w http.ResponseWriter) error {
+func (response TransactionCounts200JSONResponse) VisitTransactionCountsResponse(w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 
 	return json.NewEncoder(w).Encode(response)
 }
 
-type AggregatedTransactionsRequestObject struct {
+type CredentialActivityRequestObject struct {
+	Params CredentialActivityParams
 }
 
-type AggregatedTransactionsResponseObject interface {
-	VisitAggregatedTransactionsResponse(w http.ResponseWriter) error
+type CredentialActivityResponseObject interface {
+	VisitCredentialActivityResponse(w http.ResponseWriter) error
 }
 
-type AggregatedTransactions200JSONResponse AggregatedTransactions
+type CredentialActivity200JSONResponse CredentialReport
 
-func (response AggregatedTransactions200JSONResponse) VisitAggregatedTransactionsResponse(w http.ResponseWriter) error {
+func (response CredentialActivity200JSONResponse) VisitCredentialActivityResponse(w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 
 	return json.NewEncoder(w).Encode(response)
 }
 
-type TransactionCountsRequestObject struct {
+type SigningKeysRequestObject struct {
+	Params SigningKeysParams
 }
 
-type TransactionCountsResponseObject interface {
-	VisitTransactionCountsResponse(w http.ResponseWriter) error
+type SigningKeysResponseObject interface {
+	VisitSigningKeysResponse(w http.ResponseWriter) error
 }
 
-type TransactionCounts200JSONResponse TransactionCounts
+type SigningKeys200JSONResponse KeyReport
 
-func (response TransactionCounts200JSONResponse) VisitTransactionCountsResponse(w http.ResponseWriter) error {
+func (response SigningKeys200JSONResponse) VisitSigningKeysResponse(w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 
@@ -338,18 +1009,51 @@ type StrictServerInterface interface {
 	// More elaborate health check to conform the app is (probably) functioning correctly
 	// (GET /health)
 	CheckHealth(ctx context.Context, request CheckHealthRequestObject) (CheckHealthResponseObject, error)
+	// Returns the anomalies in the transaction rates
+	// (GET /web/anomalies)
+	Anomalies(ctx context.Context, request AnomaliesRequestObject) (AnomaliesResponseObject, error)
+	// Returns the effective configuration of the monitor
+	// (GET /web/config)
+	ConfigOverview(ctx context.Context, request ConfigOverviewRequestObject) (ConfigOverviewResponseObject, error)
+	// Reloads the configuration
+	// (POST /web/config/reload)
+	ReloadConfig(ctx context.Context, request ReloadConfigRequestObject) (ReloadConfigResponseObject, error)
+	// Renders a slice of the DAG
+	// (GET /web/dag)
+	RenderDAG(ctx context.Context, request RenderDAGRequestObject) (RenderDAGResponseObject, error)
 	// Returns the node key diagnostics
 	// (GET /web/diagnostics)
 	Diagnostics(ctx context.Context, request DiagnosticsRequestObject) (DiagnosticsResponseObject, error)
+	// Returns the changes of DID documents
+	// (GET /web/dids/changes)
+	DidChanges(ctx context.Context, request DidChangesRequestObject) (DidChangesResponseObject, error)
+	// Analyses the network topology for partitions and single points of failure
+	// (GET /web/network/analysis)
+	NetworkAnalysis(ctx context.Context, request NetworkAnalysisRequestObject) (NetworkAnalysisResponseObject, error)
+	// Compares the transaction count of our node with the counts of its peers
+	// (GET /web/network/consistency)
+	NetworkConsistency(ctx context.Context, request NetworkConsistencyRequestObject) (NetworkConsistencyResponseObject, error)
 	// Returns the network as a graph model
 	// (GET /web/network_topology)
 	NetworkTopology(ctx context.Context, request NetworkTopologyRequestObject) (NetworkTopologyResponseObject, error)
+	// Compares all monitored nodes
+	// (GET /web/nodes)
+	NodesOverview(ctx context.Context, request NodesOverviewRequestObject) (NodesOverviewResponseObject, error)
 	// Returns the transactions aggregated by time
 	// (GET /web/transactions/aggregated)
 	AggregatedTransactions(ctx context.Context, request AggregatedTransactionsRequestObject) (AggregatedTransactionsResponseObject, error)
+	// Returns the signers and transactions with an implausible signature time
+	// (GET /web/transactions/clockskew)
+	ClockSkew(ctx context.Context, request ClockSkewRequestObject) (ClockSkewResponseObject, error)
 	// Return the number of transactions per node and total known nodes
 	// (GET /web/transactions/counts)
 	TransactionCounts(ctx context.Context, request TransactionCountsRequestObject) (TransactionCountsResponseObject, error)
+	// Returns the credential issuance and revocation activity
+	// (GET /web/transactions/credentials)
+	CredentialActivity(ctx context.Context, request CredentialActivityRequestObject) (CredentialActivityResponseObject, error)
+	// Returns the statistics per signing key
+	// (GET /web/transactions/keys)
+	SigningKeys(ctx context.Context, request SigningKeysRequestObject) (SigningKeysResponseObject, error)
 }
 
 type StrictHandlerFunc = strictecho.StrictEchoHandlerFunc
@@ -387,10 +1091,108 @@ func (sh *strictHandler) CheckHealth(ctx echo.Context) error {
 	return nil
 }
 
+// Anomalies operation middleware
+func (sh *strictHandler) Anomalies(ctx echo.Context, params AnomaliesParams) error {
+	var request AnomaliesRequestObject
+
+	request.Params = params
+
+	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
+		return sh.ssi.Anomalies(ctx.Request().Context(), request.(AnomaliesRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "Anomalies")
+	}
+
+	response, err := handler(ctx, request)
+
+	if err != nil {
+		return err
+	} else if validResponse, ok := response.(AnomaliesResponseObject); ok {
+		return validResponse.VisitAnomaliesResponse(ctx.Response())
+	} else if response != nil {
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+	return nil
+}
+
+// ConfigOverview operation middleware
+func (sh *strictHandler) ConfigOverview(ctx echo.Context) error {
+	var request ConfigOverviewRequestObject
+
+	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
+		return sh.ssi.ConfigOverview(ctx.Request().Context(), request.(ConfigOverviewRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "ConfigOverview")
+	}
+
+	response, err := handler(ctx, request)
+
+	if err != nil {
+		return err
+	} else if validResponse, ok := response.(ConfigOverviewResponseObject); ok {
+		return validResponse.VisitConfigOverviewResponse(ctx.Response())
+	} else if response != nil {
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+	return nil
+}
+
+// ReloadConfig operation middleware
+func (sh *strictHandler) ReloadConfig(ctx echo.Context) error {
+	var request ReloadConfigRequestObject
+
+	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
+		return sh.ssi.ReloadConfig(ctx.Request().Context(), request.(ReloadConfigRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "ReloadConfig")
+	}
+
+	response, err := handler(ctx, request)
+
+	if err != nil {
+		return err
+	} else if validResponse, ok := response.(ReloadConfigResponseObject); ok {
+		return validResponse.VisitReloadConfigResponse(ctx.Response())
+	} else if response != nil {
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+	return nil
+}
+
+// RenderDAG operation middleware
+func (sh *strictHandler) RenderDAG(ctx echo.Context, params RenderDAGParams) error {
+	var request RenderDAGRequestObject
+
+	request.Params = params
+
+	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
+		return sh.ssi.RenderDAG(ctx.Request().Context(), request.(RenderDAGRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "RenderDAG")
+	}
+
+	response, err := handler(ctx, request)
+
+	if err != nil {
+		return err
+	} else if validResponse, ok := response.(RenderDAGResponseObject); ok {
+		return validResponse.VisitRenderDAGResponse(ctx.Response())
+	} else if response != nil {
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+	return nil
+}
+
 // Diagnostics operation middleware
-func (sh *strictHandler) Diagnostics(ctx echo.Context) error {
+func (sh *strictHandler) Diagnostics(ctx echo.Context, params DiagnosticsParams) error {
 	var request DiagnosticsRequestObject
 
+	request.Params = params
+
 	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
 		return sh.ssi.Diagnostics(ctx.Request().Context(), request.(DiagnosticsRequestObject))
 	}
@@ -410,10 +1212,87 @@ func (sh *strictHandler) Diagnostics(ctx echo.Context) error {
 	return nil
 }
 
+// DidChanges operation middleware
+func (sh *strictHandler) DidChanges(ctx echo.Context, params DidChangesParams) error {
+	var request DidChangesRequestObject
+
+	request.Params = params
+
+	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
+		return sh.ssi.DidChanges(ctx.Request().Context(), request.(DidChangesRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "DidChanges")
+	}
+
+	response, err := handler(ctx, request)
+
+	if err != nil {
+		return err
+	} else if validResponse, ok := response.(DidChangesResponseObject); ok {
+		return validResponse.VisitDidChangesResponse(ctx.Response())
+	} else if response != nil {
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+	return nil
+}
+
+// NetworkAnalysis operation middleware
+func (sh *strictHandler) NetworkAnalysis(ctx echo.Context, params NetworkAnalysisParams) error {
+	var request NetworkAnalysisRequestObject
+
+	request.Params = params
+
+	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
+		return sh.ssi.NetworkAnalysis(ctx.Request().Context(), request.(NetworkAnalysisRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "NetworkAnalysis")
+	}
+
+	response, err := handler(ctx, request)
+
+	if err != nil {
+		return err
+	} else if validResponse, ok := response.(NetworkAnalysisResponseObject); ok {
+		return validResponse.VisitNetworkAnalysisResponse(ctx.Response())
+	} else if response != nil {
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+	return nil
+}
+
+// NetworkConsistency operation middleware
+func (sh *strictHandler) NetworkConsistency(ctx echo.Context, params NetworkConsistencyParams) error {
+	var request NetworkConsistencyRequestObject
+
+	request.Params = params
+
+	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
+		return sh.ssi.NetworkConsistency(ctx.Request().Context(), request.(NetworkConsistencyRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "NetworkConsistency")
+	}
+
+	response, err := handler(ctx, request)
+
+	if err != nil {
+		return err
+	} else if validResponse, ok := response.(NetworkConsistencyResponseObject); ok {
+		return validResponse.VisitNetworkConsistencyResponse(ctx.Response())
+	} else if response != nil {
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+	return nil
+}
+
 // NetworkTopology operation middleware
-func (sh *strictHandler) NetworkTopology(ctx echo.Context) error {
+func (sh *strictHandler) NetworkTopology(ctx echo.Context, params NetworkTopologyParams) error {
 	var request NetworkTopologyRequestObject
 
+	request.Params = params
+
 	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
 		return sh.ssi.NetworkTopology(ctx.Request().Context(), request.(NetworkTopologyRequestObject))
 	}
@@ -433,10 +1312,35 @@ func (sh *strictHandler) NetworkTopology(ctx echo.Context) error {
 	return nil
 }
 
+// NodesOverview operation middleware
+func (sh *strictHandler) NodesOverview(ctx echo.Context) error {
+	var request NodesOverviewRequestObject
+
+	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
+		return sh.ssi.NodesOverview(ctx.Request().Context(), request.(NodesOverviewRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "NodesOverview")
+	}
+
+	response, err := handler(ctx, request)
+
+	if err != nil {
+		return err
+	} else if validResponse, ok := response.(NodesOverviewResponseObject); ok {
+		return validResponse.VisitNodesOverviewResponse(ctx.Response())
+	} else if response != nil {
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+	return nil
+}
+
 // AggregatedTransactions operation middleware
-func (sh *strictHandler) AggregatedTransactions(ctx echo.Context) error {
+func (sh *strictHandler) AggregatedTransactions(ctx echo.Context, params AggregatedTransactionsParams) error {
 	var request AggregatedTransactionsRequestObject
 
+	request.Params = params
+
 	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
 		return sh.ssi.AggregatedTransactions(ctx.Request().Context(), request.(AggregatedTransactionsRequestObject))
 	}
@@ -456,10 +1360,37 @@ func (sh *strictHandler) AggregatedTransactions(ctx echo.Context) error {
 	return nil
 }
 
+// ClockSkew operation middleware
+func (sh *strictHandler) ClockSkew(ctx echo.Context, params ClockSkewParams) error {
+	var request ClockSkewRequestObject
+
+	request.Params = params
+
+	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
+		return sh.ssi.ClockSkew(ctx.Request().Context(), request.(ClockSkewRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "ClockSkew")
+	}
+
+	response, err := handler(ctx, request)
+
+	if err != nil {
+		return err
+	} else if validResponse, ok := response.(ClockSkewResponseObject); ok {
+		return validResponse.VisitClockSkewResponse(ctx.Response())
+	} else if response != nil {
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+	return nil
+}
+
 // TransactionCounts operation middleware
-func (sh *strictHandler) TransactionCounts(ctx echo.Context) error {
+func (sh *strictHandler) TransactionCounts(ctx echo.Context, params TransactionCountsParams) error {
 	var request TransactionCountsRequestObject
 
+	request.Params = params
+
 	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
 		return sh.ssi.TransactionCounts(ctx.Request().Context(), request.(TransactionCountsRequestObject))
 	}
@@ -478,3 +1409,53 @@ func (sh *strictHandler) TransactionCounts(ctx echo.Context) error {
 	}
 	return nil
 }
+
+// CredentialActivity operation middleware
+func (sh *strictHandler) CredentialActivity(ctx echo.Context, params CredentialActivityParams) error {
+	var request CredentialActivityRequestObject
+
+	request.Params = params
+
+	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
+		return sh.ssi.CredentialActivity(ctx.Request().Context(), request.(CredentialActivityRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "CredentialActivity")
+	}
+
+	response, err := handler(ctx, request)
+
+	if err != nil {
+		return err
+	} else if validResponse, ok := response.(CredentialActivityResponseObject); ok {
+		return validResponse.VisitCredentialActivityResponse(ctx.Response())
+	} else if response != nil {
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+	return nil
+}
+
+// SigningKeys operation middleware
+func (sh *strictHandler) SigningKeys(ctx echo.Context, params SigningKeysParams) error {
+	var request SigningKeysRequestObject
+
+	request.Params = params
+
+	handler := func(ctx echo.Context, request interface{}) (interface{}, error) {
+		return sh.ssi.SigningKeys(ctx.Request().Context(), request.(SigningKeysRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "SigningKeys")
+	}
+
+	response, err := handler(ctx, request)
+
+	if err != nil {
+		return err
+	} else if validResponse, ok := response.(SigningKeysResponseObject); ok {
+		return validResponse.VisitSigningKeysResponse(ctx.Response())
+	} else if response != nil {
+		return fmt.Errorf("unexpected response type: %T", response)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"nuts-foundation/nuts-monitor/client"
+
+	"github.com/labstack/echo/v4"
+)
+
+// nodeMetric is a Prometheus metric with a value per monitored node
+type nodeMetric struct {
+	name  string
+	help  string
+	kind  string
+	value func(m client.Metrics) float64
+}
+
+var nodeMetrics = []nodeMetric{
+	{"nuts_monitor_node_requests_total", "Number of requests to the Nuts node, excluding retries.", "counter", func(m client.Metrics) float64 { return float64(m.Requests) }},
+	{"nuts_monitor_node_retries_total", "Number of retried requests to the Nuts node.", "counter", func(m client.Metrics) float64 { return float64(m.Retries) }},
+	{"nuts_monitor_node_failures_total", "Number of requests to the Nuts node that failed after all retries.", "counter", func(m client.Metrics) float64 { return float64(m.Failures) }},
+	{"nuts_monitor_node_rejected_total", "Number of requests not sent to the Nuts node because the circuit breaker was open.", "counter", func(m client.Metrics) float64 { return float64(m.Rejected) }},
+	{"nuts_monitor_node_breaker_opened_total", "Number of times the circuit breaker opened.", "counter", func(m client.Metrics) float64 { return float64(m.BreakerOpened) }},
+	{"nuts_monitor_node_requests_in_flight", "Number of requests currently sent to the Nuts node.", "gauge", func(m client.Metrics) float64 { return float64(m.InFlight) }},
+	{"nuts_monitor_node_requests_waiting", "Number of requests waiting for the rate or concurrency limit.", "gauge", func(m client.Metrics) float64 { return float64(m.Waiting) }},
+	{"nuts_monitor_node_breaker_open", "1 if the circuit breaker is open or half-open, 0 if it's closed.", "gauge", func(m client.Metrics) float64 {
+		if m.BreakerState == client.BreakerClosed.String() {
+			return 0
+		}
+		return 1
+	}},
+}
+
+// Metrics returns the metrics of the clients of all nodes in the Prometheus text format
+func (w Wrapper) Metrics(ctx echo.Context) error {
+	metrics := make([]client.Metrics, len(w.Nodes))
+	for i, node := range w.Nodes {
+		metrics[i] = node.Client.Metrics()
+	}
+
+	buf := new(bytes.Buffer)
+	for _, metric := range nodeMetrics {
+		fmt.Fprintf(buf, "# HELP %s %s\n", metric.name, metric.help)
+		fmt.Fprintf(buf, "# TYPE %s %s\n", metric.name, metric.kind)
+		for i, node := range w.Nodes {
+			fmt.Fprintf(buf, "%s{node=%q} %g\n", metric.name, node.Name, metric.value(metrics[i]))
+		}
+	}
+	return ctx.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
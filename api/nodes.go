@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"nuts-foundation/nuts-monitor/client"
+	"nuts-foundation/nuts-monitor/data"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Node contains the client and the collected data of a single monitored Nuts node
+type Node struct {
+	Name        string
+	Client      client.HTTPClient
+	DataStore   *data.Store
+	Consistency *data.ConsistencyChecker
+}
+
+// NodeOverview contains the key numbers of a single monitored node
+type NodeOverview struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Network string `json:"network,omitempty"`
+	// Status is the health status of the node: UP, DOWN or UNKNOWN
+	Status           string `json:"status"`
+	SoftwareVersion  string `json:"software_version,omitempty"`
+	PeerID           string `json:"peer_id,omitempty"`
+	TransactionCount int    `json:"tx_count"`
+	DagXor           string `json:"dag_xor,omitempty"`
+	ConnectedPeers   int    `json:"connected_peers"`
+	RootCount        int    `json:"root_count"`
+	Error            string `json:"error,omitempty"`
+}
+
+// NodesOverview compares all monitored nodes
+type NodesOverview struct {
+	Nodes []NodeOverview `json:"nodes"`
+	// Consistent is true when all nodes are up and the nodes of the same network have the same DAG
+	Consistent bool `json:"consistent"`
+}
+
+// node returns the node with the given name, or the first node if no name is given.
+func (w Wrapper) node(name *string) (Node, error) {
+	if len(w.Nodes) == 0 {
+		return Node{}, echo.NewHTTPError(http.StatusNotFound, "no nodes configured")
+	}
+	if name == nil || *name == "" {
+		return w.Nodes[0], nil
+	}
+	for _, n := range w.Nodes {
+		if n.Name == *name {
+			return n, nil
+		}
+	}
+	return Node{}, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("unknown node: %s", *name))
+}
+
+func (w Wrapper) NodesOverview(ctx context.Context, _ NodesOverviewRequestObject) (NodesOverviewResponseObject, error) {
+	overview := NodesOverview{
+		Nodes: make([]NodeOverview, len(w.Nodes)),
+	}
+
+	wg := sync.WaitGroup{}
+	for i, n := range w.Nodes {
+		wg.Add(1)
+		go func(i int, n Node) {
+			defer wg.Done()
+			overview.Nodes[i] = nodeOverview(ctx, n)
+		}(i, n)
+	}
+	wg.Wait()
+
+	overview.Consistent = consistent(overview.Nodes)
+
+	return NodesOverview200JSONResponse(overview), nil
+}
+
+// consistent returns true when all nodes are up and the nodes of the same network have the same DAG.
+// Nodes without a network aren't compared with other nodes, since the DAGs of different networks never match.
+func consistent(nodes []NodeOverview) bool {
+	dagXors := make(map[string]string)
+	for _, n := range nodes {
+		if n.Status != UP {
+			return false
+		}
+		if n.Network == "" {
+			continue
+		}
+		if dagXor, ok := dagXors[n.Network]; ok && dagXor != n.DagXor {
+			return false
+		}
+		dagXors[n.Network] = n.DagXor
+	}
+	return true
+}
+
+func nodeOverview(ctx context.Context, n Node) NodeOverview {
+	result := NodeOverview{
+		Name:    n.Name,
+		Address: n.Client.CurrentConfig().NutsNodeAddr,
+		Network: n.Client.CurrentConfig().NutsNodeNetwork,
+		Status:  "UNKNOWN",
+	}
+	if n.DataStore != nil {
+		_, rootCount := n.DataStore.GetTransactionCounts()
+		result.RootCount = int(rootCount)
+	}
+
+	health, err := n.Client.CheckHealth(ctx)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = health.Status
+
+	diagnostics, err := n.Client.Diagnostics(ctx)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.SoftwareVersion = diagnostics.Status.SoftwareVersion
+	result.PeerID = diagnostics.Network.Connections.PeerId
+	result.TransactionCount = diagnostics.Network.State.TransactionCount
+	result.DagXor = diagnostics.Network.State.DagXor
+	result.ConnectedPeers = diagnostics.Network.Connections.ConnectedPeersCount
+
+	return result
+}
@@ -21,10 +21,33 @@ package api
 import (
 	"nuts-foundation/nuts-monitor/client"
 	"nuts-foundation/nuts-monitor/client/diagnostics"
+	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/data"
+	"nuts-foundation/nuts-monitor/graph"
 )
 
 type CheckHealthResponse = diagnostics.Health
 
 type Diagnostics = diagnostics.Diagnostics
 
-type NetworkTopology = client.NetworkTopology
\ No newline at end of file
+type NetworkTopology = client.NetworkTopology
+
+type ConsistencyReport = data.ConsistencyReport
+
+type ClockSkewReport = data.ClockSkewReport
+
+type KeyReport = data.KeyReport
+
+type DIDChange = data.DIDChange
+
+type CredentialReport = data.CredentialReport
+
+type AnomalyReport = data.AnomalyReport
+
+type NetworkAnalysis = graph.Analysis
+
+type JSONGraph = client.JSONGraph
+
+type DAG = client.DAG
+
+type ReloadStatus = config.ReloadStatus
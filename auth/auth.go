@@ -0,0 +1,164 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"nuts-foundation/nuts-monitor/config"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrUnauthenticated is returned by an Authenticator when the request doesn't contain its credentials
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// userContextKey is the key of the authenticated User in the echo context
+const userContextKey = "auth.user"
+
+// Role determines what an authenticated user is allowed to do
+type Role string
+
+const (
+	// RoleViewer may only read data
+	RoleViewer Role = "viewer"
+	// RoleAdmin may read data and perform actions
+	RoleAdmin Role = "admin"
+)
+
+// ParseRole converts a configured role to a Role, an empty value results in RoleViewer
+func ParseRole(role string) (Role, error) {
+	switch Role(strings.ToLower(role)) {
+	case "", RoleViewer:
+		return RoleViewer, nil
+	case RoleAdmin:
+		return RoleAdmin, nil
+	}
+	return "", fmt.Errorf("unknown role: %s", role)
+}
+
+// User is an authenticated user
+type User struct {
+	Name string
+	Role Role
+}
+
+// Authenticator authenticates a request.
+// It returns ErrUnauthenticated if the request doesn't contain credentials for this authenticator, so the next one can be tried.
+// Any other error means the credentials are invalid.
+type Authenticator interface {
+	Authenticate(c echo.Context) (*User, error)
+	// Challenge returns the value for the WWW-Authenticate header, it may be empty
+	Challenge() string
+}
+
+// FromConfig creates the authenticators for the configured authentication methods.
+// If OIDC is configured, the OIDCAuthenticator is also returned so its routes can be registered.
+func FromConfig(ctx context.Context, c config.AuthConfig) ([]Authenticator, *OIDCAuthenticator, error) {
+	var authenticators []Authenticator
+	if len(c.Tokens) > 0 {
+		tokenAuthenticator, err := NewTokenAuthenticator(c.Tokens)
+		if err != nil {
+			return nil, nil, err
+		}
+		authenticators = append(authenticators, tokenAuthenticator)
+	}
+	if len(c.Users) > 0 {
+		basicAuthenticator, err := NewBasicAuthenticator(c.Users)
+		if err != nil {
+			return nil, nil, err
+		}
+		authenticators = append(authenticators, basicAuthenticator)
+	}
+	var oidcAuthenticator *OIDCAuthenticator
+	if c.OIDC.Enabled() {
+		var err error
+		oidcAuthenticator, err = NewOIDCAuthenticator(ctx, c.OIDC)
+		if err != nil {
+			return nil, nil, err
+		}
+		authenticators = append(authenticators, oidcAuthenticator)
+	}
+	return authenticators, oidcAuthenticator, nil
+}
+
+// Protected returns true for requests that require authentication: all routes, like the API endpoints under /web and the metrics,
+// except the status and health endpoints, the OIDC login endpoints under /auth and the static assets.
+// It uses the matched route, so it must be called by a middleware that runs after routing.
+func Protected(c echo.Context) bool {
+	switch route := c.Path(); {
+	case route == "/status", route == "/health", route == "/*":
+		return false
+	case strings.HasPrefix(route, "/auth/"):
+		return false
+	}
+	return true
+}
+
+// UserFromContext returns the authenticated user, or nil if the request isn't authenticated
+func UserFromContext(c echo.Context) *User {
+	user, _ := c.Get(userContextKey).(*User)
+	return user
+}
+
+// Middleware authenticates requests using the given authenticators, the first authenticator that accepts the credentials wins.
+// Requests that aren't authenticated are rejected with 401, viewers that try to use a method other than GET or HEAD are rejected with 403.
+// Requests for which skipper returns true are not authenticated.
+func Middleware(skipper func(c echo.Context) bool, authenticators ...Authenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skipper != nil && skipper(c) {
+				return next(c)
+			}
+
+			user, err := authenticate(c, authenticators)
+			if err != nil {
+				for _, a := range authenticators {
+					if challenge := a.Challenge(); challenge != "" {
+						c.Response().Header().Add(echo.HeaderWWWAuthenticate, challenge)
+					}
+				}
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			if user.Role != RoleAdmin && c.Request().Method != http.MethodGet && c.Request().Method != http.MethodHead {
+				return echo.NewHTTPError(http.StatusForbidden, "admin role required")
+			}
+
+			c.Set(userContextKey, user)
+			return next(c)
+		}
+	}
+}
+
+func authenticate(c echo.Context, authenticators []Authenticator) (*User, error) {
+	for _, a := range authenticators {
+		user, err := a.Authenticate(c)
+		if errors.Is(err, ErrUnauthenticated) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	return nil, ErrUnauthenticated
+}
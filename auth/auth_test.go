@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"nuts-foundation/nuts-monitor/config"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordHash is the bcrypt hash of "secret"
+const passwordHash = "$2a$04$R/K3hKfR2HYPobOu9nPK5OfkHV9BQhU/4uBGh8mByeyKpgZkSjD/a"
+
+var testAuthConfig = config.AuthConfig{
+	Tokens: []config.TokenConfig{
+		{Name: "viewer", Token: "viewer-token"},
+		{Name: "admin", Token: "admin-token", Role: "admin"},
+	},
+	Users: []config.UserConfig{
+		{Username: "alice", PasswordHash: passwordHash},
+	},
+}
+
+// newTestServer returns an echo server with authenticated /web/test and /metrics endpoints that return the user name
+func newTestServer(authenticators []Authenticator) *echo.Echo {
+	e := echo.New()
+	e.Use(Middleware(func(c echo.Context) bool { return !Protected(c) }, authenticators...))
+	handler := func(c echo.Context) error {
+		user := UserFromContext(c)
+		if user == nil {
+			return c.String(http.StatusOK, "")
+		}
+		return c.String(http.StatusOK, user.Name)
+	}
+	e.GET("/web/test", handler)
+	e.POST("/web/test", handler)
+	e.GET("/metrics", handler)
+	e.GET("/status", handler)
+	e.GET("/*", handler)
+	return e
+}
+
+func TestMiddleware(t *testing.T) {
+	authenticators, _, err := FromConfig(context.Background(), testAuthConfig)
+	require.NoError(t, err)
+	e := newTestServer(authenticators)
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		modifier     func(r *http.Request)
+		expectedCode int
+		expectedBody string
+	}{
+		{"public endpoint", http.MethodGet, "/status", nil, http.StatusOK, ""},
+		{"public assets", http.MethodGet, "/index.html", nil, http.StatusOK, ""},
+		{"metrics without credentials", http.MethodGet, "/metrics", nil, http.StatusUnauthorized, ""},
+		{"metrics with credentials", http.MethodGet, "/metrics", bearer("viewer-token"), http.StatusOK, "viewer"},
+		{"no credentials", http.MethodGet, "/web/test", nil, http.StatusUnauthorized, ""},
+		{"viewer token", http.MethodGet, "/web/test", bearer("viewer-token"), http.StatusOK, "viewer"},
+		{"unknown token", http.MethodGet, "/web/test", bearer("other"), http.StatusUnauthorized, ""},
+		{"basic auth", http.MethodGet, "/web/test", basic("alice", "secret"), http.StatusOK, "alice"},
+		{"wrong password", http.MethodGet, "/web/test", basic("alice", "wrong"), http.StatusUnauthorized, ""},
+		{"unknown user", http.MethodGet, "/web/test", basic("bob", "secret"), http.StatusUnauthorized, ""},
+		{"viewer can't POST", http.MethodPost, "/web/test", bearer("viewer-token"), http.StatusForbidden, ""},
+		{"admin can POST", http.MethodPost, "/web/test", bearer("admin-token"), http.StatusOK, "admin"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := httptest.NewRequest(testCase.method, testCase.path, nil)
+			if testCase.modifier != nil {
+				testCase.modifier(request)
+			}
+			recorder := httptest.NewRecorder()
+
+			e.ServeHTTP(recorder, request)
+
+			assert.Equal(t, testCase.expectedCode, recorder.Code)
+			if testCase.expectedCode == http.StatusOK {
+				assert.Equal(t, testCase.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+
+	t.Run("challenge", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+
+		e.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/web/test", nil))
+
+		assert.Equal(t, []string{`Bearer realm="nuts-monitor"`, `Basic realm="nuts-monitor"`}, recorder.Header().Values(echo.HeaderWWWAuthenticate))
+	})
+}
+
+func TestFromConfig(t *testing.T) {
+	t.Run("invalid role", func(t *testing.T) {
+		_, _, err := FromConfig(context.Background(), config.AuthConfig{Tokens: []config.TokenConfig{{Token: "t", Role: "root"}}})
+
+		assert.EqualError(t, err, "auth.tokens[0]: unknown role: root")
+	})
+	t.Run("missing token", func(t *testing.T) {
+		_, _, err := FromConfig(context.Background(), config.AuthConfig{Tokens: []config.TokenConfig{{Name: "empty"}}})
+
+		assert.EqualError(t, err, "auth.tokens[0]: token is required")
+	})
+	t.Run("invalid password hash", func(t *testing.T) {
+		_, _, err := FromConfig(context.Background(), config.AuthConfig{Users: []config.UserConfig{{Username: "alice", PasswordHash: "secret"}}})
+
+		assert.ErrorContains(t, err, "auth.users[0]: invalid bcrypt password hash")
+	})
+}
+
+func TestBasicAuthenticator(t *testing.T) {
+	t.Run("unknown users are compared with a hash of the same cost", func(t *testing.T) {
+		authenticator, err := NewBasicAuthenticator(testAuthConfig.Users)
+		require.NoError(t, err)
+
+		cost, err := bcrypt.Cost(authenticator.dummyHash)
+
+		require.NoError(t, err)
+		assert.Equal(t, 4, cost)
+	})
+}
+
+func bearer(token string) func(r *http.Request) {
+	return func(r *http.Request) {
+		r.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+}
+
+func basic(username, password string) func(r *http.Request) {
+	return func(r *http.Request) {
+		r.SetBasicAuth(username, password)
+	}
+}
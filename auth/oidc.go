@@ -0,0 +1,324 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"nuts-foundation/nuts-monitor/config"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+const (
+	// SessionCookie is the name of the cookie that contains the session after an OIDC login
+	SessionCookie = "nuts-monitor-session"
+	// stateCookie contains the state and nonce during the authorization code flow
+	stateCookie = "nuts-monitor-oidc"
+	// sessionLifetime is the validity of a session
+	sessionLifetime = 8 * time.Hour
+	// stateLifetime is the time a user has to log in at the OpenID provider
+	stateLifetime = 10 * time.Minute
+)
+
+// providerMetadata contains the fields of the OpenID provider metadata used by the monitor
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCAuthenticator logs in users using the OpenID Connect authorization code flow.
+// After a successful login the user receives a session cookie signed by the monitor.
+type OIDCAuthenticator struct {
+	config     config.OIDCConfig
+	metadata   providerMetadata
+	httpClient *http.Client
+	// sessionKey signs the session cookies, sessions are invalidated when the monitor restarts
+	sessionKey []byte
+}
+
+// NewOIDCAuthenticator creates an OIDCAuthenticator, it retrieves the provider metadata from the issuer
+func NewOIDCAuthenticator(ctx context.Context, c config.OIDCConfig) (*OIDCAuthenticator, error) {
+	if c.ClientID == "" {
+		return nil, errors.New("auth.oidc.clientid is required")
+	}
+	if c.RedirectURL == "" {
+		return nil, errors.New("auth.oidc.redirecturl is required")
+	}
+	if c.RolesClaim == "" {
+		c.RolesClaim = "roles"
+	}
+	if c.AdminRole == "" {
+		c.AdminRole = string(RoleAdmin)
+	}
+
+	result := &OIDCAuthenticator{
+		config:     c,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		sessionKey: make([]byte, 32),
+	}
+	if _, err := rand.Read(result.sessionKey); err != nil {
+		return nil, err
+	}
+	if err := result.discover(ctx); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	return result, nil
+}
+
+func (oa *OIDCAuthenticator) discover(ctx context.Context) error {
+	discoveryURL := strings.TrimSuffix(oa.config.Issuer, "/") + "/.well-known/openid-configuration"
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return err
+	}
+	response, err := oa.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code from %s: %d", discoveryURL, response.StatusCode)
+	}
+	if err := json.NewDecoder(response.Body).Decode(&oa.metadata); err != nil {
+		return err
+	}
+	if oa.metadata.Issuer != oa.config.Issuer {
+		return fmt.Errorf("issuer mismatch: configured %s, provider returned %s", oa.config.Issuer, oa.metadata.Issuer)
+	}
+	if oa.metadata.AuthorizationEndpoint == "" || oa.metadata.TokenEndpoint == "" || oa.metadata.JWKSURI == "" {
+		return errors.New("provider metadata is incomplete")
+	}
+	return nil
+}
+
+// RegisterRoutes adds the login, callback and logout endpoints under /auth
+func (oa *OIDCAuthenticator) RegisterRoutes(e *echo.Echo) {
+	e.GET("/auth/login", oa.Login)
+	e.GET("/auth/callback", oa.Callback)
+	e.GET("/auth/logout", oa.Logout)
+}
+
+// Login redirects the user to the authorization endpoint of the OpenID provider
+func (oa *OIDCAuthenticator) Login(c echo.Context) error {
+	state, err := randomString()
+	if err != nil {
+		return err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return err
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     stateCookie,
+		Value:    state + "." + nonce,
+		Path:     "/auth",
+		MaxAge:   int(stateLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   c.IsTLS(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", oa.config.ClientID)
+	query.Set("redirect_uri", oa.config.RedirectURL)
+	query.Set("scope", "openid profile email")
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	return c.Redirect(http.StatusFound, oa.metadata.AuthorizationEndpoint+"?"+query.Encode())
+}
+
+// Callback handles the redirect from the OpenID provider, it exchanges the code for an ID token and starts a session
+func (oa *OIDCAuthenticator) Callback(c echo.Context) error {
+	cookie, err := c.Cookie(stateCookie)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing login state")
+	}
+	state, nonce, _ := strings.Cut(cookie.Value, ".")
+	if state == "" || nonce == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid login state")
+	}
+	if subtle.ConstantTimeCompare([]byte(c.QueryParam("state")), []byte(state)) != 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid login state")
+	}
+	if errorCode := c.QueryParam("error"); errorCode != "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, fmt.Sprintf("login failed: %s", errorCode))
+	}
+
+	idToken, err := oa.exchange(c.Request().Context(), c.QueryParam("code"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, fmt.Sprintf("login failed: %s", err))
+	}
+	user, err := oa.verifyIDToken(c.Request().Context(), idToken, nonce)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, fmt.Sprintf("login failed: %s", err))
+	}
+	session, err := oa.createSession(*user)
+	if err != nil {
+		return err
+	}
+
+	c.SetCookie(&http.Cookie{Name: stateCookie, Path: "/auth", MaxAge: -1})
+	c.SetCookie(&http.Cookie{
+		Name:     SessionCookie,
+		Value:    session,
+		Path:     "/",
+		MaxAge:   int(sessionLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   c.IsTLS(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return c.Redirect(http.StatusFound, "/")
+}
+
+// Logout removes the session cookie
+func (oa *OIDCAuthenticator) Logout(c echo.Context) error {
+	c.SetCookie(&http.Cookie{Name: SessionCookie, Path: "/", MaxAge: -1})
+	return c.Redirect(http.StatusFound, "/")
+}
+
+// Authenticate checks the session cookie
+func (oa *OIDCAuthenticator) Authenticate(c echo.Context) (*User, error) {
+	cookie, err := c.Cookie(SessionCookie)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	token, err := jwt.ParseString(cookie.Value, jwt.WithVerify(jwa.HS256, oa.sessionKey), jwt.WithValidate(true))
+	if err != nil {
+		return nil, fmt.Errorf("invalid session: %w", err)
+	}
+	role, _ := token.Get("role")
+	roleString, _ := role.(string)
+	return &User{Name: token.Subject(), Role: Role(roleString)}, nil
+}
+
+func (oa *OIDCAuthenticator) Challenge() string {
+	return ""
+}
+
+// exchange exchanges the authorization code for an ID token at the token endpoint
+func (oa *OIDCAuthenticator) exchange(ctx context.Context, code string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", oa.config.RedirectURL)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, oa.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(url.QueryEscape(oa.config.ClientID), url.QueryEscape(oa.config.ClientSecret))
+
+	response, err := oa.httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return "", fmt.Errorf("token endpoint returned %d: %s", response.StatusCode, string(body))
+	}
+	tokenResponse := struct {
+		IDToken string `json:"id_token"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&tokenResponse); err != nil {
+		return "", err
+	}
+	if tokenResponse.IDToken == "" {
+		return "", errors.New("token response doesn't contain an id_token")
+	}
+	return tokenResponse.IDToken, nil
+}
+
+// verifyIDToken checks the signature and claims of the ID token and converts it to a User
+func (oa *OIDCAuthenticator) verifyIDToken(ctx context.Context, idToken string, nonce string) (*User, error) {
+	keySet, err := jwk.Fetch(ctx, oa.metadata.JWKSURI, jwk.WithHTTPClient(oa.httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch provider keys: %w", err)
+	}
+	token, err := jwt.ParseString(idToken,
+		jwt.WithKeySet(keySet),
+		jwt.InferAlgorithmFromKey(true),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(oa.config.Issuer),
+		jwt.WithAudience(oa.config.ClientID),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if tokenNonce, _ := token.Get("nonce"); tokenNonce != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+
+	user := User{Name: token.Subject(), Role: RoleViewer}
+	claims := token.PrivateClaims()
+	for _, claim := range []string{"preferred_username", "email"} {
+		if name, ok := claims[claim].(string); ok && name != "" {
+			user.Name = name
+			break
+		}
+	}
+	if roles, ok := claims[oa.config.RolesClaim].([]interface{}); ok {
+		for _, r := range roles {
+			if r == oa.config.AdminRole {
+				user.Role = RoleAdmin
+			}
+		}
+	}
+	return &user, nil
+}
+
+// createSession creates a signed session token for the user
+func (oa *OIDCAuthenticator) createSession(user User) (string, error) {
+	token := jwt.New()
+	now := time.Now()
+	_ = token.Set(jwt.SubjectKey, user.Name)
+	_ = token.Set(jwt.IssuedAtKey, now)
+	_ = token.Set(jwt.ExpirationKey, now.Add(sessionLifetime))
+	_ = token.Set("role", string(user.Role))
+	signed, err := jwt.Sign(token, jwa.HS256, oa.sessionKey)
+	if err != nil {
+		return "", err
+	}
+	return string(signed), nil
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
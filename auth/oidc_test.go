@@ -0,0 +1,214 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"nuts-foundation/nuts-monitor/config"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProvider is a minimal OpenID provider that logs in every user without asking for credentials
+type testProvider struct {
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+	// claims are added to the issued ID tokens
+	claims map[string]interface{}
+	// issuer overrides the issuer in the ID token
+	issuer string
+	nonce  string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	provider := &testProvider{key: key, claims: map[string]interface{}{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(providerMetadata{
+			Issuer:                provider.server.URL,
+			AuthorizationEndpoint: provider.server.URL + "/authorize",
+			TokenEndpoint:         provider.server.URL + "/token",
+			JWKSURI:               provider.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		provider.nonce = r.URL.Query().Get("nonce")
+		redirect := r.URL.Query().Get("redirect_uri") + "?" + url.Values{
+			"code":  []string{"test-code"},
+			"state": []string{r.URL.Query().Get("state")},
+		}.Encode()
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, _ := r.BasicAuth()
+		if clientID != "monitor" || clientSecret != "client-secret" || r.FormValue("code") != "test-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": provider.idToken(t)})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		publicKey, _ := jwk.New(provider.key.PublicKey)
+		_ = publicKey.Set(jwk.KeyIDKey, "test")
+		_ = publicKey.Set(jwk.AlgorithmKey, jwa.ES256)
+		set := jwk.NewSet()
+		set.Add(publicKey)
+		_ = json.NewEncoder(w).Encode(set)
+	})
+	provider.server = httptest.NewServer(mux)
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+func (tp *testProvider) idToken(t *testing.T) string {
+	token := jwt.New()
+	issuer := tp.server.URL
+	if tp.issuer != "" {
+		issuer = tp.issuer
+	}
+	_ = token.Set(jwt.IssuerKey, issuer)
+	_ = token.Set(jwt.SubjectKey, "user-1")
+	_ = token.Set(jwt.AudienceKey, "monitor")
+	_ = token.Set(jwt.IssuedAtKey, time.Now())
+	_ = token.Set(jwt.ExpirationKey, time.Now().Add(time.Minute))
+	_ = token.Set("nonce", tp.nonce)
+	for k, v := range tp.claims {
+		_ = token.Set(k, v)
+	}
+	key, _ := jwk.New(tp.key)
+	_ = key.Set(jwk.KeyIDKey, "test")
+	signed, err := jwt.Sign(token, jwa.ES256, key)
+	require.NoError(t, err)
+	return string(signed)
+}
+
+// login runs the authorization code flow and returns the response of a request to /web/test
+func login(t *testing.T, provider *testProvider) *http.Response {
+	e := echo.New()
+	monitor := httptest.NewServer(e)
+	t.Cleanup(monitor.Close)
+
+	authenticator, err := NewOIDCAuthenticator(context.Background(), config.OIDCConfig{
+		Issuer:       provider.server.URL,
+		ClientID:     "monitor",
+		ClientSecret: "client-secret",
+		RedirectURL:  monitor.URL + "/auth/callback",
+	})
+	require.NoError(t, err)
+	authenticator.RegisterRoutes(e)
+	e.Use(Middleware(func(c echo.Context) bool { return !Protected(c) }, authenticator))
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/web/test", func(c echo.Context) error {
+		return c.String(http.StatusOK, UserFromContext(c).Name)
+	})
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	resp, err := client.Get(monitor.URL + "/auth/login")
+	require.NoError(t, err)
+	if resp.StatusCode != http.StatusOK {
+		return resp
+	}
+	resp, err = client.Get(monitor.URL + "/web/test")
+	require.NoError(t, err)
+	return resp
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	t.Run("login", func(t *testing.T) {
+		provider := newTestProvider(t)
+		provider.claims["preferred_username"] = "alice"
+
+		resp := login(t, provider)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "alice", string(body))
+	})
+	t.Run("invalid issuer", func(t *testing.T) {
+		provider := newTestProvider(t)
+		provider.issuer = "https://evil.example.com"
+
+		resp := login(t, provider)
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+	t.Run("roles", func(t *testing.T) {
+		provider := newTestProvider(t)
+		provider.claims["roles"] = []string{"admin"}
+		authenticator := &OIDCAuthenticator{config: config.OIDCConfig{Issuer: provider.server.URL, ClientID: "monitor", RolesClaim: "roles", AdminRole: "admin"}}
+		authenticator.metadata.JWKSURI = provider.server.URL + "/jwks"
+		authenticator.httpClient = http.DefaultClient
+
+		user, err := authenticator.verifyIDToken(context.Background(), provider.idToken(t), "")
+
+		require.NoError(t, err)
+		assert.Equal(t, User{Name: "user-1", Role: RoleAdmin}, *user)
+	})
+	t.Run("nonce mismatch", func(t *testing.T) {
+		provider := newTestProvider(t)
+		provider.nonce = "other"
+		authenticator := &OIDCAuthenticator{config: config.OIDCConfig{Issuer: provider.server.URL, ClientID: "monitor"}}
+		authenticator.metadata.JWKSURI = provider.server.URL + "/jwks"
+		authenticator.httpClient = http.DefaultClient
+
+		_, err := authenticator.verifyIDToken(context.Background(), provider.idToken(t), "nonce")
+
+		assert.EqualError(t, err, "nonce mismatch")
+	})
+	t.Run("empty login state", func(t *testing.T) {
+		authenticator := &OIDCAuthenticator{}
+		for _, value := range []string{"", ".", "state", ".nonce", "state."} {
+			request := httptest.NewRequest(http.MethodGet, "/auth/callback?code=code", nil)
+			request.AddCookie(&http.Cookie{Name: stateCookie, Value: value})
+
+			err := authenticator.Callback(echo.New().NewContext(request, httptest.NewRecorder()))
+
+			var httpErr *echo.HTTPError
+			require.ErrorAs(t, err, &httpErr, value)
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code, value)
+		}
+	})
+	t.Run("discovery fails", func(t *testing.T) {
+		_, err := NewOIDCAuthenticator(context.Background(), config.OIDCConfig{Issuer: "http://localhost:1", ClientID: "monitor", RedirectURL: "http://localhost/auth/callback"})
+
+		assert.ErrorContains(t, err, "OIDC discovery failed")
+	})
+}
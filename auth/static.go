@@ -0,0 +1,133 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"nuts-foundation/nuts-monitor/config"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// errInvalidCredentials is returned when credentials are present but incorrect
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// TokenAuthenticator authenticates requests with a static bearer token
+type TokenAuthenticator struct {
+	tokens []config.TokenConfig
+	roles  []Role
+}
+
+// NewTokenAuthenticator creates a TokenAuthenticator for the given tokens
+func NewTokenAuthenticator(tokens []config.TokenConfig) (*TokenAuthenticator, error) {
+	result := &TokenAuthenticator{tokens: tokens}
+	for i, t := range tokens {
+		if len(t.Token) == 0 {
+			return nil, fmt.Errorf("auth.tokens[%d]: token is required", i)
+		}
+		role, err := ParseRole(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("auth.tokens[%d]: %w", i, err)
+		}
+		result.roles = append(result.roles, role)
+	}
+	return result, nil
+}
+
+func (ta *TokenAuthenticator) Authenticate(c echo.Context) (*User, error) {
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, ErrUnauthenticated
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	for i, t := range ta.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) == 1 {
+			return &User{Name: t.Name, Role: ta.roles[i]}, nil
+		}
+	}
+	// the bearer token may belong to another authenticator
+	return nil, ErrUnauthenticated
+}
+
+func (ta *TokenAuthenticator) Challenge() string {
+	return `Bearer realm="nuts-monitor"`
+}
+
+// BasicAuthenticator authenticates requests using HTTP basic authentication with bcrypt hashed passwords
+type BasicAuthenticator struct {
+	users map[string]config.UserConfig
+	roles map[string]Role
+	// dummyHash is compared for unknown users, so the response time doesn't reveal which usernames exist
+	dummyHash []byte
+}
+
+// NewBasicAuthenticator creates a BasicAuthenticator for the given users
+func NewBasicAuthenticator(users []config.UserConfig) (*BasicAuthenticator, error) {
+	result := &BasicAuthenticator{users: map[string]config.UserConfig{}, roles: map[string]Role{}}
+	cost := bcrypt.DefaultCost
+	for i, u := range users {
+		if len(u.Username) == 0 {
+			return nil, fmt.Errorf("auth.users[%d]: username is required", i)
+		}
+		userCost, err := bcrypt.Cost([]byte(u.PasswordHash))
+		if err != nil {
+			return nil, fmt.Errorf("auth.users[%d]: invalid bcrypt password hash: %w", i, err)
+		}
+		if i == 0 {
+			cost = userCost
+		}
+		role, err := ParseRole(u.Role)
+		if err != nil {
+			return nil, fmt.Errorf("auth.users[%d]: %w", i, err)
+		}
+		result.users[u.Username] = u
+		result.roles[u.Username] = role
+	}
+	// the dummy hash takes as long to compare as the hashes of the users
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("unknown user"), cost)
+	if err != nil {
+		return nil, err
+	}
+	result.dummyHash = dummyHash
+	return result, nil
+}
+
+func (ba *BasicAuthenticator) Authenticate(c echo.Context) (*User, error) {
+	username, password, ok := c.Request().BasicAuth()
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	user, ok := ba.users[username]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(ba.dummyHash, []byte(password))
+		return nil, errInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, errInvalidCredentials
+	}
+	return &User{Name: username, Role: ba.roles[username]}, nil
+}
+
+func (ba *BasicAuthenticator) Challenge() string {
+	return `Basic realm="nuts-monitor"`
+}
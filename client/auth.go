@@ -21,6 +21,7 @@ package client
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"errors"
 	"fmt"
@@ -29,55 +30,170 @@ import (
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jwt"
 	"nuts-foundation/nuts-monitor/config"
+	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
-// createTokenGenerator generates valid API tokens for the Nuts node and signs them with the private key
-func createTokenGenerator(config config.Config) AuthorizationTokenGenerator {
+const (
+	// defaultTokenLifetime is the validity of the API tokens for the Nuts node if none is configured
+	defaultTokenLifetime = 5 * time.Minute
+	// tokenRefreshMargin is the remaining validity at which a cached token is replaced by a new one,
+	// it prevents tokens from expiring while a request is in flight
+	tokenRefreshMargin = 30 * time.Second
+)
+
+// reservedClaims are set by the monitor and can't be replaced by configured claims
+var reservedClaims = []string{jwt.IssuerKey, jwt.SubjectKey, jwt.AudienceKey, jwt.IssuedAtKey, jwt.NotBeforeKey, jwt.ExpirationKey, jwt.JwtIDKey}
+
+// createTokenGenerator returns the generator for the configured API security:
+// a pre-issued token read from nutsnodeapitokenfile or tokens signed with the private key.
+// It returns an error if the key or token options are invalid.
+func createTokenGenerator(config config.Config) (AuthorizationTokenGenerator, error) {
+	if config.NutsNodeAPITokenFile != "" {
+		return newFileTokenGenerator(config.NutsNodeAPITokenFile), nil
+	}
+	return newCachingTokenGenerator(config, time.Now)
+}
+
+// newCachingTokenGenerator generates valid API tokens for the Nuts node and signs them with the private key.
+// Tokens are cached until shortly before they expire.
+func newCachingTokenGenerator(config config.Config, now func() time.Time) (AuthorizationTokenGenerator, error) {
+	// the key only needs to be converted once
+	key, err := jwkKey(config.ApiKey, config.NutsNodeAPIAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	for _, claim := range reservedClaims {
+		if _, ok := config.NutsNodeAPIClaims[claim]; ok {
+			return nil, fmt.Errorf("claim %s can't be configured", claim)
+		}
+	}
+	lifetime := config.NutsNodeAPITokenLifetime
+	if lifetime <= 0 {
+		lifetime = defaultTokenLifetime
+	}
+	// short-lived tokens are renewed halfway their lifetime
+	refreshMargin := tokenRefreshMargin
+	if refreshMargin > lifetime/2 {
+		refreshMargin = lifetime / 2
+	}
+
+	var mutex sync.Mutex
+	var cached string
+	var expires time.Time
+
 	return func() (string, error) {
-		key, err := jwkKey(config.ApiKey)
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		issuedAt := now()
+		if cached != "" && expires.Sub(issuedAt) > refreshMargin {
+			return cached, nil
+		}
+		token, err := signToken(config, key, issuedAt, lifetime)
 		if err != nil {
 			return "", err
 		}
+		cached = token
+		expires = issuedAt.Add(lifetime)
+		return cached, nil
+	}, nil
+}
 
-		issuedAt := time.Now()
-		notBefore := issuedAt
-		expires := notBefore.Add(time.Second * time.Duration(5))
-		token, err := jwt.NewBuilder().
-			Issuer(config.NutsNodeAPIUser).
-			Subject(config.NutsNodeAPIUser).
-			Audience([]string{config.NutsNodeAPIAudience}).
-			IssuedAt(issuedAt).
-			NotBefore(notBefore).
-			Expiration(expires).
-			JwtID(uuid.New().String()).
-			Build()
-
-		bytes, err := jwt.Sign(token, jwa.SignatureAlgorithm(key.Algorithm()), key)
+// signToken creates a token that is valid for the given lifetime from issuedAt
+func signToken(config config.Config, key jwk.Key, issuedAt time.Time, lifetime time.Duration) (string, error) {
+	notBefore := issuedAt
+	expires := notBefore.Add(lifetime)
+	builder := jwt.NewBuilder().
+		Issuer(config.NutsNodeAPIUser).
+		Subject(config.NutsNodeAPIUser).
+		Audience([]string{config.NutsNodeAPIAudience}).
+		IssuedAt(issuedAt).
+		NotBefore(notBefore).
+		Expiration(expires).
+		JwtID(uuid.New().String())
+	for name, value := range config.NutsNodeAPIClaims {
+		builder = builder.Claim(name, value)
+	}
+	token, err := builder.Build()
+	if err != nil {
+		return "", err
+	}
+
+	bytes, err := jwt.Sign(token, jwa.SignatureAlgorithm(key.Algorithm()), key)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// newFileTokenGenerator returns the pre-issued token from the given file.
+// The file is read again when it's modified, so the token can be replaced without restarting the monitor.
+func newFileTokenGenerator(path string) AuthorizationTokenGenerator {
+	var mutex sync.Mutex
+	var cached string
+	var modTime time.Time
+
+	return func() (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		info, err := os.Stat(path)
 		if err != nil {
-			return "", err
+			return "", fmt.Errorf("unable to read token file: %w", err)
+		}
+		if cached != "" && info.ModTime().Equal(modTime) {
+			return cached, nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("unable to read token file: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", fmt.Errorf("token file %s is empty", path)
 		}
-		return string(bytes), nil
+		cached = token
+		modTime = info.ModTime()
+		return cached, nil
 	}
 }
 
-func jwkKey(signer crypto.Signer) (key jwk.Key, err error) {
+// jwkKey converts the signer to a JWK with the given algorithm. If the algorithm is empty, a default for the key type is used.
+func jwkKey(signer crypto.Signer, algorithm string) (key jwk.Key, err error) {
+	if signer == nil {
+		return nil, errors.New("no signing private key")
+	}
 	// ssh key format
 	key, err = jwk.New(signer)
 	if err != nil {
 		return nil, err
 	}
 
+	var alg jwa.SignatureAlgorithm
 	switch k := signer.(type) {
 	case *rsa.PrivateKey:
-		key.Set(jwk.AlgorithmKey, jwa.PS512)
+		alg, err = rsaAlg(algorithm)
 	case *ecdsa.PrivateKey:
-		var alg jwa.SignatureAlgorithm
 		alg, err = ecAlg(k)
-		key.Set(jwk.AlgorithmKey, alg)
+		if err == nil && algorithm != "" && algorithm != alg.String() {
+			err = fmt.Errorf("algorithm %s can't be used with a %d bits EC key, use %s", algorithm, k.Params().BitSize, alg)
+		}
+	case ed25519.PrivateKey:
+		alg = jwa.EdDSA
+		if algorithm != "" && algorithm != alg.String() {
+			err = fmt.Errorf("algorithm %s can't be used with an Ed25519 key, use %s", algorithm, alg)
+		}
 	default:
 		err = fmt.Errorf("unsupported signing private key: %T", k)
-		return
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = key.Set(jwk.AlgorithmKey, alg); err != nil {
+		return nil, err
 	}
 
 	err = jwk.AssignKeyID(key)
@@ -85,6 +201,17 @@ func jwkKey(signer crypto.Signer) (key jwk.Key, err error) {
 	return
 }
 
+// rsaAlg returns the algorithm for RSA keys, PS512 unless another RSA algorithm is given
+func rsaAlg(algorithm string) (jwa.SignatureAlgorithm, error) {
+	if algorithm == "" {
+		return jwa.PS512, nil
+	}
+	switch alg := jwa.SignatureAlgorithm(algorithm); alg {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+		return alg, nil
+	}
+	return "", fmt.Errorf("algorithm %s can't be used with an RSA key", algorithm)
+}
 func ecAlg(key *ecdsa.PrivateKey) (alg jwa.SignatureAlgorithm, err error) {
 	alg, err = ecAlgUsingPublicKey(key.PublicKey)
 	return
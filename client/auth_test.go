@@ -0,0 +1,225 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"nuts-foundation/nuts-monitor/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func apiKeyConfig(t testing.TB) config.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return config.Config{
+		NutsNodeAPIUser:     "monitor",
+		NutsNodeAPIAudience: "nuts-node",
+		ApiKey:              key,
+	}
+}
+
+func TestCreateTokenGenerator(t *testing.T) {
+	cfg := apiKeyConfig(t)
+	now := time.Now()
+	generator, err := newCachingTokenGenerator(cfg, func() time.Time { return now })
+	require.NoError(t, err)
+
+	first, err := generator()
+	require.NoError(t, err)
+
+	t.Run("token contents", func(t *testing.T) {
+		token, err := jwt.ParseString(first)
+
+		require.NoError(t, err)
+		assert.Equal(t, "monitor", token.Issuer())
+		assert.Equal(t, []string{"nuts-node"}, token.Audience())
+		assert.Equal(t, now.Add(defaultTokenLifetime).Unix(), token.Expiration().Unix())
+		assert.NotEmpty(t, token.JwtID())
+	})
+	t.Run("cached", func(t *testing.T) {
+		now = now.Add(defaultTokenLifetime - tokenRefreshMargin - time.Second)
+
+		token, err := generator()
+
+		require.NoError(t, err)
+		assert.Equal(t, first, token)
+	})
+	t.Run("renewed shortly before expiry", func(t *testing.T) {
+		now = now.Add(2 * time.Second)
+
+		token, err := generator()
+
+		require.NoError(t, err)
+		assert.NotEqual(t, first, token)
+	})
+	t.Run("missing key", func(t *testing.T) {
+		_, err := createTokenGenerator(config.Config{})
+
+		assert.EqualError(t, err, "no signing private key")
+	})
+	t.Run("lifetime and claims", func(t *testing.T) {
+		cfg := apiKeyConfig(t)
+		cfg.NutsNodeAPITokenLifetime = 10 * time.Second
+		cfg.NutsNodeAPIClaims = map[string]interface{}{"tenant": "example"}
+		now := time.Now()
+		generator, err := newCachingTokenGenerator(cfg, func() time.Time { return now })
+		require.NoError(t, err)
+
+		first, err := generator()
+
+		require.NoError(t, err)
+		token, err := jwt.ParseString(first)
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(10*time.Second).Unix(), token.Expiration().Unix())
+		tenant, _ := token.Get("tenant")
+		assert.Equal(t, "example", tenant)
+
+		// short-lived tokens are renewed halfway their lifetime
+		now = now.Add(6 * time.Second)
+		second, err := generator()
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+	t.Run("standard claims can't be replaced", func(t *testing.T) {
+		cfg := apiKeyConfig(t)
+		cfg.NutsNodeAPIClaims = map[string]interface{}{"iss": "someone else"}
+
+		_, err := createTokenGenerator(cfg)
+
+		assert.EqualError(t, err, "claim iss can't be configured")
+	})
+}
+
+func TestJwkKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		signer    crypto.Signer
+		algorithm string
+		expected  jwa.SignatureAlgorithm
+		err       string
+	}{
+		{name: "RSA default", signer: rsaKey, expected: jwa.PS512},
+		{name: "RSA configured", signer: rsaKey, algorithm: "RS256", expected: jwa.RS256},
+		{name: "RSA invalid", signer: rsaKey, algorithm: "ES256", err: "algorithm ES256 can't be used with an RSA key"},
+		{name: "EC default", signer: ecKey, expected: jwa.ES384},
+		{name: "EC configured", signer: ecKey, algorithm: "ES384", expected: jwa.ES384},
+		{name: "EC invalid", signer: ecKey, algorithm: "ES256", err: "algorithm ES256 can't be used with a 384 bits EC key, use ES384"},
+		{name: "Ed25519 default", signer: edKey, expected: jwa.EdDSA},
+		{name: "Ed25519 invalid", signer: edKey, algorithm: "PS512", err: "algorithm PS512 can't be used with an Ed25519 key, use EdDSA"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := jwkKey(test.signer, test.algorithm)
+
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected.String(), key.Algorithm())
+			// the signed token must verify with the public key
+			token, err := signToken(config.Config{NutsNodeAPIUser: "monitor"}, key, time.Now(), time.Minute)
+			require.NoError(t, err)
+			_, err = jws.Verify([]byte(token), test.expected, test.signer.Public())
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFileTokenGenerator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	generator, err := createTokenGenerator(config.Config{NutsNodeAPITokenFile: path})
+	require.NoError(t, err)
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := generator()
+
+		assert.ErrorContains(t, err, "unable to read token file")
+	})
+	t.Run("token from file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("first-token\n"), 0600))
+
+		token, err := generator()
+
+		require.NoError(t, err)
+		assert.Equal(t, "first-token", token)
+	})
+	t.Run("reloaded when modified", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("second-token"), 0600))
+		later := time.Now().Add(time.Second)
+		require.NoError(t, os.Chtimes(path, later, later))
+
+		token, err := generator()
+
+		require.NoError(t, err)
+		assert.Equal(t, "second-token", token)
+	})
+	t.Run("empty file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, nil, 0600))
+		later := time.Now().Add(2 * time.Second)
+		require.NoError(t, os.Chtimes(path, later, later))
+
+		_, err := generator()
+
+		assert.ErrorContains(t, err, "is empty")
+	})
+}
+
+// BenchmarkTokenGenerator compares signing a token for every request to reusing the cached token
+func BenchmarkTokenGenerator(b *testing.B) {
+	cfg := apiKeyConfig(b)
+
+	b.Run("sign per request", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			key, _ := jwkKey(cfg.ApiKey, "")
+			if _, err := signToken(cfg, key, time.Now(), defaultTokenLifetime); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		generator, _ := createTokenGenerator(cfg)
+		for i := 0; i < b.N; i++ {
+			if _, err := generator(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -20,49 +20,161 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"nuts-foundation/nuts-monitor/client/diagnostics"
 	"nuts-foundation/nuts-monitor/client/network"
 	"nuts-foundation/nuts-monitor/client/vdr"
 	"nuts-foundation/nuts-monitor/config"
+	"reflect"
+	"sync/atomic"
 )
 
-// HTTPClient holds the server address and other basic settings for the http client
+// HTTPClient holds the server address and other basic settings for the http client.
+// Clients created with NewHTTPClient share their connections and API tokens between calls,
+// the zero value creates a new HTTP client for every call.
 type HTTPClient struct {
+	// Config is the config the client was created with, CurrentConfig also reflects changes made by Reload
 	Config config.Config
+	shared *sharedClients
 }
 
-func (hb HTTPClient) networkClient() network.ClientInterface {
-	addr := hb.Config.NutsNodeInternalAddr
-	if addr == "" {
-		addr = hb.Config.NutsNodeAddr
+// sharedClients is shared by all copies of a HTTPClient, so they all use the clients set by Reload
+type sharedClients struct {
+	current atomic.Pointer[clients]
+}
+
+// clients contains the generated API clients, they share a single HTTP client, token generator, limiter and circuit breaker
+type clients struct {
+	config      config.Config
+	doer        *resilientDoer
+	limiter     *Limiter
+	network     network.ClientInterface
+	vdr         vdr.ClientInterface
+	diagnostics diagnostics.ClientInterface
+}
+
+// NewHTTPClient creates a HTTPClient that reuses its HTTP connections and API tokens
+func NewHTTPClient(cfg config.Config) (HTTPClient, error) {
+	c, err := newClients(cfg)
+	if err != nil {
+		return HTTPClient{}, err
+	}
+	shared := &sharedClients{}
+	shared.current.Store(c)
+	return HTTPClient{Config: cfg, shared: shared}, nil
+}
+
+// Reload replaces the settings of the client and all its copies. New requests use the new settings, requests in flight are not affected.
+// The HTTP client, limiter and circuit breaker are only replaced when the connection, API security, retry or limit settings changed,
+// in that case the counters returned by Metrics start at 0. The current settings are kept if the new ones are invalid.
+func (hb HTTPClient) Reload(cfg config.Config) error {
+	if hb.shared == nil {
+		return errors.New("client doesn't support reloading, it must be created with NewHTTPClient")
+	}
+	current := hb.shared.current.Load()
+	if !connectionSettingsChanged(current.config, cfg) {
+		updated := *current
+		updated.config = cfg
+		hb.shared.current.Store(&updated)
+		return nil
 	}
-	response, err := network.NewClientWithResponses(addr, network.WithHTTPClient(MustCreateHTTPClient(hb.Config)))
+	c, err := newClients(cfg)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	return response
+	hb.shared.current.Store(c)
+	return nil
 }
 
-func (hb HTTPClient) vdrClient() vdr.ClientInterface {
-	addr := hb.Config.NutsNodeInternalAddr
-	if addr == "" {
-		addr = hb.Config.NutsNodeAddr
+// CurrentConfig returns the config of the client, including changes made by Reload
+func (hb HTTPClient) CurrentConfig() config.Config {
+	if hb.shared == nil {
+		return hb.Config
+	}
+	return hb.shared.current.Load().config
+}
+
+// connectionSettingsChanged returns true if settings that are used to create the clients differ
+func connectionSettingsChanged(a config.Config, b config.Config) bool {
+	settings := func(c config.Config) []interface{} {
+		return []interface{}{
+			c.NutsNodeAddr, c.NutsNodeInternalAddr, c.NutsNodeTLS, c.NutsNodeTimeout, c.NutsNodeConnectTimeout,
+			c.NutsNodeAPIKeyFile, c.NutsNodeAPITokenFile, c.NutsNodeAPIUser, c.NutsNodeAPIAudience, c.NutsNodeAPIAlgorithm,
+			c.NutsNodeAPITokenLifetime, c.NutsNodeAPIClaims, c.ApiKey, c.NutsNodeRetry, c.NutsNodeLimits,
+		}
 	}
-	response, err := vdr.NewClientWithResponses(addr, vdr.WithHTTPClient(MustCreateHTTPClient(hb.Config)))
+	return !reflect.DeepEqual(settings(a), settings(b))
+}
+
+func newClients(cfg config.Config) (*clients, error) {
+	httpClient, err := CreateHTTPClient(cfg)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return response
+	// every retry passes the limiter
+	limited := newLimitedDoer(httpClient, cfg.NutsNodeLimits)
+	doer := newResilientDoer(limited, cfg.NutsNodeRetry)
+	internalAddr := cfg.NutsNodeInternalAddr
+	if internalAddr == "" {
+		internalAddr = cfg.NutsNodeAddr
+	}
+
+	networkClient, err := network.NewClientWithResponses(internalAddr, network.WithHTTPClient(doer))
+	if err != nil {
+		return nil, err
+	}
+	vdrClient, err := vdr.NewClientWithResponses(internalAddr, vdr.WithHTTPClient(doer))
+	if err != nil {
+		return nil, err
+	}
+	diagnosticsClient, err := diagnostics.NewClientWithResponses(cfg.NutsNodeAddr, diagnostics.WithHTTPClient(doer))
+	if err != nil {
+		return nil, err
+	}
+	return &clients{
+		config:      cfg,
+		doer:        doer,
+		limiter:     limited.limiter,
+		network:     networkClient,
+		vdr:         vdrClient,
+		diagnostics: diagnosticsClient,
+	}, nil
 }
 
-func (hb HTTPClient) diagnosticsClient() diagnostics.ClientInterface {
-	response, err := diagnostics.NewClientWithResponses(hb.Config.NutsNodeAddr, diagnostics.WithHTTPClient(MustCreateHTTPClient(hb.Config)))
+func (hb HTTPClient) getClients() *clients {
+	if hb.shared != nil {
+		return hb.shared.current.Load()
+	}
+	result, err := newClients(hb.Config)
 	if err != nil {
 		panic(err)
 	}
-	return response
+	return result
+}
+
+// Metrics returns the request, retry, limiter and circuit breaker counters of the client
+func (hb HTTPClient) Metrics() Metrics {
+	if hb.shared == nil {
+		return Metrics{BreakerState: BreakerClosed.String()}
+	}
+	c := hb.shared.current.Load()
+	metrics := c.doer.metrics()
+	metrics.InFlight, metrics.Waiting = c.limiter.Stats()
+	return metrics
+}
+
+func (hb HTTPClient) networkClient() network.ClientInterface {
+	return hb.getClients().network
+}
+
+func (hb HTTPClient) vdrClient() vdr.ClientInterface {
+	return hb.getClients().vdr
+}
+
+func (hb HTTPClient) diagnosticsClient() diagnostics.ClientInterface {
+	return hb.getClients().diagnostics
 }
 
 func (hb HTTPClient) CheckHealth(ctx context.Context, reqEditors ...diagnostics.RequestEditorFn) (*diagnostics.Health, error) {
@@ -174,3 +286,54 @@ func (hb HTTPClient) ListTransactions(ctx context.Context, start int, end int) (
 	}
 	return transactions, nil
 }
+
+// RenderGraph returns the DAG in Graphviz DOT format for transactions with an LC value in the range [start, end)
+func (hb HTTPClient) RenderGraph(ctx context.Context, start int, end int) (string, error) {
+	response, err := hb.networkClient().RenderGraph(ctx, &network.RenderGraphParams{
+		Start: &start,
+		End:   &end,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := TestResponseCode(http.StatusOK, response); err != nil {
+		return "", err
+	}
+	parsedResponse, err := network.ParseRenderGraphResponse(response)
+	if err != nil {
+		return "", err
+	}
+	return string(parsedResponse.Body), nil
+}
+
+// Transaction returns the transaction with the given reference in compacted JWS format
+func (hb HTTPClient) Transaction(ctx context.Context, ref string) (string, error) {
+	response, err := hb.networkClient().GetTransaction(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	if err := TestResponseCode(http.StatusOK, response); err != nil {
+		return "", err
+	}
+	parsedResponse, err := network.ParseGetTransactionResponse(response)
+	if err != nil {
+		return "", err
+	}
+	return string(parsedResponse.Body), nil
+}
+
+// TransactionPayload returns the payload of the transaction with the given reference
+func (hb HTTPClient) TransactionPayload(ctx context.Context, ref string) ([]byte, error) {
+	response, err := hb.networkClient().GetTransactionPayload(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := TestResponseCode(http.StatusOK, response); err != nil {
+		return nil, err
+	}
+	parsedResponse, err := network.ParseGetTransactionPayloadResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	return parsedResponse.Body, nil
+}
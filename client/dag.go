@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// DAG is a slice of the DAG of the Nuts node, both in Graphviz DOT format and as a list of nodes and edges
+type DAG struct {
+	// Start is the LC value from where the DAG is rendered (inclusive)
+	Start int `json:"start"`
+	// End is the LC value up to where the DAG is rendered (exclusive)
+	End int `json:"end"`
+	// DOT contains the DAG as rendered by the Nuts node
+	DOT string `json:"dot"`
+	// Nodes contains the transactions in the DAG
+	Nodes []DAGNode `json:"nodes"`
+	// Edges contains the references from a previous transaction to the next transaction
+	Edges []Tuple `json:"edges"`
+	// Branches is the number of transactions without a next transaction, more than one indicates a branch
+	Branches int `json:"branches"`
+}
+
+// DAGNode is a single transaction in the DAG
+type DAGNode struct {
+	// Ref is the transaction reference
+	Ref string `json:"ref"`
+	// Label is the label as rendered by the Nuts node
+	Label string `json:"label"`
+}
+
+var (
+	// dotEdge matches lines like: "ref1" -> "ref2"
+	dotEdge = regexp.MustCompile(`^\s*"([^"]+)"\s*->\s*"([^"]+)"`)
+	// dotNode matches lines like: "ref1"[label="..."] or "ref1" [shape=box, label="..."]
+	dotNode = regexp.MustCompile(`^\s*"([^"]+)"\s*(\[(.*)\])?\s*;?\s*$`)
+	// dotLabel extracts the label attribute from an attribute list
+	dotLabel = regexp.MustCompile(`label\s*=\s*"((?:[^"\\]|\\.)*)"`)
+)
+
+// ParseDOT extracts the nodes and edges from a DAG rendered in DOT format by the Nuts node
+func ParseDOT(dot string) ([]DAGNode, []Tuple) {
+	nodes := []DAGNode{}
+	edges := []Tuple{}
+	known := map[string]bool{}
+	addNode := func(ref string, label string) {
+		if known[ref] {
+			return
+		}
+		known[ref] = true
+		nodes = append(nodes, DAGNode{Ref: ref, Label: label})
+	}
+
+	for _, line := range strings.Split(dot, "\n") {
+		if match := dotEdge.FindStringSubmatch(line); match != nil {
+			edges = append(edges, Tuple{match[1], match[2]})
+			continue
+		}
+		if match := dotNode.FindStringSubmatch(line); match != nil {
+			label := ""
+			if l := dotLabel.FindStringSubmatch(match[3]); l != nil {
+				label = strings.ReplaceAll(l[1], `\"`, `"`)
+			}
+			addNode(match[1], label)
+		}
+	}
+	// nodes only referenced from an edge
+	for _, e := range edges {
+		addNode(e[0], "")
+		addNode(e[1], "")
+	}
+
+	return nodes, edges
+}
+
+// RenderDAG renders the DAG for transactions with an LC value in the range [start, end)
+func (hb HTTPClient) RenderDAG(ctx context.Context, start int, end int) (DAG, error) {
+	dag := DAG{Start: start, End: end}
+	dot, err := hb.RenderGraph(ctx, start, end)
+	if err != nil {
+		return dag, err
+	}
+	dag.DOT = dot
+	dag.Nodes, dag.Edges = ParseDOT(dot)
+
+	hasNext := map[string]bool{}
+	for _, e := range dag.Edges {
+		hasNext[e[0]] = true
+	}
+	for _, n := range dag.Nodes {
+		if !hasNext[n.Ref] {
+			dag.Branches++
+		}
+	}
+
+	return dag, nil
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/test"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exampleDOT is a DAG with a branch: a is followed by both b and c
+const exampleDOT = `digraph {
+  "a"[label="DID Document (LC=1)"]
+  "b"[label="DID Document (LC=2)"]
+  "c" [shape=box, label="application/vc+json \"test\" (LC=2)"];
+  "a" -> "b"
+  "a" -> "c"
+}`
+
+func TestParseDOT(t *testing.T) {
+	t.Run("nodes and edges", func(t *testing.T) {
+		nodes, edges := ParseDOT(exampleDOT)
+
+		require.Len(t, nodes, 3)
+		assert.Equal(t, DAGNode{Ref: "a", Label: "DID Document (LC=1)"}, nodes[0])
+		assert.Equal(t, DAGNode{Ref: "c", Label: `application/vc+json "test" (LC=2)`}, nodes[2])
+		assert.Equal(t, []Tuple{{"a", "b"}, {"a", "c"}}, edges)
+	})
+	t.Run("nodes only referenced by edges", func(t *testing.T) {
+		nodes, edges := ParseDOT("digraph {\n  \"a\" -> \"b\"\n}")
+
+		assert.Equal(t, []DAGNode{{Ref: "a"}, {Ref: "b"}}, nodes)
+		assert.Len(t, edges, 1)
+	})
+	t.Run("empty", func(t *testing.T) {
+		nodes, edges := ParseDOT("")
+
+		assert.Empty(t, nodes)
+		assert.Empty(t, edges)
+	})
+}
+
+func TestClient_RenderDAG(t *testing.T) {
+	ts := test.BasicTestNode(t)
+	var query string
+	ts.HandleFunc("/internal/network/v1/diagnostics/graph", func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(exampleDOT))
+	})
+	client := HTTPClient{Config: config.Config{NutsNodeAddr: ts.URL()}}
+
+	dag, err := client.RenderDAG(context.Background(), 1, 3)
+
+	require.NoError(t, err)
+	assert.Equal(t, "end=3&start=1", query)
+	assert.Equal(t, exampleDOT, dag.DOT)
+	assert.Len(t, dag.Nodes, 3)
+	assert.Equal(t, 2, dag.Branches)
+}
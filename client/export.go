@@ -0,0 +1,207 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// exportedAttributes lists the name and GraphML type of the peer properties that are exported as node attributes
+var exportedAttributes = [][2]string{
+	{"cn", "string"},
+	{"software_version", "string"},
+	{"software_id", "string"},
+	{"tx_count", "int"},
+	{"address", "string"},
+	{"authenticated", "boolean"},
+	{"contact_name", "string"},
+	{"node_did", "string"},
+}
+
+// peerAttributes returns the name and value of the exported properties of a peer, in the order of exportedAttributes
+func peerAttributes(p Peer) [][2]string {
+	nodeDID := ""
+	if p.NodeDID != nil {
+		nodeDID = *p.NodeDID
+	}
+	return [][2]string{
+		{"cn", p.CN},
+		{"software_version", p.SoftwareVersion},
+		{"software_id", p.SoftwareID},
+		{"tx_count", strconv.Itoa(p.TransactionCount)},
+		{"address", p.Address},
+		{"authenticated", strconv.FormatBool(p.Authenticated)},
+		{"contact_name", p.ContactName},
+		{"node_did", nodeDID},
+	}
+}
+
+// DOT returns the topology as an undirected Graphviz graph. Peer information is added as node attributes.
+func (nt NetworkTopology) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("graph network {\n")
+	for _, p := range nt.Peers {
+		sb.WriteString("  ")
+		sb.WriteString(dotQuote(p.PeerID))
+		sb.WriteString(" [")
+		if p.PeerID == nt.PeerID {
+			sb.WriteString("self=true, ")
+		}
+		for i, a := range peerAttributes(p) {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(a[0])
+			sb.WriteString("=")
+			sb.WriteString(dotQuote(a[1]))
+		}
+		sb.WriteString("];\n")
+	}
+	for _, e := range nt.Edges {
+		sb.WriteString(fmt.Sprintf("  %s -- %s;\n", dotQuote(e[0]), dotQuote(e[1])))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// dotQuote returns s as a double-quoted DOT ID
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// GraphML returns the topology as an undirected GraphML document. Peer information is added as node data.
+func (nt NetworkTopology) GraphML() ([]byte, error) {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Graph: graphMLGraph{ID: "network", EdgeDefault: "undirected"},
+	}
+	for _, a := range exportedAttributes {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: a[0], For: "node", AttrName: a[0], AttrType: a[1]})
+	}
+	for _, p := range nt.Peers {
+		node := graphMLNode{ID: p.PeerID}
+		for _, a := range peerAttributes(p) {
+			node.Data = append(node.Data, graphMLData{Key: a[0], Value: a[1]})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, e := range nt.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: e[0], Target: e[1]})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// JSONGraph is the root of a JSON Graph Format (v2) document
+type JSONGraph struct {
+	Graph JSONGraphGraph `json:"graph"`
+}
+
+// JSONGraphGraph is a single graph in the JSON Graph Format
+type JSONGraphGraph struct {
+	ID       string                   `json:"id"`
+	Type     string                   `json:"type"`
+	Directed bool                     `json:"directed"`
+	Metadata map[string]interface{}   `json:"metadata,omitempty"`
+	Nodes    map[string]JSONGraphNode `json:"nodes"`
+	Edges    []JSONGraphEdge          `json:"edges"`
+}
+
+// JSONGraphNode is a node in the JSON Graph Format, the peer information is added as metadata
+type JSONGraphNode struct {
+	Label    string            `json:"label"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// JSONGraphEdge is an edge in the JSON Graph Format
+type JSONGraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// JSONGraph returns the topology in JSON Graph Format. Peer information is added as node metadata.
+func (nt NetworkTopology) JSONGraph() JSONGraph {
+	g := JSONGraphGraph{
+		ID:       "network",
+		Type:     "nuts-network",
+		Metadata: map[string]interface{}{"peer_id": nt.PeerID, "tx_count": nt.TxCount},
+		Nodes:    map[string]JSONGraphNode{},
+		Edges:    []JSONGraphEdge{},
+	}
+	for _, p := range nt.Peers {
+		node := JSONGraphNode{Label: p.PeerID, Metadata: map[string]string{}}
+		if p.CN != "" {
+			node.Label = p.CN
+		}
+		for _, a := range peerAttributes(p) {
+			node.Metadata[a[0]] = a[1]
+		}
+		g.Nodes[p.PeerID] = node
+	}
+	for _, e := range nt.Edges {
+		g.Edges = append(g.Edges, JSONGraphEdge{Source: e[0], Target: e[1]})
+	}
+	return JSONGraph{Graph: g}
+}
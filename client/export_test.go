@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportTestTopology() NetworkTopology {
+	nodeDID := "did:nuts:them"
+	return NetworkTopology{
+		PeerID:  "us",
+		TxCount: 10,
+		Peers: []Peer{
+			{PeerID: "us", TransactionCount: 10},
+			{PeerID: "them", CN: `CN="Them"`, SoftwareVersion: "v5.0.0", TransactionCount: 9, NodeDID: &nodeDID},
+		},
+		Edges: []Tuple{{"us", "them"}},
+	}
+}
+
+func TestNetworkTopology_DOT(t *testing.T) {
+	dot := exportTestTopology().DOT()
+
+	assert.Contains(t, dot, "graph network {\n")
+	assert.Contains(t, dot, `"us" [self=true, cn="", `)
+	assert.Contains(t, dot, `"them" [cn="CN=\"Them\"", software_version="v5.0.0", software_id="", tx_count="9"`)
+	assert.Contains(t, dot, `node_did="did:nuts:them"];`)
+	assert.Contains(t, dot, `  "us" -- "them";`)
+}
+
+func TestNetworkTopology_GraphML(t *testing.T) {
+	data, err := exportTestTopology().GraphML()
+
+	require.NoError(t, err)
+	doc := graphML{}
+	require.NoError(t, xml.Unmarshal(data, &doc))
+	assert.Len(t, doc.Keys, len(exportedAttributes))
+	require.Len(t, doc.Graph.Nodes, 2)
+	assert.Equal(t, "them", doc.Graph.Nodes[1].ID)
+	assert.Contains(t, doc.Graph.Nodes[1].Data, graphMLData{Key: "tx_count", Value: "9"})
+	assert.Equal(t, []graphMLEdge{{Source: "us", Target: "them"}}, doc.Graph.Edges)
+}
+
+func TestNetworkTopology_JSONGraph(t *testing.T) {
+	jgf := exportTestTopology().JSONGraph()
+
+	data, err := json.Marshal(jgf)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"directed":false`)
+	require.Len(t, jgf.Graph.Nodes, 2)
+	assert.Equal(t, `CN="Them"`, jgf.Graph.Nodes["them"].Label)
+	assert.Equal(t, "v5.0.0", jgf.Graph.Nodes["them"].Metadata["software_version"])
+	assert.Equal(t, "us", jgf.Graph.Nodes["us"].Label)
+	assert.Equal(t, []JSONGraphEdge{{Source: "us", Target: "them"}}, jgf.Graph.Edges)
+}
@@ -19,11 +19,15 @@
 package client
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"nuts-foundation/nuts-monitor/config"
-	"time"
+	"os"
 )
 
 // CreateHTTPClient creates a new HTTP client with the given client configuration.
@@ -34,15 +38,24 @@ import (
 // If the given authorization token builder is non-nil, it calls it and passes the resulting token as bearer token with requests.
 func CreateHTTPClient(cfg config.Config) (HTTPRequestDoer, error) {
 	var result *httpRequestDoerAdapter
-	client := &http.Client{}
-	client.Timeout = 10 * time.Second
+	transport, err := createTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   cfg.NutsNodeTimeout,
+	}
 
 	generator := func() (string, error) {
 		return "", nil
 	}
 
-	if cfg.NutsNodeAPIKeyFile != "" {
-		generator = createTokenGenerator(cfg)
+	if cfg.NutsNodeAPIKeyFile != "" || cfg.NutsNodeAPITokenFile != "" {
+		generator, err = createTokenGenerator(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API security config: %w", err)
+		}
 	}
 
 	result = &httpRequestDoerAdapter{fn: func(req *http.Request) (*http.Response, error) {
@@ -59,6 +72,53 @@ func CreateHTTPClient(cfg config.Config) (HTTPRequestDoer, error) {
 	return result, nil
 }
 
+// createTransport creates the HTTP transport for calls to the Nuts node, using the configured TLS settings and connect timeout
+func createTransport(cfg config.Config) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.NutsNodeConnectTimeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: cfg.NutsNodeConnectTimeout}).DialContext
+		transport.TLSHandshakeTimeout = cfg.NutsNodeConnectTimeout
+	}
+	if !cfg.NutsNodeTLS.IsZero() {
+		tlsConfig, err := createTLSConfig(cfg.NutsNodeTLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport, nil
+}
+
+// createTLSConfig creates the TLS configuration for calls to the Nuts node
+func createTLSConfig(c config.NodeTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+	if len(c.CertFile) > 0 || len(c.CertKeyFile) > 0 {
+		if len(c.CertFile) == 0 || len(c.CertKeyFile) == 0 {
+			return nil, errors.New("both certfile and certkeyfile are required for a client certificate")
+		}
+		certificate, err := tls.LoadX509KeyPair(c.CertFile, c.CertKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	if len(c.TrustStoreFile) > 0 {
+		bytes, err := os.ReadFile(c.TrustStoreFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read truststore: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bytes) {
+			return nil, fmt.Errorf("no certificates found in truststore: %s", c.TrustStoreFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
 // MustCreateHTTPClient is like CreateHTTPClient but panics if it returns an error.
 func MustCreateHTTPClient(cfg config.Config) HTTPRequestDoer {
 	client, err := CreateHTTPClient(cfg)
@@ -86,12 +146,23 @@ func (w httpRequestDoerAdapter) Do(req *http.Request) (*http.Response, error) {
 	return w.fn(req)
 }
 
+// StatusError is returned when the Nuts node responds with an unexpected status code
+type StatusError struct {
+	StatusCode         int
+	ExpectedStatusCode int
+	Body               []byte
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("server returned HTTP %d (expected: %d), body: %s", e.StatusCode, e.ExpectedStatusCode, e.Body)
+}
+
 // TestResponseCode checks whether the returned HTTP status response code matches the expected code.
-// If it doesn't match it returns an error, containing the received and expected status code, and the response body.
+// If it doesn't match it returns a StatusError, containing the received and expected status code, and the response body.
 func TestResponseCode(expectedStatusCode int, response *http.Response) error {
 	if response.StatusCode != expectedStatusCode {
 		responseData, _ := io.ReadAll(response.Body)
-		return fmt.Errorf("server returned HTTP %d (expected: %d), body: %s", response.StatusCode, expectedStatusCode, responseData)
+		return StatusError{StatusCode: response.StatusCode, ExpectedStatusCode: expectedStatusCode, Body: responseData}
 	}
 	return nil
 }
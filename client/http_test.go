@@ -0,0 +1,240 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/test"
+	"os"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func healthHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status": "UP"}`))
+}
+
+// trustStore writes the certificate of the test server to a PEM file
+func trustStore(t *testing.T, ts *httptest.Server) string {
+	file := path.Join(t.TempDir(), "truststore.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	require.NoError(t, os.WriteFile(file, data, 0600))
+	return file
+}
+
+func TestCreateHTTPClient_TLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(healthHandler))
+	defer ts.Close()
+	truststore := trustStore(t, ts)
+
+	checkHealth := func(tlsConfig config.NodeTLSConfig) error {
+		client := HTTPClient{Config: config.Config{NutsNodeAddr: ts.URL, NutsNodeTLS: tlsConfig}}
+		_, err := client.CheckHealth(context.Background())
+		return err
+	}
+
+	t.Run("custom CA", func(t *testing.T) {
+		err := checkHealth(config.NodeTLSConfig{TrustStoreFile: truststore})
+
+		assert.NoError(t, err)
+	})
+	t.Run("unknown CA", func(t *testing.T) {
+		err := checkHealth(config.NodeTLSConfig{})
+
+		assert.ErrorContains(t, err, "certificate signed by unknown authority")
+	})
+	t.Run("server name override", func(t *testing.T) {
+		// the certificate of the test server is issued for example.com
+		err := checkHealth(config.NodeTLSConfig{TrustStoreFile: truststore, ServerName: "example.com"})
+
+		assert.NoError(t, err)
+	})
+	t.Run("server name mismatch", func(t *testing.T) {
+		err := checkHealth(config.NodeTLSConfig{TrustStoreFile: truststore, ServerName: "nuts.example.org"})
+
+		assert.ErrorContains(t, err, "certificate is valid for")
+	})
+}
+
+func TestCreateHTTPClient_mTLS(t *testing.T) {
+	pki := test.NewPKI(t)
+	certFile, keyFile := pki.Issue(t, "monitor")
+	caBytes, _ := os.ReadFile(pki.CAFile)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(caBytes)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(healthHandler))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	ts.StartTLS()
+	defer ts.Close()
+	truststore := trustStore(t, ts)
+
+	t.Run("with client certificate", func(t *testing.T) {
+		client := HTTPClient{Config: config.Config{
+			NutsNodeAddr: ts.URL,
+			NutsNodeTLS:  config.NodeTLSConfig{TrustStoreFile: truststore, CertFile: certFile, CertKeyFile: keyFile},
+		}}
+
+		health, err := client.CheckHealth(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, "UP", health.Status)
+	})
+	t.Run("without client certificate", func(t *testing.T) {
+		client := HTTPClient{Config: config.Config{
+			NutsNodeAddr: ts.URL,
+			NutsNodeTLS:  config.NodeTLSConfig{TrustStoreFile: truststore},
+		}}
+
+		_, err := client.CheckHealth(context.Background())
+
+		assert.Error(t, err)
+	})
+}
+
+func TestCreateHTTPClient(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+			healthHandler(w, r)
+		}))
+		defer ts.Close()
+		client := HTTPClient{Config: config.Config{NutsNodeAddr: ts.URL, NutsNodeTimeout: 10 * time.Millisecond}}
+
+		_, err := client.CheckHealth(context.Background())
+
+		assert.ErrorContains(t, err, "Client.Timeout exceeded")
+	})
+	t.Run("static bearer token", func(t *testing.T) {
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("pre-issued"), 0600))
+		var authorization string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			healthHandler(w, r)
+		}))
+		defer ts.Close()
+		client := HTTPClient{Config: config.Config{NutsNodeAddr: ts.URL, NutsNodeAPITokenFile: tokenFile}}
+
+		_, err := client.CheckHealth(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer pre-issued", authorization)
+	})
+	t.Run("invalid API key config", func(t *testing.T) {
+		_, err := CreateHTTPClient(config.Config{NutsNodeAPIKeyFile: "key.pem"})
+
+		assert.EqualError(t, err, "invalid API security config: no signing private key")
+	})
+	t.Run("missing key file", func(t *testing.T) {
+		_, err := CreateHTTPClient(config.Config{NutsNodeTLS: config.NodeTLSConfig{CertFile: "cert.pem"}})
+
+		assert.EqualError(t, err, "both certfile and certkeyfile are required for a client certificate")
+	})
+	t.Run("invalid truststore", func(t *testing.T) {
+		_, err := CreateHTTPClient(config.Config{NutsNodeTLS: config.NodeTLSConfig{TrustStoreFile: "non-existing.pem"}})
+
+		assert.ErrorContains(t, err, "failed to read truststore")
+	})
+}
+
+func TestHTTPClient_Reload(t *testing.T) {
+	var first, second atomic.Int32
+	ts1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first.Add(1)
+		healthHandler(w, r)
+	}))
+	defer ts1.Close()
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		second.Add(1)
+		healthHandler(w, r)
+	}))
+	defer ts2.Close()
+
+	t.Run("copies use the new address", func(t *testing.T) {
+		client, err := NewHTTPClient(config.Config{NutsNodeAddr: ts1.URL})
+		require.NoError(t, err)
+		clientCopy := client
+
+		err = client.Reload(config.Config{NutsNodeAddr: ts2.URL})
+
+		require.NoError(t, err)
+		_, err = clientCopy.CheckHealth(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, int32(0), first.Load())
+		assert.Equal(t, int32(1), second.Load())
+		assert.Equal(t, ts2.URL, clientCopy.CurrentConfig().NutsNodeAddr)
+	})
+	t.Run("invalid config keeps the current settings", func(t *testing.T) {
+		client, err := NewHTTPClient(config.Config{NutsNodeAddr: ts1.URL})
+		require.NoError(t, err)
+
+		err = client.Reload(config.Config{NutsNodeAddr: ts2.URL, NutsNodeTLS: config.NodeTLSConfig{TrustStoreFile: "non-existing.pem"}})
+
+		assert.ErrorContains(t, err, "failed to read truststore")
+		assert.Equal(t, ts1.URL, client.CurrentConfig().NutsNodeAddr)
+	})
+	t.Run("not created by NewHTTPClient", func(t *testing.T) {
+		client := HTTPClient{Config: config.Config{NutsNodeAddr: ts1.URL}}
+
+		assert.Error(t, client.Reload(config.Config{NutsNodeAddr: ts2.URL}))
+	})
+}
+
+// BenchmarkHTTPClient compares creating the HTTP client for every call to a shared client that reuses its connections
+func BenchmarkHTTPClient(b *testing.B) {
+	ts := httptest.NewServer(http.HandlerFunc(healthHandler))
+	defer ts.Close()
+	cfg := apiKeyConfig(b)
+	cfg.NutsNodeAddr = ts.URL
+	// enables API tokens, the key itself is already loaded
+	cfg.NutsNodeAPIKeyFile = "api-key.pem"
+
+	b.Run("client per call", func(b *testing.B) {
+		client := HTTPClient{Config: cfg}
+		for i := 0; i < b.N; i++ {
+			if _, err := client.CheckHealth(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("shared client", func(b *testing.B) {
+		client, err := NewHTTPClient(cfg)
+		require.NoError(b, err)
+		for i := 0; i < b.N; i++ {
+			if _, err := client.CheckHealth(context.Background()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
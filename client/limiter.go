@@ -0,0 +1,215 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"nuts-foundation/nuts-monitor/config"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Priority determines the order in which waiting requests are sent to the Nuts node
+type Priority int
+
+const (
+	// PriorityInteractive is used for requests from users of the web UI and API, it's the default
+	PriorityInteractive Priority = iota
+	// PriorityBackground is used for requests from background processes like loading the history
+	PriorityBackground
+	priorityCount
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context that makes requests to the Nuts node use the given priority
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func priorityFrom(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return priority.clamp()
+	}
+	return PriorityInteractive
+}
+
+// clamp returns the nearest defined priority, values after the lowest priority get the lowest priority
+func (p Priority) clamp() Priority {
+	return min(max(p, PriorityInteractive), priorityCount-1)
+}
+
+// Limiter limits the number of requests per second and the number of concurrent requests to the Nuts node.
+// Waiting requests are admitted in order of priority, so interactive requests aren't starved by background processes.
+type Limiter struct {
+	rate        *rate.Limiter
+	maxInFlight int
+
+	mutex    sync.Mutex
+	inFlight int
+	queues   [priorityCount][]*waiter
+	// timerPending is true when a dispatch is scheduled for when the next rate limit token is available
+	timerPending bool
+}
+
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// NewLimiter creates a Limiter. A requestsPerSecond or maxInFlight of 0 disables the corresponding limit.
+func NewLimiter(requestsPerSecond float64, burst int, maxInFlight int) *Limiter {
+	l := &Limiter{maxInFlight: maxInFlight}
+	if requestsPerSecond > 0 {
+		if burst < 1 {
+			burst = 1
+		}
+		l.rate = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+	}
+	return l
+}
+
+// Acquire waits until a request with the given priority may be sent. The returned function must be called when the request is done.
+func (l *Limiter) Acquire(ctx context.Context, priority Priority) (func(), error) {
+	if l.rate == nil && l.maxInFlight <= 0 {
+		return func() {}, nil
+	}
+
+	priority = priority.clamp()
+	w := &waiter{ready: make(chan struct{})}
+	l.mutex.Lock()
+	l.queues[priority] = append(l.queues[priority], w)
+	l.dispatch()
+	l.mutex.Unlock()
+
+	select {
+	case <-w.ready:
+		return l.release, nil
+	case <-ctx.Done():
+		l.mutex.Lock()
+		if w.granted {
+			// granted while the context was cancelled
+			l.mutex.Unlock()
+			l.release()
+		} else {
+			l.remove(priority, w)
+			l.mutex.Unlock()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (l *Limiter) release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.inFlight--
+	l.dispatch()
+}
+
+// dispatch admits waiting requests, highest priority first, as long as the limits allow. The mutex must be held.
+func (l *Limiter) dispatch() {
+	for l.maxInFlight <= 0 || l.inFlight < l.maxInFlight {
+		w, priority := l.next()
+		if w == nil {
+			return
+		}
+		if l.rate != nil {
+			reservation := l.rate.Reserve()
+			if delay := reservation.Delay(); delay > 0 {
+				// no token available yet, try again when there is
+				reservation.Cancel()
+				l.scheduleDispatch(delay)
+				return
+			}
+		}
+		l.queues[priority] = l.queues[priority][1:]
+		l.inFlight++
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// next returns the first waiter with the highest priority
+func (l *Limiter) next() (*waiter, Priority) {
+	for priority := range l.queues {
+		if len(l.queues[priority]) > 0 {
+			return l.queues[priority][0], Priority(priority)
+		}
+	}
+	return nil, 0
+}
+
+func (l *Limiter) scheduleDispatch(delay time.Duration) {
+	if l.timerPending {
+		return
+	}
+	l.timerPending = true
+	time.AfterFunc(delay, func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		l.timerPending = false
+		l.dispatch()
+	})
+}
+
+func (l *Limiter) remove(priority Priority, w *waiter) {
+	queue := l.queues[priority]
+	for i := range queue {
+		if queue[i] == w {
+			l.queues[priority] = append(queue[:i:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// Stats returns the number of requests in flight and the number of waiting requests
+func (l *Limiter) Stats() (inFlight int, waiting int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, queue := range l.queues {
+		waiting += len(queue)
+	}
+	return l.inFlight, waiting
+}
+
+// limitedDoer sends requests through a Limiter, using the priority from the request context
+type limitedDoer struct {
+	next    HTTPRequestDoer
+	limiter *Limiter
+}
+
+func newLimitedDoer(next HTTPRequestDoer, c config.LimitConfig) *limitedDoer {
+	return &limitedDoer{
+		next:    next,
+		limiter: NewLimiter(c.RequestsPerSecond, c.Burst, c.MaxInFlight),
+	}
+}
+
+func (ld *limitedDoer) Do(req *http.Request) (*http.Response, error) {
+	release, err := ld.limiter.Acquire(req.Context(), priorityFrom(req.Context()))
+	if err != nil {
+		return nil, err
+	}
+	// the slot is released when the response headers are received, the body is not guaranteed to be closed by all callers
+	defer release()
+	return ld.next.Do(req)
+}
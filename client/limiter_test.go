@@ -0,0 +1,179 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"nuts-foundation/nuts-monitor/config"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForWaiting waits until the given number of requests is waiting for the limiter
+func waitForWaiting(t *testing.T, limiter *Limiter, expected int) {
+	require.Eventually(t, func() bool {
+		_, waiting := limiter.Stats()
+		return waiting == expected
+	}, time.Second, time.Millisecond)
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("max in flight", func(t *testing.T) {
+		limiter := NewLimiter(0, 0, 1)
+		release, err := limiter.Acquire(ctx, PriorityInteractive)
+		require.NoError(t, err)
+
+		acquired := make(chan struct{})
+		go func() {
+			release, _ := limiter.Acquire(ctx, PriorityInteractive)
+			close(acquired)
+			release()
+		}()
+		waitForWaiting(t, limiter, 1)
+		select {
+		case <-acquired:
+			t.Fatal("acquired while the limit was reached")
+		default:
+		}
+
+		release()
+
+		<-acquired
+		inFlight, waiting := limiter.Stats()
+		assert.Equal(t, 0, waiting)
+		assert.LessOrEqual(t, inFlight, 1)
+	})
+	t.Run("undefined priorities are clamped", func(t *testing.T) {
+		limiter := NewLimiter(0, 0, 2)
+
+		for _, priority := range []Priority{-1, priorityCount, 100} {
+			release, err := limiter.Acquire(ctx, priority)
+			require.NoError(t, err)
+			release()
+		}
+		assert.Equal(t, PriorityBackground, priorityFrom(WithPriority(ctx, 100)))
+		assert.Equal(t, PriorityInteractive, priorityFrom(WithPriority(ctx, -1)))
+	})
+	t.Run("interactive requests go first", func(t *testing.T) {
+		limiter := NewLimiter(0, 0, 1)
+		release, err := limiter.Acquire(ctx, PriorityInteractive)
+		require.NoError(t, err)
+
+		var order []Priority
+		var mutex sync.Mutex
+		wg := sync.WaitGroup{}
+		acquire := func(priority Priority) {
+			defer wg.Done()
+			release, _ := limiter.Acquire(ctx, priority)
+			mutex.Lock()
+			order = append(order, priority)
+			mutex.Unlock()
+			release()
+		}
+		wg.Add(3)
+		go acquire(PriorityBackground)
+		waitForWaiting(t, limiter, 1)
+		go acquire(PriorityBackground)
+		waitForWaiting(t, limiter, 2)
+		go acquire(PriorityInteractive)
+		waitForWaiting(t, limiter, 3)
+
+		release()
+		wg.Wait()
+
+		assert.Equal(t, []Priority{PriorityInteractive, PriorityBackground, PriorityBackground}, order)
+	})
+	t.Run("requests per second", func(t *testing.T) {
+		limiter := NewLimiter(20, 1, 0)
+		start := time.Now()
+
+		for i := 0; i < 3; i++ {
+			release, err := limiter.Acquire(ctx, PriorityBackground)
+			require.NoError(t, err)
+			release()
+		}
+
+		// the first request uses the burst, the other two wait 50ms each
+		assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	})
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		limiter := NewLimiter(0, 0, 1)
+		release, err := limiter.Acquire(ctx, PriorityInteractive)
+		require.NoError(t, err)
+		defer release()
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err = limiter.Acquire(timeoutCtx, PriorityInteractive)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		inFlight, waiting := limiter.Stats()
+		assert.Equal(t, 1, inFlight)
+		assert.Equal(t, 0, waiting)
+	})
+	t.Run("unlimited", func(t *testing.T) {
+		limiter := NewLimiter(0, 0, 0)
+
+		release, err := limiter.Acquire(ctx, PriorityBackground)
+
+		require.NoError(t, err)
+		release()
+	})
+}
+
+func TestHTTPClient_MaxInFlight(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			observed := maxInFlight.Load()
+			if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		healthHandler(w, r)
+	}))
+	defer ts.Close()
+	client, err := NewHTTPClient(config.Config{NutsNodeAddr: ts.URL, NutsNodeLimits: config.LimitConfig{MaxInFlight: 2}})
+	require.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.CheckHealth(WithPriority(context.Background(), PriorityBackground))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), maxInFlight.Load())
+}
@@ -0,0 +1,288 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"nuts-foundation/nuts-monitor/config"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCircuitOpen is returned when requests to the Nuts node are not sent because the node is considered down
+var ErrCircuitOpen = errors.New("circuit breaker is open: Nuts node is unavailable")
+
+// Backoff calculates exponentially increasing wait times with full jitter
+type Backoff struct {
+	// Initial is the maximum wait time before the first retry
+	Initial time.Duration
+	// Max caps the wait time
+	Max time.Duration
+}
+
+// Duration returns the wait time before the given retry, starting at 0.
+// The result is a random duration between 0 and min(Max, Initial * 2^retry).
+func (b Backoff) Duration(retry int) time.Duration {
+	if b.Initial <= 0 {
+		return 0
+	}
+	ceiling := b.Initial
+	for i := 0; i < retry && ceiling < b.Max; i++ {
+		ceiling *= 2
+	}
+	if b.Max > 0 && ceiling > b.Max {
+		ceiling = b.Max
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// Sleep waits for the backoff duration of the given retry, it returns false if the context is done before that
+func (b Backoff) Sleep(ctx context.Context, retry int) bool {
+	timer := time.NewTimer(b.Duration(retry))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// BreakerState is the state of a CircuitBreaker
+type BreakerState int32
+
+const (
+	// BreakerClosed lets all requests through
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen lets a single request through to probe whether the node is back
+	BreakerHalfOpen
+	// BreakerOpen rejects all requests
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+	return "closed"
+}
+
+// CircuitBreaker stops sending requests to the Nuts node after a number of consecutive failures.
+// After the open duration a single probe request is let through, if it succeeds the breaker closes again.
+type CircuitBreaker struct {
+	threshold    int
+	openDuration time.Duration
+	now          func() time.Time
+
+	mutex    sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// opened counts the number of times the breaker opened
+	opened uint64
+}
+
+// NewCircuitBreaker creates a CircuitBreaker that opens after threshold consecutive failures.
+// A threshold of 0 disables the breaker.
+func NewCircuitBreaker(threshold int, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:    threshold,
+		openDuration: openDuration,
+		now:          time.Now,
+	}
+}
+
+// Allow returns ErrCircuitOpen if a request may not be sent
+func (cb *CircuitBreaker) Allow() error {
+	if cb.threshold <= 0 {
+		return nil
+	}
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		if cb.now().Sub(cb.openedAt) < cb.openDuration {
+			return ErrCircuitOpen
+		}
+		cb.state = BreakerHalfOpen
+		cb.openedAt = cb.now()
+		return nil
+	case BreakerHalfOpen:
+		// only the probe request is allowed, unless it didn't finish (e.g. because it was cancelled)
+		if cb.now().Sub(cb.openedAt) < cb.openDuration {
+			return ErrCircuitOpen
+		}
+		cb.openedAt = cb.now()
+		return nil
+	}
+	return nil
+}
+
+// Success records a successful request, it closes the breaker
+func (cb *CircuitBreaker) Success() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.failures = 0
+	cb.state = BreakerClosed
+}
+
+// Failure records a failed request, it opens the breaker when the threshold is reached or the probe request failed
+func (cb *CircuitBreaker) Failure() {
+	if cb.threshold <= 0 {
+		return
+	}
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.failures++
+	if cb.state == BreakerHalfOpen || (cb.state == BreakerClosed && cb.failures >= cb.threshold) {
+		cb.state = BreakerOpen
+		cb.openedAt = cb.now()
+		cb.opened++
+	}
+}
+
+// State returns the current state of the breaker
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.state
+}
+
+// Opened returns the number of times the breaker opened
+func (cb *CircuitBreaker) Opened() uint64 {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.opened
+}
+
+// Metrics contains counters of the requests to a Nuts node
+type Metrics struct {
+	// Requests is the number of requests made by the monitor, retries are not included
+	Requests uint64 `json:"requests"`
+	// Retries is the number of retried requests
+	Retries uint64 `json:"retries"`
+	// Failures is the number of requests that failed after all retries
+	Failures uint64 `json:"failures"`
+	// Rejected is the number of requests that were not sent because the circuit breaker was open
+	Rejected uint64 `json:"rejected"`
+	// BreakerState is the current state of the circuit breaker: closed, half-open or open
+	BreakerState string `json:"breaker_state"`
+	// BreakerOpened is the number of times the circuit breaker opened
+	BreakerOpened uint64 `json:"breaker_opened"`
+	// InFlight is the number of requests currently sent to the node
+	InFlight int `json:"in_flight"`
+	// Waiting is the number of requests waiting for the rate or concurrency limit
+	Waiting int `json:"waiting"`
+}
+
+// resilientDoer retries idempotent requests that fail because of network errors or an unavailable node,
+// and fails fast using a circuit breaker when the node is down.
+type resilientDoer struct {
+	next        HTTPRequestDoer
+	maxAttempts int
+	backoff     Backoff
+	breaker     *CircuitBreaker
+
+	requests atomic.Uint64
+	retries  atomic.Uint64
+	failures atomic.Uint64
+	rejected atomic.Uint64
+}
+
+func newResilientDoer(next HTTPRequestDoer, c config.RetryConfig) *resilientDoer {
+	return &resilientDoer{
+		next:        next,
+		maxAttempts: c.MaxAttempts,
+		backoff:     Backoff{Initial: c.InitialBackoff, Max: c.MaxBackoff},
+		breaker:     NewCircuitBreaker(c.BreakerThreshold, c.BreakerTimeout),
+	}
+}
+
+func (rd *resilientDoer) Do(req *http.Request) (*http.Response, error) {
+	rd.requests.Add(1)
+	if err := rd.breaker.Allow(); err != nil {
+		rd.rejected.Add(1)
+		return nil, err
+	}
+
+	attempts := 1
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		attempts = rd.maxAttempts
+	}
+	for attempt := 0; ; attempt++ {
+		response, err := rd.next.Do(req)
+		if !retryable(req, response, err) {
+			if err != nil {
+				// e.g. a cancelled request, this doesn't say anything about the node
+				rd.failures.Add(1)
+			} else {
+				rd.breaker.Success()
+			}
+			return response, err
+		}
+		if attempt+1 >= attempts || !rd.backoff.Sleep(req.Context(), attempt) {
+			rd.failures.Add(1)
+			// a request that is cancelled while waiting for a retry doesn't say anything about the node
+			if req.Context().Err() == nil {
+				rd.breaker.Failure()
+			}
+			return response, err
+		}
+		// the response is discarded, so close it to reuse the connection
+		if response != nil {
+			_, _ = io.Copy(io.Discard, response.Body)
+			_ = response.Body.Close()
+		}
+		rd.retries.Add(1)
+	}
+}
+
+// retryable returns true if the request failed because of a network error or because the node is (temporarily) unavailable
+func retryable(req *http.Request, response *http.Response, err error) bool {
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	switch response.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (rd *resilientDoer) metrics() Metrics {
+	return Metrics{
+		Requests:      rd.requests.Load(),
+		Retries:       rd.retries.Load(),
+		Failures:      rd.failures.Load(),
+		Rejected:      rd.rejected.Load(),
+		BreakerState:  rd.breaker.State().String(),
+		BreakerOpened: rd.breaker.Opened(),
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"nuts-foundation/nuts-monitor/config"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff_Duration(t *testing.T) {
+	backoff := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+
+	for retry, ceiling := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for i := 0; i < 100; i++ {
+			duration := backoff.Duration(retry)
+			assert.GreaterOrEqual(t, duration, time.Duration(0))
+			assert.LessOrEqual(t, duration, ceiling)
+		}
+	}
+	assert.Equal(t, time.Duration(0), Backoff{}.Duration(3))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := NewCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	t.Run("closed until threshold", func(t *testing.T) {
+		breaker.Failure()
+
+		assert.NoError(t, breaker.Allow())
+		assert.Equal(t, BreakerClosed, breaker.State())
+	})
+	t.Run("opens at threshold", func(t *testing.T) {
+		breaker.Failure()
+
+		assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+		assert.Equal(t, BreakerOpen, breaker.State())
+		assert.Equal(t, uint64(1), breaker.Opened())
+	})
+	t.Run("single probe after timeout", func(t *testing.T) {
+		now = now.Add(time.Minute)
+
+		assert.NoError(t, breaker.Allow())
+		assert.Equal(t, BreakerHalfOpen, breaker.State())
+		assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+	})
+	t.Run("failed probe opens again", func(t *testing.T) {
+		breaker.Failure()
+
+		assert.Equal(t, BreakerOpen, breaker.State())
+		assert.Equal(t, uint64(2), breaker.Opened())
+	})
+	t.Run("successful probe closes", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		require.NoError(t, breaker.Allow())
+
+		breaker.Success()
+
+		assert.Equal(t, BreakerClosed, breaker.State())
+		assert.NoError(t, breaker.Allow())
+	})
+	t.Run("disabled", func(t *testing.T) {
+		breaker := NewCircuitBreaker(0, time.Minute)
+		for i := 0; i < 10; i++ {
+			breaker.Failure()
+		}
+
+		assert.NoError(t, breaker.Allow())
+	})
+}
+
+// flakyNode returns a test server that responds with the given status codes in order, followed by 200 OK
+func flakyNode(t *testing.T, statusCodes ...int) (*httptest.Server, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1)) - 1
+		if call < len(statusCodes) {
+			w.WriteHeader(statusCodes[call])
+			return
+		}
+		healthHandler(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, calls
+}
+
+func resilientClient(t *testing.T, url string, retry config.RetryConfig) HTTPClient {
+	client, err := NewHTTPClient(config.Config{NutsNodeAddr: url, NutsNodeRetry: retry})
+	require.NoError(t, err)
+	return client
+}
+
+func TestResilientDoer(t *testing.T) {
+	retry := config.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	t.Run("retries unavailable node", func(t *testing.T) {
+		ts, calls := flakyNode(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+		client := resilientClient(t, ts.URL, retry)
+
+		health, err := client.CheckHealth(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, "UP", health.Status)
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Equal(t, Metrics{Requests: 1, Retries: 2, BreakerState: "closed"}, client.Metrics())
+	})
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		ts, calls := flakyNode(t, http.StatusGatewayTimeout, http.StatusGatewayTimeout, http.StatusGatewayTimeout)
+		client := resilientClient(t, ts.URL, retry)
+
+		_, err := client.CheckHealth(context.Background())
+
+		assert.ErrorContains(t, err, "server returned HTTP 504")
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Equal(t, uint64(1), client.Metrics().Failures)
+	})
+	t.Run("other errors are not retried", func(t *testing.T) {
+		ts, calls := flakyNode(t, http.StatusInternalServerError)
+		client := resilientClient(t, ts.URL, retry)
+
+		_, err := client.CheckHealth(context.Background())
+
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+	t.Run("connection errors are retried", func(t *testing.T) {
+		ts, _ := flakyNode(t)
+		ts.Close()
+		client := resilientClient(t, ts.URL, retry)
+
+		_, err := client.CheckHealth(context.Background())
+
+		assert.Error(t, err)
+		assert.Equal(t, uint64(2), client.Metrics().Retries)
+	})
+	t.Run("cancelled requests are not retried", func(t *testing.T) {
+		ts, calls := flakyNode(t)
+		client := resilientClient(t, ts.URL, retry)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := client.CheckHealth(ctx)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, int32(0), calls.Load())
+		assert.Equal(t, uint64(0), client.Metrics().Retries)
+	})
+	t.Run("requests cancelled while waiting for a retry don't open the breaker", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			// the request is cancelled during the backoff, after the response is received
+			time.AfterFunc(50*time.Millisecond, cancel)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(ts.Close)
+		client := resilientClient(t, ts.URL, config.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour, BreakerThreshold: 1, BreakerTimeout: time.Hour})
+
+		_, err := client.CheckHealth(ctx)
+
+		assert.Error(t, err)
+		metrics := client.Metrics()
+		assert.Equal(t, uint64(1), metrics.Failures)
+		assert.Equal(t, "closed", metrics.BreakerState)
+	})
+	t.Run("circuit breaker fails fast", func(t *testing.T) {
+		ts, calls := flakyNode(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+		client := resilientClient(t, ts.URL, config.RetryConfig{MaxAttempts: 1, BreakerThreshold: 2, BreakerTimeout: time.Hour})
+
+		_, _ = client.CheckHealth(context.Background())
+		_, _ = client.CheckHealth(context.Background())
+		_, err := client.CheckHealth(context.Background())
+
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		assert.Equal(t, int32(2), calls.Load())
+		metrics := client.Metrics()
+		assert.Equal(t, "open", metrics.BreakerState)
+		assert.Equal(t, uint64(1), metrics.Rejected)
+		assert.Equal(t, uint64(1), metrics.BreakerOpened)
+	})
+}
@@ -69,8 +69,8 @@ func (t Tuple) equals(other Tuple) bool {
 		(t[0] == other[1] && t[1] == other[0])
 }
 
-// realPeerID removes the -bootstrap postfix
-func realPeerID(peerID string) string {
+// RealPeerID removes the -bootstrap postfix
+func RealPeerID(peerID string) string {
 	s := strings.Split(peerID, "-bootstrap")
 	return s[0]
 }
@@ -122,7 +122,7 @@ func (ts TopologyService) NetworkTopology(ctx context.Context) (NetworkTopology,
 
 	var certificate *x509.Certificate
 	for k, v := range peerDiagnostics {
-		peerID := realPeerID(k)
+		peerID := RealPeerID(k)
 		peer, ok := existingPeer(networkTopology.Peers, peerID)
 		if v.TransactionNum != nil {
 			peer.TransactionCount = int(*v.TransactionNum)
@@ -146,7 +146,7 @@ func (ts TopologyService) NetworkTopology(ctx context.Context) (NetworkTopology,
 		}
 		if v.Peers != nil {
 			for _, connectedPeer := range *v.Peers {
-				otherPeerID := realPeerID(connectedPeer)
+				otherPeerID := RealPeerID(connectedPeer)
 				t := Tuple([2]string{otherPeerID, peerID})
 				if !containsEdge(networkTopology.Edges, t) {
 					networkTopology.Edges = append(networkTopology.Edges, t)
@@ -0,0 +1,84 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"context"
+	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/test"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopologyService_NetworkTopology(t *testing.T) {
+	simulator := test.NewSimulator(t)
+	simulator.PeerID = "us"
+	nodeDID := simulator.CreateDID(t)
+	simulator.AddService(t, nodeDID, "node-contact-info", map[string]string{"name": "Hospital", "email": "info@hospital.example.com"})
+	simulator.AddPeer(t, test.SimulatedPeer{
+		ID:               "them",
+		Address:          "them.example.com:5555",
+		NodeDID:          nodeDID.ID,
+		TransactionCount: 1,
+		Peers:            []string{"us", "friend-bootstrap"},
+		CommonName:       "them.example.com",
+		SoftwareVersion:  "v5.4.0",
+	})
+	httpClient, err := NewHTTPClient(config.Config{NutsNodeAddr: simulator.URL()})
+	require.NoError(t, err)
+	service := TopologyService{HTTPClient: httpClient}
+
+	t.Run("ok", func(t *testing.T) {
+		topology, err := service.NetworkTopology(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, "us", topology.PeerID)
+		assert.Equal(t, 2, topology.TxCount)
+		require.Len(t, topology.Peers, 3)
+		them := topology.Peers[1]
+		assert.Equal(t, "them", them.PeerID)
+		assert.Equal(t, "CN=them.example.com", them.CN)
+		assert.Equal(t, "them.example.com:5555", them.Address)
+		assert.True(t, them.Authenticated)
+		assert.Equal(t, "v5.4.0", them.SoftwareVersion)
+		assert.Equal(t, "Hospital", them.ContactName)
+		assert.Equal(t, "info@hospital.example.com", them.ContactEmail)
+		assert.Equal(t, "friend", topology.Peers[2].PeerID)
+		assert.ElementsMatch(t, []Tuple{{"us", "them"}, {"friend", "them"}}, topology.Edges)
+	})
+	t.Run("contact info isn't available", func(t *testing.T) {
+		simulator.Fail("/internal/vdr/v1/did/", test.MalformedJSON())
+		defer simulator.Reset()
+
+		topology, err := service.NetworkTopology(context.Background())
+
+		require.NoError(t, err)
+		assert.Empty(t, topology.Peers[1].ContactName)
+	})
+	t.Run("diagnostics fail", func(t *testing.T) {
+		simulator.Fail("/status/diagnostics", test.ServerError(502))
+		defer simulator.Reset()
+
+		_, err := service.NetworkTopology(context.Background())
+
+		assert.Error(t, err)
+	})
+}
@@ -20,6 +20,7 @@ package config
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"encoding/json"
 	"errors"
@@ -27,8 +28,10 @@ import (
 	"golang.org/x/crypto/ssh"
 	"io"
 	"log"
+	"net"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/knadh/koanf"
 	"github.com/knadh/koanf/parsers/yaml"
@@ -41,14 +44,77 @@ import (
 const defaultPrefix = "NUTS_"
 const defaultDelimiter = "."
 const configFileFlag = "configfile"
+const validateConfigFlag = "validate-config"
 const defaultConfigFile = "server.config.yaml"
 const defaultNutsNodeAddress = "http://localhost:1323"
 const defaultNutsNodeStreamAddress = "nats://localhost:4222"
+const defaultConsistencyInterval = time.Minute
+const defaultConsistencyThreshold = 10
+const defaultConsistencyGracePeriod = 10 * time.Minute
+const defaultClockDrift = 5 * time.Second
+const defaultClockSkewMaxFuture = time.Minute
+const defaultClockSkewMaxPast = time.Hour
+const defaultKeyDormancy = 30 * 24 * time.Hour
+const defaultMassRevocationThreshold = 100
+const defaultMassRevocationWindow = time.Hour
+const defaultAnomalyThreshold = 3
+const defaultAnomalyMinCount = 10
+const defaultDAGRenderMaxRange = 1000
+const defaultNodeName = "default"
+const defaultServerAddress = ":1313"
+const defaultNutsNodeTimeout = 10 * time.Second
+const defaultNutsNodeConnectTimeout = 5 * time.Second
+const defaultRetryMaxAttempts = 3
+const defaultRetryInitialBackoff = 200 * time.Millisecond
+const defaultRetryMaxBackoff = 5 * time.Second
+const defaultBreakerThreshold = 5
+const defaultBreakerTimeout = 30 * time.Second
+const defaultRequestsPerSecond = 50
+const defaultMaxInFlight = 10
+const defaultNutsNodeAPITokenLifetime = 5 * time.Minute
+const defaultMockNodeAddress = "localhost:1323"
+const defaultMockNodeStreamAddress = "localhost:4222"
+const defaultMockNodeTransactionInterval = 2 * time.Second
 
 func defaultConfig() Config {
 	return Config{
-		NutsNodeAddr:       defaultNutsNodeAddress,
-		NutsNodeStreamAddr: defaultNutsNodeStreamAddress,
+		NutsNodeAddr:             defaultNutsNodeAddress,
+		NutsNodeStreamAddr:       defaultNutsNodeStreamAddress,
+		NutsNodeTimeout:          defaultNutsNodeTimeout,
+		NutsNodeConnectTimeout:   defaultNutsNodeConnectTimeout,
+		NutsNodeAPITokenLifetime: defaultNutsNodeAPITokenLifetime,
+		NutsNodeRetry: RetryConfig{
+			MaxAttempts:      defaultRetryMaxAttempts,
+			InitialBackoff:   defaultRetryInitialBackoff,
+			MaxBackoff:       defaultRetryMaxBackoff,
+			BreakerThreshold: defaultBreakerThreshold,
+			BreakerTimeout:   defaultBreakerTimeout,
+		},
+		NutsNodeLimits: LimitConfig{
+			RequestsPerSecond: defaultRequestsPerSecond,
+			Burst:             defaultRequestsPerSecond,
+			MaxInFlight:       defaultMaxInFlight,
+		},
+		ConsistencyInterval:     defaultConsistencyInterval,
+		ConsistencyThreshold:    defaultConsistencyThreshold,
+		ConsistencyGracePeriod:  defaultConsistencyGracePeriod,
+		ClockDrift:              defaultClockDrift,
+		ClockSkewMaxFuture:      defaultClockSkewMaxFuture,
+		ClockSkewMaxPast:        defaultClockSkewMaxPast,
+		KeyDormancy:             defaultKeyDormancy,
+		MassRevocationThreshold: defaultMassRevocationThreshold,
+		MassRevocationWindow:    defaultMassRevocationWindow,
+		AnomalyThreshold:        defaultAnomalyThreshold,
+		AnomalyMinCount:         defaultAnomalyMinCount,
+		DAGRenderMaxRange:       defaultDAGRenderMaxRange,
+		MockNode: MockNodeConfig{
+			Address:             defaultMockNodeAddress,
+			StreamAddress:       defaultMockNodeStreamAddress,
+			TransactionInterval: defaultMockNodeTransactionInterval,
+		},
+		Server: ServerConfig{
+			Address: defaultServerAddress,
+		},
 	}
 }
 
@@ -60,23 +126,268 @@ type Config struct {
 	// NutsNodeInternalAddr contains the address of the Nuts node for calls to /internal endpoints, in case these are bound to a separate HTTP interface.
 	// If empty, NutsNodeAddr is used.
 	NutsNodeInternalAddr string `koanf:"nutsnodeinternaladdr"`
+	// NutsNodeNetwork names the Nuts network of the node. Only nodes of the same network are expected to have the same DAG
+	NutsNodeNetwork string `koanf:"nutsnodenetwork"`
 	// NutsNodeAPIKeyFile points to the private key used to sign JWTs. If empty Nuts node API security is not enabled
 	NutsNodeAPIKeyFile string `koanf:"nutsnodeapikeyfile"`
 	// NutsNodeAPIUser contains the API key user that will go into the iss field. It must match the user with the public key from the authorized_keys file in the Nuts node
 	NutsNodeAPIUser string `koanf:"nutsnodeapiuser"`
 	// NutsNodeAPIAudience dictates the aud field of the created JWT
-	NutsNodeAPIAudience string `kaonf:"nutsnodeapiaudience"`
-	ApiKey              crypto.Signer
-	// WithMockNode enables the mock Nuts node
+	NutsNodeAPIAudience string `koanf:"nutsnodeapiaudience"`
+	// NutsNodeAPIAlgorithm overrides the JWT signing algorithm, e.g. RS256 for RSA keys. If empty, it's derived from the key type
+	NutsNodeAPIAlgorithm string `koanf:"nutsnodeapialgorithm"`
+	// NutsNodeAPITokenLifetime is the validity of the created JWTs
+	NutsNodeAPITokenLifetime time.Duration `koanf:"nutsnodeapitokenlifetime"`
+	// NutsNodeAPIClaims are added to the created JWTs, they can't replace the standard claims
+	NutsNodeAPIClaims map[string]interface{} `koanf:"nutsnodeapiclaims"`
+	// NutsNodeAPITokenFile points to a file with a pre-issued bearer token. It can't be combined with nutsnodeapikeyfile
+	NutsNodeAPITokenFile string `koanf:"nutsnodeapitokenfile"`
+	ApiKey               crypto.Signer
+	// NutsNodeTLS configures TLS for calls to the Nuts node, e.g. when its API is behind mTLS or uses a private CA
+	NutsNodeTLS NodeTLSConfig `koanf:"nutsnodetls"`
+	// NutsNodeTimeout is the maximum duration of a request to the Nuts node
+	NutsNodeTimeout time.Duration `koanf:"nutsnodetimeout"`
+	// NutsNodeConnectTimeout is the maximum duration for setting up a connection, including the TLS handshake, to the Nuts node
+	NutsNodeConnectTimeout time.Duration `koanf:"nutsnodeconnecttimeout"`
+	// NutsNodeRetry configures retries and the circuit breaker for calls to the Nuts node
+	NutsNodeRetry RetryConfig `koanf:"nutsnoderetry"`
+	// NutsNodeLimits limits the load the monitor puts on the Nuts node
+	NutsNodeLimits LimitConfig `koanf:"nutsnodelimits"`
+	// WithMockNode starts an in-process mock Nuts node and monitors it instead of the configured nodes
 	WithMockNode bool `koanf:"withmocknode"`
+	// MockNode configures the mock Nuts node that is started with WithMockNode
+	MockNode MockNodeConfig `koanf:"mocknode"`
+	// ConsistencyInterval is the interval at which the transaction counts of peers are compared to our own
+	ConsistencyInterval time.Duration `koanf:"consistencyinterval"`
+	// ConsistencyThreshold is the number of transactions a peer may lag or lead before it's considered out of sync
+	ConsistencyThreshold int `koanf:"consistencythreshold"`
+	// ConsistencyGracePeriod is the time a peer may be out of sync before it's flagged as diverged
+	ConsistencyGracePeriod time.Duration `koanf:"consistencygraceperiod"`
+	// ClockDrift is subtracted from the signature time of transactions before they're counted, so transactions of signers with a clock that runs slightly ahead are counted
+	ClockDrift time.Duration `koanf:"clockdrift"`
+	// ClockSkewMaxFuture is the time a signature time may be after the arrival of a transaction, before the signer is reported for clock skew. 0 disables the check
+	ClockSkewMaxFuture time.Duration `koanf:"clockskewmaxfuture"`
+	// ClockSkewMaxPast is the time a signature time may be before the arrival of a transaction from the NATS stream, before the signer is reported for clock skew. 0 disables the check
+	ClockSkewMaxPast time.Duration `koanf:"clockskewmaxpast"`
+	// KeyDormancy is the time a signing key must be unused before it's reported as reactivated when it signs again. 0 disables the check
+	KeyDormancy time.Duration `koanf:"keydormancy"`
+	// MassRevocationThreshold is the number of credentials an issuer may revoke within MassRevocationWindow before it's reported for a mass revocation. 0 disables the check
+	MassRevocationThreshold int `koanf:"massrevocationthreshold"`
+	// MassRevocationWindow is the time in which the revocations of a mass revocation are signed
+	MassRevocationWindow time.Duration `koanf:"massrevocationwindow"`
+	// AnomalyThreshold is the number of standard deviations the hourly transaction count of a content type or root DID must differ from its baseline to be reported as an anomaly. 0 disables the detection
+	AnomalyThreshold float64 `koanf:"anomalythreshold"`
+	// AnomalyMinCount is the minimum hourly count of a spike and the minimum baseline of a silence
+	AnomalyMinCount int `koanf:"anomalymincount"`
+	// DAGRenderMaxRange is the maximum number of LC values that can be rendered in a single DAG request
+	DAGRenderMaxRange int `koanf:"dagrendermaxrange"`
+	// Nodes contains the Nuts nodes to monitor. If empty, the single node configured by the nutsnode* parameters is monitored.
+	Nodes []NodeConfig `koanf:"nodes"`
+	// Auth configures authentication for the web UI and API of the monitor. If empty, authentication is disabled.
+	Auth AuthConfig `koanf:"auth"`
+	// Server configures the HTTP server of the monitor
+	Server ServerConfig `koanf:"server"`
+
+	// configFile is the path of the loaded config file
+	configFile string
+	// sources contains the source of the options that are not defaults
+	sources keySources
+	// flagset contains the command line flags the config was loaded with, they are used again on reload
+	flagset *pflag.FlagSet
+}
+
+// MockNodeConfig configures the in-process mock Nuts node
+type MockNodeConfig struct {
+	// Address is the address the HTTP API of the mock node listens on, in host:port form. Defaults to "localhost:1323"
+	Address string `koanf:"address"`
+	// StreamAddress is the address the NATS server of the mock node listens on, in host:port form. Defaults to "localhost:4222"
+	StreamAddress string `koanf:"streamaddress"`
+	// TransactionInterval is the interval at which the mock node publishes a new transaction. Defaults to 2s
+	TransactionInterval time.Duration `koanf:"transactioninterval"`
+}
+
+// ServerConfig configures the listeners of the HTTP server of the monitor
+type ServerConfig struct {
+	// Address is the address the web UI and API listen on, in host:port form. Defaults to ":1313"
+	Address string `koanf:"address"`
+	// InternalAddress is the address for /status and /health. If set, these endpoints are only available on this address.
+	InternalAddress string `koanf:"internaladdress"`
+	// TLS configures HTTPS for the web UI and API
+	TLS ServerTLSConfig `koanf:"tls"`
+}
+
+// ServerTLSConfig configures HTTPS for the HTTP server of the monitor
+type ServerTLSConfig struct {
+	// CertFile is the PEM encoded certificate (chain), it's reloaded when the file changes
+	CertFile string `koanf:"certfile"`
+	// CertKeyFile is the PEM encoded private key of the certificate
+	CertKeyFile string `koanf:"certkeyfile"`
+	// ClientCAFile is a PEM encoded CA bundle. If set, clients must present a certificate issued by one of these CAs (mTLS)
+	ClientCAFile string `koanf:"clientcafile"`
+}
+
+// Enabled returns true if a certificate is configured
+func (t ServerTLSConfig) Enabled() bool {
+	return len(t.CertFile) > 0
 }
 
+// AuthConfig contains the authentication methods for the web UI and API. Multiple methods can be enabled at the same time.
+type AuthConfig struct {
+	// Tokens contains static bearer tokens
+	Tokens []TokenConfig `koanf:"tokens"`
+	// Users contains users for HTTP basic authentication
+	Users []UserConfig `koanf:"users"`
+	// OIDC configures login using an OpenID Connect provider
+	OIDC OIDCConfig `koanf:"oidc"`
+}
+
+// Enabled returns true if any authentication method is configured
+func (a AuthConfig) Enabled() bool {
+	return len(a.Tokens) > 0 || len(a.Users) > 0 || a.OIDC.Enabled()
+}
+
+// TokenConfig contains a static bearer token and the role it grants
+type TokenConfig struct {
+	// Name identifies the token holder in logs
+	Name string `koanf:"name"`
+	// Token is the bearer token
+	Token string `koanf:"token" redact:"true"`
+	// Role is either viewer (default) or admin
+	Role string `koanf:"role"`
+}
+
+// UserConfig contains a user for HTTP basic authentication
+type UserConfig struct {
+	Username string `koanf:"username"`
+	// PasswordHash is the bcrypt hash of the password
+	PasswordHash string `koanf:"passwordhash" redact:"true"`
+	// Role is either viewer (default) or admin
+	Role string `koanf:"role"`
+}
+
+// OIDCConfig configures login using an OpenID Connect provider with the authorization code flow
+type OIDCConfig struct {
+	// Issuer is the URL of the OpenID provider, it's used for discovery
+	Issuer       string `koanf:"issuer"`
+	ClientID     string `koanf:"clientid"`
+	ClientSecret string `koanf:"clientsecret" redact:"true"`
+	// RedirectURL is the public URL of the /auth/callback endpoint of the monitor
+	RedirectURL string `koanf:"redirecturl"`
+	// RolesClaim is the ID token claim that contains the roles of the user, defaults to "roles"
+	RolesClaim string `koanf:"rolesclaim"`
+	// AdminRole is the value in the roles claim that grants the admin role, defaults to "admin"
+	AdminRole string `koanf:"adminrole"`
+}
+
+// Enabled returns true if OIDC login is configured
+func (o OIDCConfig) Enabled() bool {
+	return len(o.Issuer) > 0
+}
+
+// RetryConfig configures retries and the circuit breaker for calls to a Nuts node
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of attempts for GET requests, including the first one
+	MaxAttempts int `koanf:"maxattempts"`
+	// InitialBackoff is the maximum wait time before the first retry, it doubles with every retry
+	InitialBackoff time.Duration `koanf:"initialbackoff"`
+	// MaxBackoff caps the wait time between retries
+	MaxBackoff time.Duration `koanf:"maxbackoff"`
+	// BreakerThreshold is the number of consecutive failed requests after which the node is considered down. 0 disables the circuit breaker
+	BreakerThreshold int `koanf:"breakerthreshold"`
+	// BreakerTimeout is the time requests fail fast after the node is considered down, before a new request is tried
+	BreakerTimeout time.Duration `koanf:"breakertimeout"`
+}
+
+// LimitConfig limits the number of requests to a Nuts node
+type LimitConfig struct {
+	// RequestsPerSecond is the maximum average number of requests per second. 0 disables the limit
+	RequestsPerSecond float64 `koanf:"requestspersecond"`
+	// Burst is the number of requests that may be sent at once when the limit hasn't been reached for a while
+	Burst int `koanf:"burst"`
+	// MaxInFlight is the maximum number of concurrent requests. 0 disables the limit
+	MaxInFlight int `koanf:"maxinflight"`
+}
+
+// NodeTLSConfig configures TLS for calls to a Nuts node
+type NodeTLSConfig struct {
+	// CertFile is the PEM encoded client certificate, used when the node requires mTLS
+	CertFile string `koanf:"certfile"`
+	// CertKeyFile is the PEM encoded private key of the client certificate
+	CertKeyFile string `koanf:"certkeyfile"`
+	// TrustStoreFile is a PEM encoded CA bundle used to verify the certificate of the node. If empty, the system CAs are used
+	TrustStoreFile string `koanf:"truststorefile"`
+	// ServerName overrides the name used to verify the certificate of the node
+	ServerName string `koanf:"servername"`
+}
+
+// IsZero returns true if no TLS settings are configured
+func (t NodeTLSConfig) IsZero() bool {
+	return t == NodeTLSConfig{}
+}
+
+// NodeConfig contains the connection settings of a single monitored Nuts node
+type NodeConfig struct {
+	// Name identifies the node in the API
+	Name string `koanf:"name"`
+	// Addr contains the address of the Nuts node
+	Addr string `koanf:"address"`
+	// InternalAddr contains the address of the Nuts node for calls to /internal endpoints. If empty, Addr is used.
+	InternalAddr string `koanf:"internaladdress"`
+	// Network names the Nuts network of the node. If empty, nutsnodenetwork is used
+	Network string `koanf:"network"`
+	// StreamAddr contains the NATS address of the Nuts node
+	StreamAddr string `koanf:"streamaddress"`
+	// APIKeyFile points to the private key used to sign JWTs. If empty Nuts node API security is not enabled
+	APIKeyFile string `koanf:"apikeyfile"`
+	// APIUser contains the API key user that will go into the iss field
+	APIUser string `koanf:"apiuser"`
+	// APIAudience dictates the aud field of the created JWT
+	APIAudience string `koanf:"apiaudience"`
+	// APIAlgorithm overrides the JWT signing algorithm. If empty, nutsnodeapialgorithm is used
+	APIAlgorithm string `koanf:"apialgorithm"`
+	// APITokenLifetime is the validity of the created JWTs. If empty, nutsnodeapitokenlifetime is used
+	APITokenLifetime time.Duration `koanf:"apitokenlifetime"`
+	// APIClaims are added to the created JWTs. If empty, nutsnodeapiclaims is used
+	APIClaims map[string]interface{} `koanf:"apiclaims"`
+	// APITokenFile points to a file with a pre-issued bearer token. It can't be combined with apikeyfile
+	APITokenFile string `koanf:"apitokenfile"`
+	ApiKey       crypto.Signer
+	// TLS configures TLS for calls to the node. If empty, nutsnodetls is used
+	TLS NodeTLSConfig `koanf:"tls"`
+	// Timeout is the maximum duration of a request to the node. If empty, nutsnodetimeout is used
+	Timeout time.Duration `koanf:"timeout"`
+	// ConnectTimeout is the maximum duration for setting up a connection to the node. If empty, nutsnodeconnecttimeout is used
+	ConnectTimeout time.Duration `koanf:"connecttimeout"`
+}
+
+// ForNode returns a copy of the config where the Nuts node settings are replaced by those of the given node
+func (c Config) ForNode(node NodeConfig) Config {
+	nodeConfig := c
+	nodeConfig.NutsNodeAddr = node.Addr
+	nodeConfig.NutsNodeInternalAddr = node.InternalAddr
+	nodeConfig.NutsNodeStreamAddr = node.StreamAddr
+	nodeConfig.NutsNodeNetwork = node.Network
+	nodeConfig.NutsNodeAPIKeyFile = node.APIKeyFile
+	nodeConfig.NutsNodeAPIUser = node.APIUser
+	nodeConfig.NutsNodeAPIAudience = node.APIAudience
+	nodeConfig.NutsNodeAPIAlgorithm = node.APIAlgorithm
+	nodeConfig.NutsNodeAPITokenLifetime = node.APITokenLifetime
+	nodeConfig.NutsNodeAPIClaims = node.APIClaims
+	nodeConfig.NutsNodeAPITokenFile = node.APITokenFile
+	nodeConfig.ApiKey = node.ApiKey
+	nodeConfig.NutsNodeTLS = node.TLS
+	nodeConfig.NutsNodeTimeout = node.Timeout
+	nodeConfig.NutsNodeConnectTimeout = node.ConnectTimeout
+	nodeConfig.Nodes = nil
+	return nodeConfig
+}
+
+// Print writes the config as JSON, with secret options redacted
 func (c Config) Print(writer io.Writer) error {
 	if _, err := fmt.Fprintln(writer, "========== CONFIG: =========="); err != nil {
 		return err
 	}
-	var pr Config = c
-	data, _ := json.MarshalIndent(pr, "", "  ")
+	data, _ := json.MarshalIndent(c.Map(), "", "  ")
 	if _, err := fmt.Fprintln(writer, string(data)); err != nil {
 		return err
 	}
@@ -86,61 +397,183 @@ func (c Config) Print(writer io.Writer) error {
 	return nil
 }
 
+// LoadConfig loads the config from the config file and the environment. It exits when the config is invalid.
+// With --validate-config it only reports whether the config is valid and exits.
 func LoadConfig() Config {
 	flagset := loadFlagSet(os.Args[1:])
+	config, err := load(flagset)
 
+	if validateOnly, _ := flagset.GetBool(validateConfigFlag); validateOnly {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "config is invalid:\n%s\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("config is valid")
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("invalid config:\n%s", err)
+	}
+	return config
+}
+
+// load loads and validates the config, all validation problems are returned in a single error
+func load(flagset *pflag.FlagSet) (Config, error) {
 	var k = koanf.New(defaultDelimiter)
+	sources := keySources{}
 
 	// Prepare koanf for parsing the config file
-	configFilePath := resolveConfigFile(flagset)
+	configFilePath, configFileSource := resolveConfigFile(flagset)
 	// Check if the file exists
 	if _, err := os.Stat(configFilePath); err == nil {
 		log.Printf("Loading config from file: %s", configFilePath)
-		if err := k.Load(file.Provider(configFilePath), yaml.Parser()); err != nil {
-			log.Fatalf("error while loading config from file: %v", err)
+		fileK := koanf.New(defaultDelimiter)
+		if err := fileK.Load(file.Provider(configFilePath), yaml.Parser()); err != nil {
+			return Config{}, fmt.Errorf("error while loading config from file: %w", err)
 		}
+		sources.add(SourceFile, fileK.Keys())
+		_ = k.Merge(fileK)
 	} else {
 		log.Printf("Using default config because no file was found at: %s", configFilePath)
 	}
 
 	// load env flags, can't return error
-	_ = k.Load(envProvider(), nil)
+	envK := koanf.New(defaultDelimiter)
+	_ = envK.Load(envProvider(), nil)
+	sources.add(SourceEnv, envK.Keys())
+	_ = k.Merge(envK)
+	sources[configFileFlag] = configFileSource
 
 	config := defaultConfig()
+	config.configFile = configFilePath
+	config.sources = sources
+	config.flagset = flagset
 
 	// Unmarshal values of the config file into the config struct, potentially replacing default values
 	if err := k.Unmarshal("", &config); err != nil {
-		log.Fatalf("error while unmarshalling config: %v", err)
+		return config, fmt.Errorf("error while unmarshalling config: %w", err)
 	}
 
-	// Load the API key
-	if len(config.NutsNodeAPIKeyFile) > 0 {
-		bytes, err := os.ReadFile(config.NutsNodeAPIKeyFile)
-		if err != nil {
-			log.Fatalf("error while reading private key file: %v", err)
+	if config.WithMockNode {
+		config.useMockNode()
+	}
+
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+
+	return config, loadNodes(&config)
+}
+
+// useMockNode points the nutsnode* parameters to the mock node and removes the configured nodes and their API security.
+func (c *Config) useMockNode() {
+	c.NutsNodeAddr = "http://" + localAddress(c.MockNode.Address)
+	c.NutsNodeInternalAddr = ""
+	c.NutsNodeStreamAddr = "nats://" + localAddress(c.MockNode.StreamAddress)
+	c.NutsNodeAPIKeyFile = ""
+	c.NutsNodeAPITokenFile = ""
+	c.NutsNodeTLS = NodeTLSConfig{}
+	c.Nodes = nil
+}
+
+// localAddress returns the address to connect to for a listen address, an empty host is replaced by localhost
+func localAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || len(host) > 0 {
+		return address
+	}
+	return net.JoinHostPort("localhost", port)
+}
+
+// loadNodes fills the list of nodes from the nutsnode* parameters if no nodes are configured.
+// Nodes inherit the TLS, timeout and token settings of the nutsnode* parameters. It also loads the API keys.
+// The config must be validated before.
+func loadNodes(config *Config) error {
+	if len(config.Nodes) == 0 {
+		config.Nodes = []NodeConfig{{
+			Name:             defaultNodeName,
+			Addr:             config.NutsNodeAddr,
+			InternalAddr:     config.NutsNodeInternalAddr,
+			StreamAddr:       config.NutsNodeStreamAddr,
+			Network:          config.NutsNodeNetwork,
+			APIKeyFile:       config.NutsNodeAPIKeyFile,
+			APIUser:          config.NutsNodeAPIUser,
+			APIAudience:      config.NutsNodeAPIAudience,
+			APIAlgorithm:     config.NutsNodeAPIAlgorithm,
+			APITokenLifetime: config.NutsNodeAPITokenLifetime,
+			APIClaims:        config.NutsNodeAPIClaims,
+			APITokenFile:     config.NutsNodeAPITokenFile,
+			TLS:              config.NutsNodeTLS,
+			Timeout:          config.NutsNodeTimeout,
+			ConnectTimeout:   config.NutsNodeConnectTimeout,
+		}}
+		if len(config.NutsNodeAPIKeyFile) > 0 {
+			key, err := loadAPIKey(config.NutsNodeAPIKeyFile)
+			if err != nil {
+				return fmt.Errorf("nutsnodeapikeyfile: %w", err)
+			}
+			config.ApiKey = key
+			config.Nodes[0].ApiKey = key
 		}
-		config.ApiKey, err = pemToPrivateKey(bytes)
-		if err != nil {
-			log.Fatalf("error while decoding private key file: %v", err)
+		return nil
+	}
+
+	var errs []error
+	for i := range config.Nodes {
+		node := &config.Nodes[i]
+		if len(node.StreamAddr) == 0 {
+			node.StreamAddr = defaultNutsNodeStreamAddress
+		}
+		if len(node.Network) == 0 {
+			node.Network = config.NutsNodeNetwork
+		}
+		if node.TLS.IsZero() {
+			node.TLS = config.NutsNodeTLS
+		}
+		if node.Timeout == 0 {
+			node.Timeout = config.NutsNodeTimeout
+		}
+		if node.ConnectTimeout == 0 {
+			node.ConnectTimeout = config.NutsNodeConnectTimeout
 		}
-		if len(config.NutsNodeAPIUser) == 0 {
-			log.Fatal("nutsnodeapiuser config is required with nutsnodeapikeyfile")
+		if len(node.APIAlgorithm) == 0 {
+			node.APIAlgorithm = config.NutsNodeAPIAlgorithm
 		}
-		if len(config.NutsNodeAPIAudience) == 0 {
-			log.Fatal("nutsnodeapiaudience config is required with nutsnodeapikeyfile")
+		if node.APITokenLifetime == 0 {
+			node.APITokenLifetime = config.NutsNodeAPITokenLifetime
+		}
+		if len(node.APIClaims) == 0 {
+			node.APIClaims = config.NutsNodeAPIClaims
+		}
+		if len(node.APIKeyFile) > 0 {
+			key, err := loadAPIKey(node.APIKeyFile)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("nodes[%d].apikeyfile: %w", i, err))
+				continue
+			}
+			node.ApiKey = key
 		}
 	}
+	return errors.Join(errs...)
+}
 
-	if k.Bool("withmocknode") {
-		config.WithMockNode = true
+// loadAPIKey reads the PEM encoded private key used to sign API tokens
+func loadAPIKey(path string) (crypto.Signer, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading private key file: %w", err)
 	}
-
-	return config
+	key, err := pemToPrivateKey(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error while decoding private key file: %w", err)
+	}
+	return key, nil
 }
 
 func loadFlagSet(args []string) *pflag.FlagSet {
 	f := pflag.NewFlagSet("config", pflag.ContinueOnError)
 	f.String(configFileFlag, defaultConfigFile, "Nuts monitor config file")
+	f.Bool(validateConfigFlag, false, "Validate the config, print the result and exit")
 	f.Usage = func() {
 		fmt.Println(f.FlagUsages())
 		os.Exit(0)
@@ -153,7 +586,8 @@ func loadFlagSet(args []string) *pflag.FlagSet {
 // 1. commandline params (using the given flags)
 // 2. environment vars,
 // 3. default location.
-func resolveConfigFile(flagset *pflag.FlagSet) string {
+// It also returns the source of the path.
+func resolveConfigFile(flagset *pflag.FlagSet) (string, string) {
 
 	k := koanf.New(defaultDelimiter)
 
@@ -164,7 +598,13 @@ func resolveConfigFile(flagset *pflag.FlagSet) string {
 	_ = k.Load(posflag.Provider(flagset, defaultDelimiter, k), nil)
 
 	configFile := k.String(configFileFlag)
-	return configFile
+	switch {
+	case flagset.Changed(configFileFlag):
+		return configFile, SourceFlag
+	case os.Getenv(defaultPrefix+strings.ToUpper(configFileFlag)) != "":
+		return configFile, SourceEnv
+	}
+	return configFile, SourceDefault
 }
 
 func envProvider() *env.Env {
@@ -174,7 +614,7 @@ func envProvider() *env.Env {
 	})
 }
 
-// pemToPrivateKey converts a PEM encoded private key to a Signer interface. It supports EC, RSA and PKIX PEM encoded strings
+// pemToPrivateKey converts a PEM encoded private key to a Signer interface. It supports EC, RSA, Ed25519 and PKIX PEM encoded strings
 func pemToPrivateKey(bytes []byte) (signer crypto.Signer, err error) {
 	key, _ := ssh.ParseRawPrivateKey(bytes)
 	if key == nil {
@@ -187,6 +627,11 @@ func pemToPrivateKey(bytes []byte) (signer crypto.Signer, err error) {
 		signer = k
 	case *ecdsa.PrivateKey:
 		signer = k
+	case ed25519.PrivateKey:
+		signer = k
+	case *ed25519.PrivateKey:
+		// OpenSSH keys are returned as pointer
+		signer = *k
 	default:
 		err = fmt.Errorf("unsupported private key type: %T", k)
 	}
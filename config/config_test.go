@@ -19,9 +19,17 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestConfig_loadConfig(t *testing.T) {
@@ -32,3 +40,138 @@ func TestConfig_loadConfig(t *testing.T) {
 
 	assert.Equal(t, "http://example.com", cfg.NutsNodeAddr)
 }
+
+func TestConfig_loadNodes(t *testing.T) {
+	t.Run("defaults to the nutsnode parameters", func(t *testing.T) {
+		os.Setenv("NUTS_CONFIGFILE", "../test/test.config.yaml")
+		defer os.Clearenv()
+
+		cfg := LoadConfig()
+
+		require.Len(t, cfg.Nodes, 1)
+		assert.Equal(t, "default", cfg.Nodes[0].Name)
+		assert.Equal(t, "http://example.com", cfg.Nodes[0].Addr)
+	})
+	t.Run("from the nodes list", func(t *testing.T) {
+		os.Setenv("NUTS_CONFIGFILE", "../test/nodes.config.yaml")
+		defer os.Clearenv()
+
+		cfg := LoadConfig()
+
+		require.Len(t, cfg.Nodes, 2)
+		assert.Equal(t, "acceptance", cfg.Nodes[0].Name)
+		assert.Equal(t, defaultNutsNodeStreamAddress, cfg.Nodes[0].StreamAddr)
+		assert.Equal(t, "development", cfg.ForNode(cfg.Nodes[0]).NutsNodeNetwork)
+		production := cfg.ForNode(cfg.Nodes[1])
+		assert.Equal(t, "http://production.example.com", production.NutsNodeAddr)
+		assert.Equal(t, "http://production.internal:8081", production.NutsNodeInternalAddr)
+		assert.Equal(t, "production", production.NutsNodeNetwork)
+		assert.Equal(t, "nats://production.example.com:4222", production.NutsNodeStreamAddr)
+		assert.Equal(t, 30*time.Second, production.NutsNodeTimeout)
+		assert.Equal(t, defaultNutsNodeConnectTimeout, production.NutsNodeConnectTimeout)
+		assert.Equal(t, "production.internal", production.NutsNodeTLS.ServerName)
+		assert.Equal(t, time.Minute, production.NutsNodeAPITokenLifetime)
+		assert.Equal(t, map[string]interface{}{"tenant": "example"}, production.NutsNodeAPIClaims)
+		assert.Equal(t, defaultNutsNodeAPITokenLifetime, cfg.ForNode(cfg.Nodes[0]).NutsNodeAPITokenLifetime)
+	})
+	t.Run("TLS and timeouts default to the nutsnode parameters", func(t *testing.T) {
+		cfg := Config{
+			NutsNodeTLS:     NodeTLSConfig{TrustStoreFile: "ca.pem"},
+			NutsNodeTimeout: time.Second,
+			Nodes:           []NodeConfig{{Name: "a", Addr: "http://a"}},
+		}
+
+		err := loadNodes(&cfg)
+
+		require.NoError(t, err)
+		assert.Equal(t, "ca.pem", cfg.Nodes[0].TLS.TrustStoreFile)
+		assert.Equal(t, time.Second, cfg.Nodes[0].Timeout)
+	})
+	t.Run("error on invalid API key files", func(t *testing.T) {
+		keyFile := filepath.Join(t.TempDir(), "key.pem")
+		require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0600))
+		cfg := Config{Nodes: []NodeConfig{{Name: "a", Addr: "http://a", APIKeyFile: keyFile}, {Name: "b", Addr: "http://b", APIKeyFile: keyFile}}}
+
+		err := loadNodes(&cfg)
+
+		assert.EqualError(t, err, "nodes[0].apikeyfile: error while decoding private key file: failed to decode PEM file\n"+
+			"nodes[1].apikeyfile: error while decoding private key file: failed to decode PEM file")
+	})
+}
+
+func TestConfig_load(t *testing.T) {
+	t.Run("API security from the environment", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+		keyFile := filepath.Join(t.TempDir(), "key.pem")
+		require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+		t.Setenv("NUTS_CONFIGFILE", "../test/test.config.yaml")
+		t.Setenv("NUTS_NUTSNODEAPIKEYFILE", keyFile)
+		t.Setenv("NUTS_NUTSNODEAPIUSER", "monitor")
+		t.Setenv("NUTS_NUTSNODEAPIAUDIENCE", "nuts-node")
+
+		cfg, err := load(loadFlagSet(nil))
+
+		require.NoError(t, err)
+		assert.Equal(t, "nuts-node", cfg.NutsNodeAPIAudience)
+		assert.Equal(t, key, cfg.ForNode(cfg.Nodes[0]).ApiKey)
+	})
+	t.Run("mock node", func(t *testing.T) {
+		t.Setenv("NUTS_CONFIGFILE", "../test/nodes.config.yaml")
+		t.Setenv("NUTS_WITHMOCKNODE", "true")
+		t.Setenv("NUTS_MOCKNODE_ADDRESS", ":8080")
+
+		cfg, err := load(loadFlagSet(nil))
+
+		require.NoError(t, err)
+		require.Len(t, cfg.Nodes, 1)
+		assert.Equal(t, "http://localhost:8080", cfg.Nodes[0].Addr)
+		assert.Equal(t, "nats://localhost:4222", cfg.Nodes[0].StreamAddr)
+		assert.Empty(t, cfg.Nodes[0].APIKeyFile)
+		assert.Nil(t, cfg.ApiKey)
+	})
+	t.Run("all problems are reported", func(t *testing.T) {
+		t.Setenv("NUTS_CONFIGFILE", "../test/test.config.yaml")
+		t.Setenv("NUTS_NUTSNODESTREAMADDR", "localhost:4222")
+		t.Setenv("NUTS_NUTSNODEAPIKEYFILE", "non-existing.pem")
+
+		_, err := load(loadFlagSet(nil))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "nutsnodestreamaddr: invalid URL")
+		assert.Contains(t, err.Error(), "nutsnodeapikeyfile: stat non-existing.pem: no such file or directory")
+		assert.Contains(t, err.Error(), "nutsnodeapiuser: is required with nutsnodeapikeyfile")
+		assert.Contains(t, err.Error(), "nutsnodeapiaudience: is required with nutsnodeapikeyfile")
+	})
+}
+
+func TestConfig_pemToPrivateKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	t.Run("Ed25519 PKCS8", func(t *testing.T) {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+
+		signer, err := pemToPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+		require.NoError(t, err)
+		assert.Equal(t, key, signer)
+	})
+	t.Run("Ed25519 OpenSSH", func(t *testing.T) {
+		block, err := ssh.MarshalPrivateKey(key, "")
+		require.NoError(t, err)
+
+		signer, err := pemToPrivateKey(pem.EncodeToMemory(block))
+
+		require.NoError(t, err)
+		assert.Equal(t, key, signer)
+	})
+	t.Run("invalid PEM", func(t *testing.T) {
+		_, err := pemToPrivateKey([]byte("not a key"))
+
+		assert.EqualError(t, err, "failed to decode PEM file")
+	})
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// RedactedValue replaces the value of secret options in the printed config and the config API.
+// Secret options are marked with the `redact:"true"` struct tag.
+const RedactedValue = "<redacted>"
+
+// Sources of config values
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Map returns the config with the same structure and keys as the config file, with secret options redacted.
+// Fields without a koanf tag, like the parsed API keys, are left out.
+func (c Config) Map() map[string]interface{} {
+	result := redactedValue(reflect.ValueOf(c)).(map[string]interface{})
+	result[configFileFlag] = c.configFile
+	return result
+}
+
+// Sources returns where the value of every option in Map comes from: default, file, env or flag.
+// Options in nested structs and maps are joined with a dot, lists are a single option.
+func (c Config) Sources() map[string]string {
+	result := map[string]string{}
+	flatten("", c.Map(), func(key string) {
+		if source, ok := c.sources[key]; ok {
+			result[key] = source
+		} else {
+			result[key] = SourceDefault
+		}
+	})
+	return result
+}
+
+// flatten calls fn for the key of every value in the (nested) map
+func flatten(prefix string, m map[string]interface{}, fn func(key string)) {
+	for key, value := range m {
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flatten(prefix+key+defaultDelimiter, nested, fn)
+			continue
+		}
+		fn(prefix + key)
+	}
+}
+
+// redactedValue converts structs to maps using the koanf tags and redacts the fields tagged with redact
+func redactedValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Struct:
+		result := map[string]interface{}{}
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			key := field.Tag.Get("koanf")
+			if key == "" || key == "-" {
+				continue
+			}
+			if field.Tag.Get("redact") == "true" {
+				if !value.Field(i).IsZero() {
+					result[key] = RedactedValue
+				} else {
+					result[key] = ""
+				}
+				continue
+			}
+			result[key] = redactedValue(value.Field(i))
+		}
+		return result
+	case reflect.Slice:
+		if value.IsNil() {
+			return []interface{}{}
+		}
+		result := make([]interface{}, value.Len())
+		for i := range result {
+			result[i] = redactedValue(value.Index(i))
+		}
+		return result
+	case reflect.Map:
+		result := map[string]interface{}{}
+		iter := value.MapRange()
+		for iter.Next() {
+			result[iter.Key().String()] = redactedValue(iter.Value())
+		}
+		return result
+	case reflect.Int64:
+		if duration, ok := value.Interface().(time.Duration); ok {
+			return duration.String()
+		}
+	}
+	return value.Interface()
+}
+
+// keySources records the source of every key loaded by the providers, later sources overwrite earlier ones
+type keySources map[string]string
+
+func (s keySources) add(source string, keys []string) {
+	for _, key := range keys {
+		s[strings.ToLower(key)] = source
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Map(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cfg := defaultConfig()
+	cfg.ApiKey = key
+	cfg.Auth = AuthConfig{
+		Tokens: []TokenConfig{{Name: "dashboard", Token: "secret-token"}},
+		Users:  []UserConfig{{Username: "admin"}},
+		OIDC:   OIDCConfig{Issuer: "https://idp", ClientSecret: "client-secret"},
+	}
+
+	m := cfg.Map()
+
+	t.Run("secrets are redacted", func(t *testing.T) {
+		auth := m["auth"].(map[string]interface{})
+		assert.Equal(t, RedactedValue, auth["tokens"].([]interface{})[0].(map[string]interface{})["token"])
+		assert.Equal(t, "dashboard", auth["tokens"].([]interface{})[0].(map[string]interface{})["name"])
+		// empty secrets are shown as empty
+		assert.Equal(t, "", auth["users"].([]interface{})[0].(map[string]interface{})["passwordhash"])
+		assert.Equal(t, RedactedValue, auth["oidc"].(map[string]interface{})["clientsecret"])
+	})
+	t.Run("fields without koanf tag are left out", func(t *testing.T) {
+		assert.NotContains(t, m, "ApiKey")
+		assert.NotContains(t, m, "sources")
+	})
+	t.Run("durations and nested structs", func(t *testing.T) {
+		assert.Equal(t, "10s", m["nutsnodetimeout"])
+		assert.Equal(t, 3, m["nutsnoderetry"].(map[string]interface{})["maxattempts"])
+	})
+	t.Run("printed config doesn't contain secrets", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+
+		require.NoError(t, cfg.Print(buf))
+
+		assert.Contains(t, buf.String(), `"nutsnodeaddr": "http://localhost:1323"`)
+		assert.NotContains(t, buf.String(), "secret-token")
+		assert.NotContains(t, buf.String(), "client-secret")
+		assert.NotContains(t, buf.String(), key.D.String())
+	})
+}
+
+func TestConfig_Sources(t *testing.T) {
+	t.Setenv("NUTS_CONFIGFILE", "../test/nodes.config.yaml")
+	t.Setenv("NUTS_NUTSNODETIMEOUT", "20s")
+	t.Setenv("NUTS_NUTSNODERETRY_MAXATTEMPTS", "5")
+
+	cfg, err := load(loadFlagSet(nil))
+	require.NoError(t, err)
+	sources := cfg.Sources()
+
+	assert.Equal(t, SourceEnv, sources["configfile"])
+	assert.Equal(t, SourceFile, sources["nodes"])
+	assert.Equal(t, SourceFile, sources["nutsnodeapiclaims.tenant"])
+	assert.Equal(t, SourceEnv, sources["nutsnodetimeout"])
+	assert.Equal(t, SourceEnv, sources["nutsnoderetry.maxattempts"])
+	assert.Equal(t, SourceDefault, sources["nutsnoderetry.maxbackoff"])
+	assert.Equal(t, SourceDefault, sources["server.address"])
+
+	t.Run("config file from flag", func(t *testing.T) {
+		cfg, err := load(loadFlagSet([]string{"--configfile", "../test/test.config.yaml"}))
+		require.NoError(t, err)
+
+		assert.Equal(t, SourceFlag, cfg.Sources()["configfile"])
+		assert.Equal(t, "../test/test.config.yaml", cfg.Map()["configfile"])
+	})
+}
@@ -0,0 +1,310 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+// signingAlgorithms are the JWS algorithms that can be used to sign API tokens
+var signingAlgorithms = map[string]bool{
+	"RS256": true, "RS384": true, "RS512": true,
+	"PS256": true, "PS384": true, "PS512": true,
+	"ES256": true, "ES384": true, "ES512": true,
+	"EdDSA": true,
+}
+
+// reservedClaims are set by the monitor and can't be configured
+var reservedClaims = []string{"iss", "sub", "aud", "iat", "nbf", "exp", "jti"}
+
+// Validate checks the config and returns all problems found, joined in a single error.
+// Every problem is prefixed with the key of the offending option, e.g. "nodes[1].address".
+func (c Config) Validate() error {
+	v := &validator{}
+
+	if len(c.Nodes) == 0 {
+		// the nutsnode* parameters are only used when no nodes are configured
+		v.httpURL("nutsnodeaddr", c.NutsNodeAddr, true)
+		v.httpURL("nutsnodeinternaladdr", c.NutsNodeInternalAddr, false)
+		v.natsURL("nutsnodestreamaddr", c.NutsNodeStreamAddr, true)
+		v.apiSecurity("nutsnodeapi", c.NutsNodeAPIKeyFile, c.NutsNodeAPITokenFile, c.NutsNodeAPIUser, c.NutsNodeAPIAudience)
+	}
+	// the following nutsnode* parameters are the defaults for the nodes
+	v.algorithm("nutsnodeapialgorithm", c.NutsNodeAPIAlgorithm)
+	v.positive("nutsnodeapitokenlifetime", c.NutsNodeAPITokenLifetime)
+	v.claims("nutsnodeapiclaims", c.NutsNodeAPIClaims)
+	v.nodeTLS("nutsnodetls", c.NutsNodeTLS)
+	v.notNegative("nutsnodetimeout", c.NutsNodeTimeout)
+	v.notNegative("nutsnodeconnecttimeout", c.NutsNodeConnectTimeout)
+
+	v.retry("nutsnoderetry", c.NutsNodeRetry)
+	v.limits("nutsnodelimits", c.NutsNodeLimits)
+
+	v.positive("consistencyinterval", c.ConsistencyInterval)
+	if c.ConsistencyThreshold < 0 {
+		v.errorf("consistencythreshold", "must not be negative")
+	}
+	v.notNegative("consistencygraceperiod", c.ConsistencyGracePeriod)
+	v.notNegative("clockdrift", c.ClockDrift)
+	v.notNegative("clockskewmaxfuture", c.ClockSkewMaxFuture)
+	v.notNegative("clockskewmaxpast", c.ClockSkewMaxPast)
+	v.notNegative("keydormancy", c.KeyDormancy)
+	if c.MassRevocationThreshold < 0 {
+		v.errorf("massrevocationthreshold", "must not be negative")
+	}
+	v.positive("massrevocationwindow", c.MassRevocationWindow)
+	if c.AnomalyThreshold < 0 {
+		v.errorf("anomalythreshold", "must not be negative")
+	}
+	if c.AnomalyMinCount < 0 {
+		v.errorf("anomalymincount", "must not be negative")
+	}
+	if c.DAGRenderMaxRange <= 0 {
+		v.errorf("dagrendermaxrange", "must be positive")
+	}
+
+	names := map[string]bool{}
+	for i, node := range c.Nodes {
+		key := fmt.Sprintf("nodes[%d]", i)
+		if len(node.Name) == 0 {
+			v.errorf(key+".name", "is required")
+		} else if names[node.Name] {
+			v.errorf(key+".name", "duplicate name %s", node.Name)
+		}
+		names[node.Name] = true
+		v.httpURL(key+".address", node.Addr, true)
+		v.httpURL(key+".internaladdress", node.InternalAddr, false)
+		v.natsURL(key+".streamaddress", node.StreamAddr, false)
+		v.apiSecurity(key+".api", node.APIKeyFile, node.APITokenFile, node.APIUser, node.APIAudience)
+		v.algorithm(key+".apialgorithm", node.APIAlgorithm)
+		v.notNegative(key+".apitokenlifetime", node.APITokenLifetime)
+		v.claims(key+".apiclaims", node.APIClaims)
+		v.nodeTLS(key+".tls", node.TLS)
+		v.notNegative(key+".timeout", node.Timeout)
+		v.notNegative(key+".connecttimeout", node.ConnectTimeout)
+	}
+
+	if c.WithMockNode {
+		v.listenAddress("mocknode.address", c.MockNode.Address)
+		v.listenAddress("mocknode.streamaddress", c.MockNode.StreamAddress)
+		v.positive("mocknode.transactioninterval", c.MockNode.TransactionInterval)
+	}
+
+	v.auth("auth", c.Auth)
+	v.server("server", c.Server)
+
+	return errors.Join(v.errs...)
+}
+
+// validator collects the problems found in the config
+type validator struct {
+	errs []error
+}
+
+func (v *validator) errorf(key string, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) required(key string, value string) {
+	if len(value) == 0 {
+		v.errorf(key, "is required")
+	}
+}
+
+// url checks that the value is an absolute URL with one of the given schemes
+func (v *validator) url(key string, value string, required bool, schemes ...string) {
+	if len(value) == 0 {
+		if required {
+			v.errorf(key, "is required")
+		}
+		return
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		v.errorf(key, "invalid URL: %s", err)
+		return
+	}
+	if len(parsed.Host) == 0 {
+		v.errorf(key, "invalid URL %q: host is missing", value)
+		return
+	}
+	for _, scheme := range schemes {
+		if parsed.Scheme == scheme {
+			return
+		}
+	}
+	v.errorf(key, "invalid URL %q: scheme must be one of %v", value, schemes)
+}
+
+func (v *validator) httpURL(key string, value string, required bool) {
+	v.url(key, value, required, "http", "https")
+}
+
+func (v *validator) natsURL(key string, value string, required bool) {
+	v.url(key, value, required, "nats", "tls", "ws", "wss")
+}
+
+// file checks that the value, if set, points to an existing file
+func (v *validator) file(key string, value string) {
+	if len(value) == 0 {
+		return
+	}
+	info, err := os.Stat(value)
+	if err != nil {
+		v.errorf(key, "%s", err)
+		return
+	}
+	if info.IsDir() {
+		v.errorf(key, "%s is a directory", value)
+	}
+}
+
+func (v *validator) positive(key string, value time.Duration) {
+	if value <= 0 {
+		v.errorf(key, "must be positive")
+	}
+}
+
+func (v *validator) notNegative(key string, value time.Duration) {
+	if value < 0 {
+		v.errorf(key, "must not be negative")
+	}
+}
+
+// listenAddress checks that the value is a host:port address
+func (v *validator) listenAddress(key string, value string) {
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		v.errorf(key, "invalid address %q: %s", value, err)
+	}
+}
+
+// apiSecurity checks the API key or token file of a node, prefix is used for the keys of the files and user.
+func (v *validator) apiSecurity(prefix string, keyFile string, tokenFile string, user string, audience string) {
+	v.file(prefix+"keyfile", keyFile)
+	v.file(prefix+"tokenfile", tokenFile)
+	if len(keyFile) > 0 {
+		if len(tokenFile) > 0 {
+			v.errorf(prefix+"tokenfile", "can't be combined with %skeyfile", prefix)
+		}
+		if len(user) == 0 {
+			v.errorf(prefix+"user", "is required with %skeyfile", prefix)
+		}
+		if len(audience) == 0 {
+			v.errorf(prefix+"audience", "is required with %skeyfile", prefix)
+		}
+	}
+}
+
+func (v *validator) algorithm(key string, value string) {
+	if len(value) > 0 && !signingAlgorithms[value] {
+		v.errorf(key, "unsupported algorithm %s", value)
+	}
+}
+
+func (v *validator) claims(key string, claims map[string]interface{}) {
+	for _, claim := range reservedClaims {
+		if _, ok := claims[claim]; ok {
+			v.errorf(key, "claim %s can't be configured", claim)
+		}
+	}
+}
+
+func (v *validator) nodeTLS(key string, c NodeTLSConfig) {
+	if (len(c.CertFile) == 0) != (len(c.CertKeyFile) == 0) {
+		v.errorf(key, "both certfile and certkeyfile are required for a client certificate")
+	}
+	v.file(key+".certfile", c.CertFile)
+	v.file(key+".certkeyfile", c.CertKeyFile)
+	v.file(key+".truststorefile", c.TrustStoreFile)
+}
+
+func (v *validator) retry(key string, c RetryConfig) {
+	if c.MaxAttempts < 1 {
+		v.errorf(key+".maxattempts", "must be at least 1")
+	}
+	v.notNegative(key+".initialbackoff", c.InitialBackoff)
+	v.notNegative(key+".maxbackoff", c.MaxBackoff)
+	if c.MaxBackoff > 0 && c.MaxBackoff < c.InitialBackoff {
+		v.errorf(key+".maxbackoff", "must not be less than initialbackoff")
+	}
+	if c.BreakerThreshold < 0 {
+		v.errorf(key+".breakerthreshold", "must not be negative")
+	}
+	if c.BreakerThreshold > 0 {
+		v.positive(key+".breakertimeout", c.BreakerTimeout)
+	}
+}
+
+func (v *validator) limits(key string, c LimitConfig) {
+	if c.RequestsPerSecond < 0 {
+		v.errorf(key+".requestspersecond", "must not be negative")
+	}
+	if c.Burst < 0 {
+		v.errorf(key+".burst", "must not be negative")
+	}
+	if c.MaxInFlight < 0 {
+		v.errorf(key+".maxinflight", "must not be negative")
+	}
+}
+
+func (v *validator) role(key string, value string) {
+	switch value {
+	case "", "viewer", "admin":
+	default:
+		v.errorf(key, "unknown role %s, must be viewer or admin", value)
+	}
+}
+
+func (v *validator) auth(key string, c AuthConfig) {
+	for i, token := range c.Tokens {
+		tokenKey := fmt.Sprintf("%s.tokens[%d]", key, i)
+		v.required(tokenKey+".token", token.Token)
+		v.role(tokenKey+".role", token.Role)
+	}
+	for i, user := range c.Users {
+		userKey := fmt.Sprintf("%s.users[%d]", key, i)
+		v.required(userKey+".username", user.Username)
+		v.required(userKey+".passwordhash", user.PasswordHash)
+		v.role(userKey+".role", user.Role)
+	}
+	if c.OIDC.Enabled() {
+		v.httpURL(key+".oidc.issuer", c.OIDC.Issuer, true)
+		v.required(key+".oidc.clientid", c.OIDC.ClientID)
+		v.httpURL(key+".oidc.redirecturl", c.OIDC.RedirectURL, true)
+	}
+}
+
+func (v *validator) server(key string, c ServerConfig) {
+	v.listenAddress(key+".address", c.Address)
+	if len(c.InternalAddress) > 0 {
+		v.listenAddress(key+".internaladdress", c.InternalAddress)
+	}
+	if len(c.TLS.CertFile) > 0 || len(c.TLS.ClientCAFile) > 0 {
+		v.required(key+".tls.certfile", c.TLS.CertFile)
+		v.required(key+".tls.certkeyfile", c.TLS.CertKeyFile)
+	}
+	v.file(key+".tls.certfile", c.TLS.CertFile)
+	v.file(key+".tls.certkeyfile", c.TLS.CertKeyFile)
+	v.file(key+".tls.clientcafile", c.TLS.ClientCAFile)
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	existing := filepath.Join(t.TempDir(), "file.pem")
+	require.NoError(t, os.WriteFile(existing, []byte("contents"), 0600))
+	dir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		// errs contains the expected problems, none if empty
+		errs []string
+	}{
+		{name: "defaults", modify: func(c *Config) {}},
+		// nutsnode* parameters
+		{name: "nutsnodeaddr missing", modify: func(c *Config) { c.NutsNodeAddr = "" }, errs: []string{"nutsnodeaddr: is required"}},
+		{name: "nutsnodeaddr without scheme", modify: func(c *Config) { c.NutsNodeAddr = "localhost:1323" }, errs: []string{`nutsnodeaddr: invalid URL "localhost:1323": host is missing`}},
+		{name: "nutsnodeaddr invalid scheme", modify: func(c *Config) { c.NutsNodeAddr = "ftp://localhost" }, errs: []string{`nutsnodeaddr: invalid URL "ftp://localhost": scheme must be one of [http https]`}},
+		{name: "nutsnodeaddr unparsable", modify: func(c *Config) { c.NutsNodeAddr = "http://%zz" }, errs: []string{`nutsnodeaddr: invalid URL: parse "http://%zz": invalid URL escape "%zz"`}},
+		{name: "nutsnodeinternaladdr", modify: func(c *Config) { c.NutsNodeInternalAddr = "https://internal:8081" }},
+		{name: "nutsnodeinternaladdr invalid", modify: func(c *Config) { c.NutsNodeInternalAddr = "nats://internal" }, errs: []string{`nutsnodeinternaladdr: invalid URL "nats://internal": scheme must be one of [http https]`}},
+		{name: "nutsnodestreamaddr tls", modify: func(c *Config) { c.NutsNodeStreamAddr = "tls://localhost:4222" }},
+		{name: "nutsnodestreamaddr missing", modify: func(c *Config) { c.NutsNodeStreamAddr = "" }, errs: []string{"nutsnodestreamaddr: is required"}},
+		{name: "nutsnodestreamaddr invalid scheme", modify: func(c *Config) { c.NutsNodeStreamAddr = "http://localhost:4222" }, errs: []string{`nutsnodestreamaddr: invalid URL "http://localhost:4222": scheme must be one of [nats tls ws wss]`}},
+		{name: "API key", modify: func(c *Config) {
+			c.NutsNodeAPIKeyFile = existing
+			c.NutsNodeAPIUser = "monitor"
+			c.NutsNodeAPIAudience = "nuts-node"
+		}},
+		{name: "API key file missing", modify: func(c *Config) {
+			c.NutsNodeAPIKeyFile = "non-existing.pem"
+			c.NutsNodeAPIUser = "monitor"
+			c.NutsNodeAPIAudience = "nuts-node"
+		}, errs: []string{"nutsnodeapikeyfile: stat non-existing.pem: no such file or directory"}},
+		{name: "API key file is a directory", modify: func(c *Config) {
+			c.NutsNodeAPIKeyFile = dir
+			c.NutsNodeAPIUser = "monitor"
+			c.NutsNodeAPIAudience = "nuts-node"
+		}, errs: []string{"nutsnodeapikeyfile: " + dir + " is a directory"}},
+		{name: "API key without user and audience", modify: func(c *Config) { c.NutsNodeAPIKeyFile = existing }, errs: []string{
+			"nutsnodeapiuser: is required with nutsnodeapikeyfile",
+			"nutsnodeapiaudience: is required with nutsnodeapikeyfile",
+		}},
+		{name: "API token file", modify: func(c *Config) { c.NutsNodeAPITokenFile = existing }},
+		{name: "API token file missing", modify: func(c *Config) { c.NutsNodeAPITokenFile = "token" }, errs: []string{"nutsnodeapitokenfile: stat token: no such file or directory"}},
+		{name: "API key and token file", modify: func(c *Config) {
+			c.NutsNodeAPIKeyFile = existing
+			c.NutsNodeAPITokenFile = existing
+			c.NutsNodeAPIUser = "monitor"
+			c.NutsNodeAPIAudience = "nuts-node"
+		}, errs: []string{"nutsnodeapitokenfile: can't be combined with nutsnodeapikeyfile"}},
+		{name: "nutsnodeapialgorithm", modify: func(c *Config) { c.NutsNodeAPIAlgorithm = "RS256" }},
+		{name: "nutsnodeapialgorithm unsupported", modify: func(c *Config) { c.NutsNodeAPIAlgorithm = "HS256" }, errs: []string{"nutsnodeapialgorithm: unsupported algorithm HS256"}},
+		{name: "nutsnodeapitokenlifetime zero", modify: func(c *Config) { c.NutsNodeAPITokenLifetime = 0 }, errs: []string{"nutsnodeapitokenlifetime: must be positive"}},
+		{name: "nutsnodeapiclaims", modify: func(c *Config) { c.NutsNodeAPIClaims = map[string]interface{}{"tenant": "example"} }},
+		{name: "nutsnodeapiclaims reserved", modify: func(c *Config) { c.NutsNodeAPIClaims = map[string]interface{}{"exp": 0} }, errs: []string{"nutsnodeapiclaims: claim exp can't be configured"}},
+		{name: "nutsnodetls", modify: func(c *Config) {
+			c.NutsNodeTLS = NodeTLSConfig{CertFile: existing, CertKeyFile: existing, TrustStoreFile: existing, ServerName: "node"}
+		}},
+		{name: "nutsnodetls certfile without key", modify: func(c *Config) { c.NutsNodeTLS.CertFile = existing }, errs: []string{"nutsnodetls: both certfile and certkeyfile are required for a client certificate"}},
+		{name: "nutsnodetls files missing", modify: func(c *Config) {
+			c.NutsNodeTLS = NodeTLSConfig{CertFile: "cert.pem", CertKeyFile: "key.pem", TrustStoreFile: "ca.pem"}
+		}, errs: []string{
+			"nutsnodetls.certfile: stat cert.pem: no such file or directory",
+			"nutsnodetls.certkeyfile: stat key.pem: no such file or directory",
+			"nutsnodetls.truststorefile: stat ca.pem: no such file or directory",
+		}},
+		{name: "nutsnodetimeout disabled", modify: func(c *Config) { c.NutsNodeTimeout = 0 }},
+		{name: "nutsnodetimeout negative", modify: func(c *Config) { c.NutsNodeTimeout = -time.Second }, errs: []string{"nutsnodetimeout: must not be negative"}},
+		{name: "nutsnodeconnecttimeout negative", modify: func(c *Config) { c.NutsNodeConnectTimeout = -time.Second }, errs: []string{"nutsnodeconnecttimeout: must not be negative"}},
+		// retries and limits
+		{name: "nutsnoderetry.maxattempts", modify: func(c *Config) { c.NutsNodeRetry.MaxAttempts = 0 }, errs: []string{"nutsnoderetry.maxattempts: must be at least 1"}},
+		{name: "nutsnoderetry.initialbackoff", modify: func(c *Config) { c.NutsNodeRetry.InitialBackoff = -time.Second }, errs: []string{"nutsnoderetry.initialbackoff: must not be negative"}},
+		{name: "nutsnoderetry.maxbackoff", modify: func(c *Config) { c.NutsNodeRetry.MaxBackoff = time.Millisecond }, errs: []string{"nutsnoderetry.maxbackoff: must not be less than initialbackoff"}},
+		{name: "nutsnoderetry.breakerthreshold", modify: func(c *Config) { c.NutsNodeRetry.BreakerThreshold = -1 }, errs: []string{"nutsnoderetry.breakerthreshold: must not be negative"}},
+		{name: "nutsnoderetry.breakertimeout", modify: func(c *Config) { c.NutsNodeRetry.BreakerTimeout = 0 }, errs: []string{"nutsnoderetry.breakertimeout: must be positive"}},
+		{name: "nutsnoderetry breaker disabled", modify: func(c *Config) { c.NutsNodeRetry.BreakerThreshold = 0; c.NutsNodeRetry.BreakerTimeout = 0 }},
+		{name: "nutsnodelimits disabled", modify: func(c *Config) { c.NutsNodeLimits = LimitConfig{} }},
+		{name: "nutsnodelimits negative", modify: func(c *Config) { c.NutsNodeLimits = LimitConfig{RequestsPerSecond: -1, Burst: -1, MaxInFlight: -1} }, errs: []string{
+			"nutsnodelimits.requestspersecond: must not be negative",
+			"nutsnodelimits.burst: must not be negative",
+			"nutsnodelimits.maxinflight: must not be negative",
+		}},
+		// consistency and DAG
+		{name: "consistencyinterval", modify: func(c *Config) { c.ConsistencyInterval = 0 }, errs: []string{"consistencyinterval: must be positive"}},
+		{name: "consistencythreshold", modify: func(c *Config) { c.ConsistencyThreshold = -1 }, errs: []string{"consistencythreshold: must not be negative"}},
+		{name: "consistencygraceperiod", modify: func(c *Config) { c.ConsistencyGracePeriod = -time.Second }, errs: []string{"consistencygraceperiod: must not be negative"}},
+		{name: "clockdrift", modify: func(c *Config) { c.ClockDrift = -time.Second }, errs: []string{"clockdrift: must not be negative"}},
+		{name: "clockskewmaxfuture", modify: func(c *Config) { c.ClockSkewMaxFuture = -time.Second }, errs: []string{"clockskewmaxfuture: must not be negative"}},
+		{name: "clockskewmaxpast", modify: func(c *Config) { c.ClockSkewMaxPast = -time.Second }, errs: []string{"clockskewmaxpast: must not be negative"}},
+		{name: "keydormancy", modify: func(c *Config) { c.KeyDormancy = -time.Second }, errs: []string{"keydormancy: must not be negative"}},
+		{name: "massrevocationthreshold", modify: func(c *Config) { c.MassRevocationThreshold = -1 }, errs: []string{"massrevocationthreshold: must not be negative"}},
+		{name: "massrevocationwindow", modify: func(c *Config) { c.MassRevocationWindow = 0 }, errs: []string{"massrevocationwindow: must be positive"}},
+		{name: "anomalythreshold", modify: func(c *Config) { c.AnomalyThreshold = -1 }, errs: []string{"anomalythreshold: must not be negative"}},
+		{name: "anomalymincount", modify: func(c *Config) { c.AnomalyMinCount = -1 }, errs: []string{"anomalymincount: must not be negative"}},
+		{name: "dagrendermaxrange", modify: func(c *Config) { c.DAGRenderMaxRange = 0 }, errs: []string{"dagrendermaxrange: must be positive"}},
+		// nodes
+		{name: "nodes", modify: func(c *Config) {
+			c.NutsNodeAddr = "ignored"
+			c.Nodes = []NodeConfig{
+				{Name: "a", Addr: "http://a"},
+				{Name: "b", Addr: "https://b", InternalAddr: "http://b:8081", StreamAddr: "nats://b:4222", APITokenFile: existing, APITokenLifetime: time.Minute, APIAlgorithm: "EdDSA",
+					TLS: NodeTLSConfig{TrustStoreFile: existing}, Timeout: time.Second, ConnectTimeout: time.Second},
+			}
+		}},
+		{name: "nodes name missing and duplicate", modify: func(c *Config) {
+			c.Nodes = []NodeConfig{{Addr: "http://a"}, {Name: "b", Addr: "http://b"}, {Name: "b", Addr: "http://c"}}
+		}, errs: []string{"nodes[0].name: is required", "nodes[2].name: duplicate name b"}},
+		{name: "nodes addresses", modify: func(c *Config) {
+			c.Nodes = []NodeConfig{{Name: "a", InternalAddr: "a:8081", StreamAddr: "http://a"}}
+		}, errs: []string{
+			"nodes[0].address: is required",
+			`nodes[0].internaladdress: invalid URL "a:8081": host is missing`,
+			`nodes[0].streamaddress: invalid URL "http://a": scheme must be one of [nats tls ws wss]`,
+		}},
+		{name: "nodes API security", modify: func(c *Config) {
+			c.Nodes = []NodeConfig{{Name: "a", Addr: "http://a", APIKeyFile: existing, APITokenFile: "token", APIAlgorithm: "none", APIClaims: map[string]interface{}{"sub": "x"}}}
+		}, errs: []string{
+			"nodes[0].apitokenfile: stat token: no such file or directory",
+			"nodes[0].apitokenfile: can't be combined with nodes[0].apikeyfile",
+			"nodes[0].apiuser: is required with nodes[0].apikeyfile",
+			"nodes[0].apiaudience: is required with nodes[0].apikeyfile",
+			"nodes[0].apialgorithm: unsupported algorithm none",
+			"nodes[0].apiclaims: claim sub can't be configured",
+		}},
+		{name: "nodes TLS and timeouts", modify: func(c *Config) {
+			c.Nodes = []NodeConfig{{Name: "a", Addr: "http://a", APITokenLifetime: -time.Second, TLS: NodeTLSConfig{CertKeyFile: existing}, Timeout: -time.Second, ConnectTimeout: -time.Second}}
+		}, errs: []string{
+			"nodes[0].apitokenlifetime: must not be negative",
+			"nodes[0].tls: both certfile and certkeyfile are required for a client certificate",
+			"nodes[0].timeout: must not be negative",
+			"nodes[0].connecttimeout: must not be negative",
+		}},
+		// monitor authentication
+		{name: "auth", modify: func(c *Config) {
+			c.Auth = AuthConfig{
+				Tokens: []TokenConfig{{Token: "token", Role: "admin"}},
+				Users:  []UserConfig{{Username: "user", PasswordHash: "hash"}},
+				OIDC:   OIDCConfig{Issuer: "https://idp", ClientID: "monitor", RedirectURL: "https://monitor/auth/callback"},
+			}
+		}},
+		{name: "auth tokens", modify: func(c *Config) { c.Auth.Tokens = []TokenConfig{{Role: "owner"}} }, errs: []string{
+			"auth.tokens[0].token: is required",
+			"auth.tokens[0].role: unknown role owner, must be viewer or admin",
+		}},
+		{name: "auth users", modify: func(c *Config) { c.Auth.Users = []UserConfig{{Role: "root"}} }, errs: []string{
+			"auth.users[0].username: is required",
+			"auth.users[0].passwordhash: is required",
+			"auth.users[0].role: unknown role root, must be viewer or admin",
+		}},
+		{name: "auth oidc", modify: func(c *Config) { c.Auth.OIDC = OIDCConfig{Issuer: "idp"} }, errs: []string{
+			`auth.oidc.issuer: invalid URL "idp": host is missing`,
+			"auth.oidc.clientid: is required",
+			"auth.oidc.redirecturl: is required",
+		}},
+		// mock node
+		{name: "mock node", modify: func(c *Config) { c.WithMockNode = true }},
+		{name: "mock node invalid", modify: func(c *Config) {
+			c.WithMockNode = true
+			c.MockNode = MockNodeConfig{Address: "1323", StreamAddress: ":4222"}
+		}, errs: []string{
+			`mocknode.address: invalid address "1323": address 1323: missing port in address`,
+			"mocknode.transactioninterval: must be positive",
+		}},
+		{name: "mock node disabled", modify: func(c *Config) { c.MockNode = MockNodeConfig{} }},
+		// HTTP server
+		{name: "server", modify: func(c *Config) {
+			c.Server = ServerConfig{Address: "127.0.0.1:8443", InternalAddress: ":8080", TLS: ServerTLSConfig{CertFile: existing, CertKeyFile: existing, ClientCAFile: existing}}
+		}},
+		{name: "server addresses", modify: func(c *Config) { c.Server = ServerConfig{Address: "1313", InternalAddress: "localhost"} }, errs: []string{
+			`server.address: invalid address "1313": address 1313: missing port in address`,
+			`server.internaladdress: invalid address "localhost": address localhost: missing port in address`,
+		}},
+		{name: "server TLS", modify: func(c *Config) { c.Server.TLS = ServerTLSConfig{ClientCAFile: "ca.pem"} }, errs: []string{
+			"server.tls.certfile: is required",
+			"server.tls.certkeyfile: is required",
+			"server.tls.clientcafile: stat ca.pem: no such file or directory",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			test.modify(&cfg)
+
+			err := cfg.Validate()
+
+			if len(test.errs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, test.errs, strings.Split(err.Error(), "\n"))
+		})
+	}
+}
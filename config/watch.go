@@ -0,0 +1,269 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Triggers of a reload
+const (
+	TriggerFile   = "file"
+	TriggerSignal = "signal"
+	TriggerAPI    = "api"
+)
+
+// reloadDebounce is the time to wait for more changes to the config file before reloading, editors often write a file in multiple steps
+const reloadDebounce = 200 * time.Millisecond
+
+// ReloadStatus describes the reloads of the config
+type ReloadStatus struct {
+	// Reloads is the number of successful reloads
+	Reloads int `json:"reloads"`
+	// LastAttempt is the time of the last reload, successful or not
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	// LastSuccess is the time of the last successful reload
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	// Trigger of the last reload: file, signal or api
+	Trigger string `json:"trigger,omitempty"`
+	// Error of the last reload, empty if it succeeded
+	Error string `json:"error,omitempty"`
+	// RestartRequired contains the changed options that only take effect after a restart
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+// Watcher holds the current config and reloads it when the config file changes or the process receives SIGHUP.
+// Changes to the node addresses, API security, TLS, timeouts, retries, limits and DAG settings take effect immediately.
+// Changes to the server, auth, withmocknode, mocknode options and to the names of the nodes require a restart, they are ignored until then.
+type Watcher struct {
+	load      func() (Config, error)
+	listeners []func(Config) error
+
+	// reloadMutex makes sure only one reload runs at a time
+	reloadMutex sync.Mutex
+	mutex       sync.RWMutex
+	current     Config
+	status      ReloadStatus
+}
+
+// NewWatcher creates a Watcher for a config that was returned by LoadConfig. Call Start to watch for changes.
+func NewWatcher(initial Config) *Watcher {
+	return newWatcher(initial, func() (Config, error) {
+		if initial.flagset == nil {
+			return Config{}, errors.New("config wasn't loaded from file and environment")
+		}
+		return load(initial.flagset)
+	})
+}
+
+func newWatcher(initial Config, load func() (Config, error)) *Watcher {
+	return &Watcher{load: load, current: initial}
+}
+
+// OnReload registers a function that applies a reloaded config. If it returns an error the reload fails and the current config is kept,
+// so it must check the config before applying it. Listeners are called in the order they were registered.
+func (w *Watcher) OnReload(listener func(Config) error) {
+	w.reloadMutex.Lock()
+	defer w.reloadMutex.Unlock()
+
+	w.listeners = append(w.listeners, listener)
+}
+
+// Current returns the current config
+func (w *Watcher) Current() Config {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	return w.current
+}
+
+// Status returns the result of the reloads
+func (w *Watcher) Status() ReloadStatus {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	status := w.status
+	status.RestartRequired = append([]string(nil), w.status.RestartRequired...)
+	return status
+}
+
+// Reload loads the config from the config file and environment and applies it. The trigger is recorded in the status.
+func (w *Watcher) Reload(trigger string) error {
+	w.reloadMutex.Lock()
+	defer w.reloadMutex.Unlock()
+
+	next, err := w.load()
+	var restartRequired []string
+	if err == nil {
+		next, restartRequired = keepRestartRequired(w.Current(), next)
+		for _, listener := range w.listeners {
+			if err = listener(next); err != nil {
+				break
+			}
+		}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	now := time.Now()
+	w.status.LastAttempt = &now
+	w.status.Trigger = trigger
+	if err != nil {
+		w.status.Error = err.Error()
+		log.Printf("Failed to reload config (trigger: %s), keeping the current config: %s", trigger, err)
+		return err
+	}
+	w.current = next
+	w.status.Reloads++
+	w.status.LastSuccess = &now
+	w.status.Error = ""
+	w.status.RestartRequired = restartRequired
+	log.Printf("Reloaded config (trigger: %s)", trigger)
+	if len(restartRequired) > 0 {
+		log.Printf("Changes to these options require a restart: %v", restartRequired)
+	}
+	return nil
+}
+
+// keepRestartRequired returns the next config with the options that can't be changed at runtime set to their current values,
+// and the keys of these options that were changed.
+func keepRestartRequired(current Config, next Config) (Config, []string) {
+	var changed []string
+	if !reflect.DeepEqual(current.Server, next.Server) {
+		changed = append(changed, "server")
+		next.Server = current.Server
+	}
+	if !reflect.DeepEqual(current.Auth, next.Auth) {
+		changed = append(changed, "auth")
+		next.Auth = current.Auth
+	}
+	if current.WithMockNode != next.WithMockNode {
+		changed = append(changed, "withmocknode")
+		next.WithMockNode = current.WithMockNode
+	}
+	if current.MockNode != next.MockNode {
+		changed = append(changed, "mocknode")
+		next.MockNode = current.MockNode
+	}
+	if !sameNodeNames(current.Nodes, next.Nodes) {
+		// nodes can't be added or removed, keep all node settings
+		changed = append(changed, "nodes")
+		next.Nodes = current.Nodes
+		return next, changed
+	}
+	// the data of a node is collected from its address, another address would mix the data of two nodes
+	next.Nodes = append([]NodeConfig(nil), next.Nodes...)
+	for i := range next.Nodes {
+		if current.Nodes[i].Addr == next.Nodes[i].Addr {
+			continue
+		}
+		next.Nodes[i].Addr = current.Nodes[i].Addr
+		if len(next.Nodes) == 1 && next.Nodes[i].Name == defaultNodeName {
+			// the node is configured by the nutsnode* parameters
+			changed = append(changed, "nutsnodeaddr")
+			next.NutsNodeAddr = current.NutsNodeAddr
+		} else {
+			changed = append(changed, fmt.Sprintf("nodes[%d].address", i))
+		}
+	}
+	return next, changed
+}
+
+func sameNodeNames(a []NodeConfig, b []NodeConfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+// Start reloads the config when the config file changes or the process receives SIGHUP, until the context is cancelled.
+// The directory of the config file is watched, so files that are replaced instead of written (e.g. Kubernetes ConfigMaps) are noticed too.
+func (w *Watcher) Start(ctx context.Context) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	var events chan fsnotify.Event
+	var watchErrors chan error
+	var fileWatcher *fsnotify.Watcher
+	configFile := w.Current().configFile
+	if configFile != "" {
+		var err error
+		fileWatcher, err = fsnotify.NewWatcher()
+		if err != nil {
+			signal.Stop(signals)
+			return err
+		}
+		if err = fileWatcher.Add(filepath.Dir(configFile)); err != nil {
+			_ = fileWatcher.Close()
+			signal.Stop(signals)
+			return err
+		}
+		events = fileWatcher.Events
+		watchErrors = fileWatcher.Errors
+	}
+
+	go func() {
+		defer signal.Stop(signals)
+		if fileWatcher != nil {
+			defer fileWatcher.Close()
+		}
+		debounce := time.NewTimer(0)
+		<-debounce.C
+		for {
+			select {
+			case <-ctx.Done():
+				debounce.Stop()
+				return
+			case <-signals:
+				_ = w.Reload(TriggerSignal)
+			case event := <-events:
+				if filepath.Clean(event.Name) == filepath.Clean(configFile) || isConfigMapSwap(event.Name) {
+					debounce.Reset(reloadDebounce)
+				}
+			case err := <-watchErrors:
+				log.Printf("Error while watching the config file: %s", err)
+			case <-debounce.C:
+				_ = w.Reload(TriggerFile)
+			}
+		}
+	}()
+	return nil
+}
+
+// isConfigMapSwap returns true for the symlink that Kubernetes replaces when a mounted ConfigMap changes
+func isConfigMapSwap(name string) bool {
+	return filepath.Base(name) == "..data"
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_Reload(t *testing.T) {
+	initial := defaultConfig()
+	initial.NutsNodeTimeout = time.Second
+
+	t.Run("ok", func(t *testing.T) {
+		next := defaultConfig()
+		next.NutsNodeTimeout = 2 * time.Second
+		watcher := newWatcher(initial, func() (Config, error) { return next, nil })
+		var applied Config
+		watcher.OnReload(func(cfg Config) error {
+			applied = cfg
+			return nil
+		})
+
+		err := watcher.Reload(TriggerAPI)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2*time.Second, watcher.Current().NutsNodeTimeout)
+		assert.Equal(t, 2*time.Second, applied.NutsNodeTimeout)
+		status := watcher.Status()
+		assert.Equal(t, 1, status.Reloads)
+		assert.Equal(t, TriggerAPI, status.Trigger)
+		assert.NotNil(t, status.LastSuccess)
+		assert.Empty(t, status.Error)
+		assert.Empty(t, status.RestartRequired)
+	})
+	t.Run("invalid config keeps the current config", func(t *testing.T) {
+		watcher := newWatcher(initial, func() (Config, error) { return Config{}, errors.New("nutsnodeaddr: is required") })
+		called := false
+		watcher.OnReload(func(cfg Config) error {
+			called = true
+			return nil
+		})
+
+		err := watcher.Reload(TriggerFile)
+
+		assert.EqualError(t, err, "nutsnodeaddr: is required")
+		assert.False(t, called)
+		assert.Equal(t, time.Second, watcher.Current().NutsNodeTimeout)
+		status := watcher.Status()
+		assert.Equal(t, 0, status.Reloads)
+		assert.Equal(t, TriggerFile, status.Trigger)
+		assert.NotNil(t, status.LastAttempt)
+		assert.Nil(t, status.LastSuccess)
+		assert.Equal(t, "nutsnodeaddr: is required", status.Error)
+	})
+	t.Run("failing listener keeps the current config", func(t *testing.T) {
+		next := defaultConfig()
+		next.NutsNodeTimeout = 2 * time.Second
+		watcher := newWatcher(initial, func() (Config, error) { return next, nil })
+		watcher.OnReload(func(cfg Config) error { return errors.New("failed") })
+		called := false
+		watcher.OnReload(func(cfg Config) error {
+			called = true
+			return nil
+		})
+
+		err := watcher.Reload(TriggerSignal)
+
+		assert.EqualError(t, err, "failed")
+		assert.False(t, called)
+		assert.Equal(t, time.Second, watcher.Current().NutsNodeTimeout)
+		assert.Equal(t, "failed", watcher.Status().Error)
+	})
+	t.Run("options that require a restart are kept", func(t *testing.T) {
+		current := initial
+		current.Nodes = []NodeConfig{{Name: "a", Addr: "http://node-a:1323"}}
+		next := defaultConfig()
+		next.Server.Address = ":9000"
+		next.Auth.Tokens = []TokenConfig{{Token: "secret"}}
+		next.WithMockNode = true
+		next.Nodes = []NodeConfig{{Name: "b", Addr: "http://node-b:1323"}}
+		next.DAGRenderMaxRange = 10
+		watcher := newWatcher(current, func() (Config, error) { return next, nil })
+
+		err := watcher.Reload(TriggerAPI)
+
+		require.NoError(t, err)
+		cfg := watcher.Current()
+		assert.Equal(t, current.Server, cfg.Server)
+		assert.Equal(t, current.Auth, cfg.Auth)
+		assert.False(t, cfg.WithMockNode)
+		assert.Equal(t, current.Nodes, cfg.Nodes)
+		assert.Equal(t, 10, cfg.DAGRenderMaxRange)
+		assert.Equal(t, []string{"server", "auth", "withmocknode", "nodes"}, watcher.Status().RestartRequired)
+	})
+	t.Run("node addresses require a restart", func(t *testing.T) {
+		current := initial
+		current.Nodes = []NodeConfig{{Name: "a", Addr: "http://node-a:1323"}, {Name: "b", Addr: "http://node-b:1323", Timeout: time.Second}}
+		next := initial
+		next.Nodes = []NodeConfig{{Name: "a", Addr: "http://node-a:1323"}, {Name: "b", Addr: "http://node-c:1323", Timeout: 2 * time.Second}}
+		watcher := newWatcher(current, func() (Config, error) { return next, nil })
+
+		err := watcher.Reload(TriggerAPI)
+
+		require.NoError(t, err)
+		cfg := watcher.Current()
+		assert.Equal(t, "http://node-b:1323", cfg.Nodes[1].Addr)
+		assert.Equal(t, 2*time.Second, cfg.Nodes[1].Timeout)
+		assert.Equal(t, []string{"nodes[1].address"}, watcher.Status().RestartRequired)
+	})
+	t.Run("the address of the default node requires a restart", func(t *testing.T) {
+		current := initial
+		current.NutsNodeAddr = "http://node-a:1323"
+		current.Nodes = []NodeConfig{{Name: defaultNodeName, Addr: "http://node-a:1323"}}
+		next := initial
+		next.NutsNodeAddr = "http://node-b:1323"
+		next.Nodes = []NodeConfig{{Name: defaultNodeName, Addr: "http://node-b:1323"}}
+		watcher := newWatcher(current, func() (Config, error) { return next, nil })
+
+		err := watcher.Reload(TriggerAPI)
+
+		require.NoError(t, err)
+		cfg := watcher.Current()
+		assert.Equal(t, "http://node-a:1323", cfg.NutsNodeAddr)
+		assert.Equal(t, "http://node-a:1323", cfg.Nodes[0].Addr)
+		assert.Equal(t, []string{"nutsnodeaddr"}, watcher.Status().RestartRequired)
+	})
+}
+
+func TestWatcher_Start(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "server.config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("nutsnodetimeout: 1s\n"), 0600))
+	t.Setenv("NUTS_CONFIGFILE", configFile)
+	initial, err := load(loadFlagSet(nil))
+	require.NoError(t, err)
+	watcher := NewWatcher(initial)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, watcher.Start(ctx))
+	require.NoError(t, os.WriteFile(configFile, []byte("nutsnodetimeout: 2s\n"), 0600))
+
+	require.Eventually(t, func() bool {
+		return watcher.Current().NutsNodeTimeout == 2*time.Second
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, TriggerFile, watcher.Status().Trigger)
+}
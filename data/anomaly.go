@@ -0,0 +1,273 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Types of Anomaly
+const (
+	// AnomalySpike is reported when the count of an hour is far above the baseline
+	AnomalySpike = "spike"
+	// AnomalySilence is reported when there are no transactions in an hour, while the baseline is far above zero
+	AnomalySilence = "silence"
+)
+
+// Series of an Anomaly
+const (
+	// SeriesContentType contains the transactions of a content type
+	SeriesContentType = "content_type"
+	// SeriesRoot contains the transactions of the signers controlled by a root DID
+	SeriesRoot = "root"
+)
+
+// maxAnomalies is the number of most recent anomalies that are kept
+const maxAnomalies = 1000
+
+// minBaseline is the number of hours a series must have been active before it's checked
+const minBaseline = 6
+
+// maxAnomalyContext is the number of largest counts that are kept as context of an anomaly
+const maxAnomalyContext = 5
+
+// Anomaly is an hour, or consecutive hours, in which the number of transactions of a series differs from its baseline
+type Anomaly struct {
+	// Type is AnomalySpike or AnomalySilence
+	Type string `json:"type"`
+	// Series is SeriesContentType or SeriesRoot
+	Series string `json:"series"`
+	// Key is the content type or the root DID
+	Key string `json:"key"`
+	// Start is the start of the first hour of the anomaly
+	Start time.Time `json:"start"`
+	// End is the end of the last hour of the anomaly
+	End   time.Time `json:"end"`
+	Count uint32    `json:"count"`
+	// Mean and StdDev are the average and standard deviation of the hourly counts before the anomaly
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	// Context contains the largest counts of the anomaly per root DID for a content type, or per content type for a root DID
+	Context map[string]uint32 `json:"context"`
+	// Detected is the time the anomaly was first detected
+	Detected time.Time `json:"detected"`
+}
+
+// AnomalyReport contains the most recent anomalies
+type AnomalyReport struct {
+	// Threshold is the configured number of standard deviations a count must differ from the baseline, 0 if not checked
+	Threshold float64 `json:"threshold"`
+	// MinCount is the configured minimum count of a spike and minimum baseline of a silence
+	MinCount int `json:"min_count"`
+	// Anomalies contains the most recent anomalies, the latest first
+	Anomalies []Anomaly `json:"anomalies"`
+}
+
+// anomalyDetector compares the hourly transaction count per content type and root DID with the counts of the week before.
+type anomalyDetector struct {
+	threshold float64
+	minCount  int
+	// activity counts per hour for the last week, per root DID and content type
+	activity *slidingWindow
+	// started is the time the detector was created, hours that started before it aren't checked since the history may still be loading
+	started time.Time
+	// checked is the start of the last checked hour
+	checked time.Time
+	// ongoing contains the anomalies that may be extended with the next hour, per type, series and key
+	ongoing   map[string]*Anomaly
+	anomalies []*Anomaly
+}
+
+func newAnomalyDetector(threshold float64, minCount int, started time.Time) *anomalyDetector {
+	return &anomalyDetector{
+		threshold: threshold,
+		minCount:  minCount,
+		activity:  NewSlidingWindow(time.Hour, 7*24*time.Hour, time.Minute),
+		started:   started,
+		ongoing:   map[string]*Anomaly{},
+	}
+}
+
+// add counts the transaction for its content type and the root DID of its signer
+func (d *anomalyDetector) add(root string, transaction Transaction) {
+	d.activity.AddCount(root+" "+transaction.ContentType, transaction.SigTime)
+}
+
+// detect checks the last completed hour before now, if it hasn't been checked yet
+func (d *anomalyDetector) detect(now time.Time) {
+	hour := now.Truncate(time.Hour).Add(-time.Hour)
+	if d.threshold <= 0 || hour.Before(d.started) || !hour.After(d.checked) {
+		return
+	}
+	d.checked = hour
+
+	d.activity.mutex.Lock()
+	dataPoints := d.activity.consolidate(now)
+	d.activity.mutex.Unlock()
+
+	// sum the counts per content type and per root, the counts of the other dimension are the context
+	contentTypes := map[string][]uint32{}
+	roots := map[string][]uint32{}
+	contentTypeContext := map[string]map[string]uint32{}
+	rootContext := map[string]map[string]uint32{}
+	for key, points := range dataPoints {
+		root, contentType, _ := strings.Cut(key, " ")
+		addCounts(contentTypes, contentType, points)
+		addCounts(roots, root, points)
+		// the last data point is the current hour, the one before it is checked
+		if count := points[len(points)-2].Count; count > 0 {
+			addContext(contentTypeContext, contentType, root, count)
+			addContext(rootContext, root, contentType, count)
+		}
+	}
+	for contentType, counts := range contentTypes {
+		d.check(SeriesContentType, contentType, counts, contentTypeContext[contentType], hour, now)
+	}
+	for root, counts := range roots {
+		d.check(SeriesRoot, root, counts, rootContext[root], hour, now)
+	}
+}
+
+// check compares the count of the hour before the last data point with the counts before it
+func (d *anomalyDetector) check(series string, key string, counts []uint32, context map[string]uint32, hour time.Time, now time.Time) {
+	current := counts[len(counts)-2]
+	history := counts[:len(counts)-2]
+	// the baseline starts at the first activity of the series
+	first := 0
+	for first < len(history) && history[first] == 0 {
+		first++
+	}
+	if len(history)-first < minBaseline {
+		return
+	}
+	mean, stdDev := meanStdDev(history[first:])
+	// the counts vary at least as much as those of a Poisson process
+	deviation := math.Max(stdDev, math.Max(math.Sqrt(mean), 1))
+
+	var anomalyType string
+	switch {
+	case int(current) >= d.minCount && float64(current) > mean+d.threshold*deviation:
+		anomalyType = AnomalySpike
+	case current == 0 && mean >= float64(d.minCount) && mean-d.threshold*deviation > 0:
+		anomalyType = AnomalySilence
+	default:
+		return
+	}
+
+	id := anomalyType + " " + series + " " + key
+	if ongoing, ok := d.ongoing[id]; ok && ongoing.End.Equal(hour) {
+		// the anomaly continues, the baseline of its first hour is kept
+		ongoing.End = hour.Add(time.Hour)
+		ongoing.Count += current
+		for name, count := range context {
+			ongoing.Context[name] += count
+		}
+		ongoing.Context = largest(ongoing.Context)
+		return
+	}
+	anomaly := &Anomaly{
+		Type:     anomalyType,
+		Series:   series,
+		Key:      key,
+		Start:    hour,
+		End:      hour.Add(time.Hour),
+		Count:    current,
+		Mean:     mean,
+		StdDev:   stdDev,
+		Context:  largest(context),
+		Detected: now,
+	}
+	d.ongoing[id] = anomaly
+	d.anomalies = append(d.anomalies, anomaly)
+	if len(d.anomalies) > maxAnomalies {
+		d.anomalies = d.anomalies[len(d.anomalies)-maxAnomalies:]
+	}
+}
+
+func (d *anomalyDetector) report() AnomalyReport {
+	report := AnomalyReport{
+		Threshold: d.threshold,
+		MinCount:  d.minCount,
+		Anomalies: make([]Anomaly, len(d.anomalies)),
+	}
+	for i, anomaly := range d.anomalies {
+		result := *anomaly
+		result.Context = make(map[string]uint32, len(anomaly.Context))
+		for name, count := range anomaly.Context {
+			result.Context[name] = count
+		}
+		report.Anomalies[len(d.anomalies)-1-i] = result
+	}
+	return report
+}
+
+func addCounts(series map[string][]uint32, key string, dataPoints []DataPoint) {
+	counts, ok := series[key]
+	if !ok {
+		counts = make([]uint32, len(dataPoints))
+		series[key] = counts
+	}
+	for i, dataPoint := range dataPoints {
+		counts[i] += dataPoint.Count
+	}
+}
+
+func addContext(contexts map[string]map[string]uint32, key string, name string, count uint32) {
+	context, ok := contexts[key]
+	if !ok {
+		context = map[string]uint32{}
+		contexts[key] = context
+	}
+	context[name] += count
+}
+
+// largest returns the maxAnomalyContext largest counts
+func largest(counts map[string]uint32) map[string]uint32 {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	result := make(map[string]uint32, min(len(names), maxAnomalyContext))
+	for _, name := range names[:min(len(names), maxAnomalyContext)] {
+		result[name] = counts[name]
+	}
+	return result
+}
+
+func meanStdDev(counts []uint32) (float64, float64) {
+	var total float64
+	for _, count := range counts {
+		total += float64(count)
+	}
+	mean := total / float64(len(counts))
+	var squares float64
+	for _, count := range counts {
+		squares += (float64(count) - mean) * (float64(count) - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(counts)))
+}
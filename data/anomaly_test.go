@@ -0,0 +1,157 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/test"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnomalyDetector(t *testing.T) {
+	now := time.Now()
+	// the hour before the current hour is checked
+	checked := now.Truncate(time.Hour).Add(-time.Hour)
+	add := func(detector *anomalyDetector, root string, contentType string, hour time.Time, count int) {
+		for i := 0; i < count; i++ {
+			detector.add(root, Transaction{ContentType: contentType, SigTime: hour.Add(30 * time.Minute)})
+		}
+	}
+
+	t.Run("reports spikes per content type and root", func(t *testing.T) {
+		detector := newAnomalyDetector(3, 10, time.Time{})
+		for h := 2; h <= 25; h++ {
+			add(detector, "did:nuts:a", "application/vc+json", checked.Add(-time.Duration(h-1)*time.Hour), 10)
+		}
+		add(detector, "did:nuts:a", "application/vc+json", checked, 60)
+		// the root hasn't been active long enough to be checked
+		add(detector, "did:nuts:b", "application/vc+json", checked, 5)
+
+		detector.detect(now)
+
+		report := detector.report()
+		require.Len(t, report.Anomalies, 2)
+		anomalies := map[string]Anomaly{}
+		for _, anomaly := range report.Anomalies {
+			anomalies[anomaly.Series] = anomaly
+		}
+		assert.Equal(t, Anomaly{Type: AnomalySpike, Series: SeriesContentType, Key: "application/vc+json", Start: checked, End: checked.Add(time.Hour), Count: 65, Mean: 10,
+			Context: map[string]uint32{"did:nuts:a": 60, "did:nuts:b": 5}, Detected: now}, anomalies[SeriesContentType])
+		assert.Equal(t, Anomaly{Type: AnomalySpike, Series: SeriesRoot, Key: "did:nuts:a", Start: checked, End: checked.Add(time.Hour), Count: 60, Mean: 10,
+			Context: map[string]uint32{"application/vc+json": 60}, Detected: now}, anomalies[SeriesRoot])
+	})
+	t.Run("reports silences once per consecutive hours", func(t *testing.T) {
+		detector := newAnomalyDetector(3, 10, time.Time{})
+		for h := 2; h <= 25; h++ {
+			add(detector, "did:nuts:a", "application/did+json", checked.Add(-time.Duration(h-1)*time.Hour), 20)
+		}
+
+		detector.detect(now)
+		// the same hour isn't checked twice
+		detector.detect(now.Add(time.Second))
+		// the next hour extends the silence
+		detector.detect(now.Add(time.Hour))
+
+		report := detector.report()
+		require.Len(t, report.Anomalies, 2)
+		for _, anomaly := range report.Anomalies {
+			assert.Equal(t, AnomalySilence, anomaly.Type)
+			assert.Equal(t, checked, anomaly.Start)
+			assert.Equal(t, checked.Add(2*time.Hour), anomaly.End)
+			assert.Equal(t, uint32(0), anomaly.Count)
+			assert.Equal(t, float64(20), anomaly.Mean)
+			assert.Empty(t, anomaly.Context)
+		}
+	})
+	t.Run("ignores small counts", func(t *testing.T) {
+		detector := newAnomalyDetector(3, 10, time.Time{})
+		for h := 2; h <= 25; h++ {
+			add(detector, "did:nuts:a", "application/vc+json", checked.Add(-time.Duration(h-1)*time.Hour), 1)
+		}
+		add(detector, "did:nuts:a", "application/vc+json", checked, 9)
+
+		detector.detect(now)
+
+		assert.Empty(t, detector.report().Anomalies)
+	})
+	t.Run("doesn't check hours before it started", func(t *testing.T) {
+		detector := newAnomalyDetector(3, 10, now)
+		for h := 2; h <= 25; h++ {
+			add(detector, "did:nuts:a", "application/did+json", checked.Add(-time.Duration(h-1)*time.Hour), 20)
+		}
+
+		detector.detect(now)
+
+		assert.Empty(t, detector.report().Anomalies)
+	})
+	t.Run("detection is disabled", func(t *testing.T) {
+		detector := newAnomalyDetector(0, 10, time.Time{})
+		for h := 2; h <= 25; h++ {
+			add(detector, "did:nuts:a", "application/did+json", checked.Add(-time.Duration(h-1)*time.Hour), 20)
+		}
+
+		detector.detect(now)
+
+		assert.Empty(t, detector.report().Anomalies)
+	})
+}
+
+func TestStore_GetAnomalies(t *testing.T) {
+	simulator := test.NewSimulator(t)
+	store := newTestStore(t, simulator)
+	store.Reconfigure(config.Config{AnomalyThreshold: 3, AnomalyMinCount: 10})
+	// the history is loaded, so the hours before the store was created are checked
+	store.anomalies.started = time.Time{}
+	controller := simulator.CreateDID(t)
+	signer := simulator.CreateDID(t, controller)
+	now := time.Now()
+	checked := now.Truncate(time.Hour).Add(-time.Hour)
+	for h := 1; h <= minBaseline; h++ {
+		simulator.AddTransaction(t, signer, "application/vc+json", checked.Add(-time.Duration(h)*time.Hour+30*time.Minute))
+	}
+	for i := 0; i < 20; i++ {
+		simulator.AddTransaction(t, signer, "application/vc+json", checked.Add(30*time.Minute))
+	}
+	for _, tx := range simulator.Transactions() {
+		if tx.ContentType == "application/vc+json" {
+			addTransactions(t, store, tx)
+		}
+	}
+
+	store.mutex.Lock()
+	store.anomalies.detect(now)
+	store.mutex.Unlock()
+
+	report := store.GetAnomalies()
+	assert.Equal(t, float64(3), report.Threshold)
+	assert.Equal(t, 10, report.MinCount)
+	require.Len(t, report.Anomalies, 2)
+	for _, anomaly := range report.Anomalies {
+		assert.Equal(t, AnomalySpike, anomaly.Type)
+		assert.Equal(t, uint32(20), anomaly.Count)
+	}
+	// the transactions are counted for the root DID of the signer
+	roots := []string{report.Anomalies[0].Key, report.Anomalies[1].Key}
+	assert.Contains(t, roots, controller.ID)
+	assert.NotContains(t, roots, signer.ID)
+}
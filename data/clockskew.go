@@ -0,0 +1,171 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxSkewedTransactions is the number of most recent skewed transactions that are kept
+const maxSkewedTransactions = 100
+
+// SkewedTransaction is a transaction with a signature time that is implausibly far from its arrival time
+type SkewedTransaction struct {
+	Signer      string    `json:"signer"`
+	ContentType string    `json:"content_type"`
+	LC          int       `json:"lc"`
+	SigTime     time.Time `json:"sigt"`
+	Received    time.Time `json:"received"`
+	// Skew is the signature time minus the arrival time in seconds, it's positive for transactions signed in the future
+	Skew float64 `json:"skew"`
+}
+
+// SignerSkew contains the number of skewed transactions of a signer
+type SignerSkew struct {
+	Signer string `json:"signer"`
+	// Future is the number of transactions signed too far after their arrival
+	Future int `json:"future"`
+	// Past is the number of transactions signed too long before their arrival
+	Past int `json:"past"`
+	// MaxSkew is the skew in seconds of the transaction that is furthest off
+	MaxSkew float64 `json:"max_skew"`
+	// LastSeen is the arrival time of the last skewed transaction
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// ClockSkewReport lists the signers and transactions with an implausible signature time
+type ClockSkewReport struct {
+	// MaxFuture is the configured time in seconds a signature time may be after the arrival time
+	MaxFuture float64 `json:"max_future"`
+	// MaxPast is the configured time in seconds a signature time may be before the arrival time
+	MaxPast float64 `json:"max_past"`
+	Future  int     `json:"future"`
+	Past    int     `json:"past"`
+	// Signers is sorted on the number of skewed transactions, descending
+	Signers []SignerSkew `json:"signers"`
+	// Transactions contains the most recent skewed transactions, the latest first
+	Transactions []SkewedTransaction `json:"transactions"`
+}
+
+// clockSkewDetector keeps track of transactions with a signature time far from their arrival time, which indicates a signer with a wrong clock.
+// Signature times in the future are checked for all transactions, times in the past only for transactions from the NATS stream:
+// the history contains transactions that were signed long ago.
+type clockSkewDetector struct {
+	mutex        sync.Mutex
+	maxFuture    time.Duration
+	maxPast      time.Duration
+	future       int
+	past         int
+	signers      map[string]*SignerSkew
+	transactions []SkewedTransaction
+}
+
+func newClockSkewDetector(maxFuture, maxPast time.Duration) *clockSkewDetector {
+	return &clockSkewDetector{
+		maxFuture: maxFuture,
+		maxPast:   maxPast,
+		signers:   map[string]*SignerSkew{},
+	}
+}
+
+// reconfigure changes the thresholds, transactions that have been reported stay in the report
+func (d *clockSkewDetector) reconfigure(maxFuture, maxPast time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.maxFuture = maxFuture
+	d.maxPast = maxPast
+}
+
+// check records the transaction if its signature time is too far from its arrival time. Transactions without an arrival time arrived now.
+func (d *clockSkewDetector) check(transaction Transaction, now time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	received := transaction.Received
+	if received.IsZero() {
+		received = now
+	}
+	skew := transaction.SigTime.Sub(received)
+	future := d.maxFuture > 0 && skew > d.maxFuture
+	past := d.maxPast > 0 && !transaction.Received.IsZero() && -skew > d.maxPast
+	if !future && !past {
+		return
+	}
+
+	signer, ok := d.signers[transaction.Signer]
+	if !ok {
+		signer = &SignerSkew{Signer: transaction.Signer}
+		d.signers[transaction.Signer] = signer
+	}
+	if future {
+		d.future++
+		signer.Future++
+	} else {
+		d.past++
+		signer.Past++
+	}
+	if math.Abs(skew.Seconds()) >= math.Abs(signer.MaxSkew) {
+		signer.MaxSkew = skew.Seconds()
+	}
+	signer.LastSeen = received
+
+	d.transactions = append(d.transactions, SkewedTransaction{
+		Signer:      transaction.Signer,
+		ContentType: transaction.ContentType,
+		LC:          transaction.LC,
+		SigTime:     transaction.SigTime,
+		Received:    received,
+		Skew:        skew.Seconds(),
+	})
+	if len(d.transactions) > maxSkewedTransactions {
+		d.transactions = d.transactions[len(d.transactions)-maxSkewedTransactions:]
+	}
+}
+
+func (d *clockSkewDetector) report() ClockSkewReport {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	report := ClockSkewReport{
+		MaxFuture:    d.maxFuture.Seconds(),
+		MaxPast:      d.maxPast.Seconds(),
+		Future:       d.future,
+		Past:         d.past,
+		Signers:      make([]SignerSkew, 0, len(d.signers)),
+		Transactions: make([]SkewedTransaction, len(d.transactions)),
+	}
+	for _, signer := range d.signers {
+		report.Signers = append(report.Signers, *signer)
+	}
+	sort.Slice(report.Signers, func(i, j int) bool {
+		a, b := report.Signers[i], report.Signers[j]
+		if a.Future+a.Past != b.Future+b.Past {
+			return a.Future+a.Past > b.Future+b.Past
+		}
+		return a.Signer < b.Signer
+	})
+	for i, transaction := range d.transactions {
+		report.Transactions[len(d.transactions)-1-i] = transaction
+	}
+	return report
+}
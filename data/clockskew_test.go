@@ -0,0 +1,93 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockSkewDetector_check(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	transaction := func(signer string, sigTime time.Time, received time.Time) Transaction {
+		return Transaction{Signer: signer, ContentType: "application/vc+json", SigTime: sigTime, Received: received}
+	}
+
+	t.Run("reports transactions signed in the future", func(t *testing.T) {
+		detector := newClockSkewDetector(time.Minute, time.Hour)
+
+		detector.check(transaction("did:nuts:a", now.Add(2*time.Minute), now), now)
+		detector.check(transaction("did:nuts:a", now.Add(time.Minute), now), now)
+		// transactions from the history arrive now
+		detector.check(transaction("did:nuts:b", now.Add(time.Hour), time.Time{}), now)
+
+		report := detector.report()
+		assert.Equal(t, 2, report.Future)
+		assert.Equal(t, 0, report.Past)
+		require.Len(t, report.Signers, 2)
+		assert.Equal(t, SignerSkew{Signer: "did:nuts:a", Future: 1, MaxSkew: 120, LastSeen: now}, report.Signers[0])
+		assert.Equal(t, SignerSkew{Signer: "did:nuts:b", Future: 1, MaxSkew: 3600, LastSeen: now}, report.Signers[1])
+		require.Len(t, report.Transactions, 2)
+		assert.Equal(t, "did:nuts:b", report.Transactions[0].Signer)
+		assert.Equal(t, now.Add(time.Hour), report.Transactions[0].SigTime)
+		assert.Equal(t, float64(3600), report.Transactions[0].Skew)
+	})
+	t.Run("reports transactions from the stream signed in the past", func(t *testing.T) {
+		detector := newClockSkewDetector(time.Minute, time.Hour)
+
+		detector.check(transaction("did:nuts:a", now.Add(-2*time.Hour), now), now)
+		detector.check(transaction("did:nuts:a", now.Add(-time.Hour), now), now)
+		// the history contains old transactions
+		detector.check(transaction("did:nuts:a", now.Add(-24*time.Hour), time.Time{}), now)
+
+		report := detector.report()
+		assert.Equal(t, 0, report.Future)
+		assert.Equal(t, 1, report.Past)
+		assert.Equal(t, []SignerSkew{{Signer: "did:nuts:a", Past: 1, MaxSkew: -7200, LastSeen: now}}, report.Signers)
+	})
+	t.Run("disabled checks", func(t *testing.T) {
+		detector := newClockSkewDetector(0, 0)
+
+		detector.check(transaction("did:nuts:a", now.Add(time.Hour), now), now)
+		detector.check(transaction("did:nuts:a", now.Add(-24*time.Hour), now), now)
+
+		report := detector.report()
+		assert.Empty(t, report.Signers)
+		assert.Empty(t, report.Transactions)
+	})
+	t.Run("keeps the most recent transactions", func(t *testing.T) {
+		detector := newClockSkewDetector(time.Minute, time.Hour)
+
+		for i := 0; i < maxSkewedTransactions+10; i++ {
+			detector.check(transaction(fmt.Sprintf("did:nuts:%d", i%3), now.Add(time.Hour), now), now)
+		}
+
+		report := detector.report()
+		assert.Equal(t, maxSkewedTransactions+10, report.Future)
+		assert.Len(t, report.Transactions, maxSkewedTransactions)
+		assert.Equal(t, fmt.Sprintf("did:nuts:%d", (maxSkewedTransactions+9)%3), report.Transactions[0].Signer)
+		// the signers with most skewed transactions come first
+		assert.Equal(t, "did:nuts:0", report.Signers[0].Signer)
+		assert.Equal(t, 37, report.Signers[0].Future)
+	})
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"context"
+	"log"
+	"nuts-foundation/nuts-monitor/client"
+	"sort"
+	"sync"
+	"time"
+)
+
+// consistencyHistoryLength is the number of samples that are kept per peer
+const consistencyHistoryLength = 60
+
+// ConsistencySample is a single comparison between the transaction count of our node and a peer
+type ConsistencySample struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Ours is the transaction count of our node
+	Ours int `json:"ours"`
+	// Theirs is the transaction count of the peer
+	Theirs int `json:"theirs"`
+}
+
+// Difference returns the number of transactions the peer leads (positive) or lags (negative)
+func (cs ConsistencySample) Difference() int {
+	return cs.Theirs - cs.Ours
+}
+
+// PeerConsistency contains the consistency state of a single peer
+type PeerConsistency struct {
+	PeerID string `json:"peer_id"`
+	// TransactionCount is the last known transaction count of the peer
+	TransactionCount int `json:"tx_count"`
+	// Difference is the number of transactions the peer leads (positive) or lags (negative) in the last sample
+	Difference int `json:"difference"`
+	// OutOfSyncSince is set when the difference exceeds the threshold
+	OutOfSyncSince *time.Time `json:"out_of_sync_since,omitempty"`
+	// Diverged is true when the peer has been out of sync for longer than the grace period
+	Diverged bool `json:"diverged"`
+	// History contains the most recent samples, oldest first
+	History []ConsistencySample `json:"history"`
+}
+
+// ConsistencyReport is the result of the most recent consistency check
+type ConsistencyReport struct {
+	// Timestamp of the last check, zero if no check has been done yet
+	Timestamp time.Time `json:"timestamp"`
+	// DagXor is the XOR of all transaction references of our node. It isn't compared with the peers, the node doesn't report their XOR.
+	DagXor string `json:"dag_xor"`
+	// TransactionCount is the transaction count of our node
+	TransactionCount int `json:"tx_count"`
+	// Threshold is the maximum difference in transaction count before a peer is considered out of sync
+	Threshold int `json:"threshold"`
+	// Peers contains the consistency state per peer, sorted by PeerID
+	Peers []PeerConsistency `json:"peers"`
+	// Diverged lists the PeerIDs of diverged peers
+	Diverged []string `json:"diverged"`
+	// Error contains the error of the last check, if any
+	Error string `json:"error,omitempty"`
+}
+
+// ConsistencyChecker periodically compares the transaction count of our node with the counts reported by its peers.
+// Peers that lag or lead more than the threshold for longer than the grace period are flagged as diverged.
+// The DAGs themselves can't be compared, since the peer diagnostics of the node don't contain the XOR of the peers:
+// a peer with the same number of transactions but a different DAG isn't detected.
+type ConsistencyChecker struct {
+	client      client.HTTPClient
+	interval    time.Duration
+	threshold   int
+	gracePeriod time.Duration
+	mutex       sync.RWMutex
+	report      ConsistencyReport
+	peers       map[string]*PeerConsistency
+}
+
+// NewConsistencyChecker creates a new ConsistencyChecker. Call Start to begin checking.
+func NewConsistencyChecker(client client.HTTPClient, interval time.Duration, threshold int, gracePeriod time.Duration) *ConsistencyChecker {
+	return &ConsistencyChecker{
+		client:      client,
+		interval:    interval,
+		threshold:   threshold,
+		gracePeriod: gracePeriod,
+		report:      ConsistencyReport{Threshold: threshold, Peers: []PeerConsistency{}, Diverged: []string{}},
+		peers:       map[string]*PeerConsistency{},
+	}
+}
+
+// Start runs a check every interval until the context is cancelled
+func (c *ConsistencyChecker) Start(ctx context.Context) {
+	go func() {
+		interval := c.getInterval()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.Check(ctx); err != nil {
+				log.Printf("failed to check DAG consistency: %s", err)
+			}
+			// the interval may have been changed by Reconfigure
+			if current := c.getInterval(); current != interval {
+				interval = current
+				ticker.Reset(interval)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Reconfigure changes the settings of the checker. The threshold and grace period are used by the next check,
+// the new interval takes effect after the next check.
+func (c *ConsistencyChecker) Reconfigure(interval time.Duration, threshold int, gracePeriod time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.interval = interval
+	c.threshold = threshold
+	c.gracePeriod = gracePeriod
+}
+
+func (c *ConsistencyChecker) getInterval() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.interval
+}
+
+// Check compares the transaction counts once and updates the report
+func (c *ConsistencyChecker) Check(ctx context.Context) error {
+	diagnostics, err := c.client.Diagnostics(ctx)
+	if err != nil {
+		c.setError(err)
+		return err
+	}
+	peerDiagnostics, err := c.client.PeerDiagnostics(ctx)
+	if err != nil {
+		c.setError(err)
+		return err
+	}
+
+	now := time.Now()
+	ours := diagnostics.Network.State.TransactionCount
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	seen := map[string]bool{}
+	for k, v := range peerDiagnostics {
+		if v.TransactionNum == nil {
+			continue
+		}
+		peerID := client.RealPeerID(k)
+		seen[peerID] = true
+		c.record(peerID, ConsistencySample{Timestamp: now, Ours: ours, Theirs: int(*v.TransactionNum)})
+	}
+	// peers that are no longer connected are forgotten
+	for peerID := range c.peers {
+		if !seen[peerID] {
+			delete(c.peers, peerID)
+		}
+	}
+
+	report := ConsistencyReport{
+		Timestamp:        now,
+		DagXor:           diagnostics.Network.State.DagXor,
+		TransactionCount: ours,
+		Threshold:        c.threshold,
+		Peers:            make([]PeerConsistency, 0, len(c.peers)),
+		Diverged:         []string{},
+	}
+	for _, p := range c.peers {
+		peer := *p
+		peer.History = append([]ConsistencySample{}, p.History...)
+		report.Peers = append(report.Peers, peer)
+	}
+	sort.Slice(report.Peers, func(i, j int) bool {
+		return report.Peers[i].PeerID < report.Peers[j].PeerID
+	})
+	for _, p := range report.Peers {
+		if p.Diverged {
+			report.Diverged = append(report.Diverged, p.PeerID)
+		}
+	}
+	c.report = report
+
+	return nil
+}
+
+// record adds a sample for the given peer and updates its out-of-sync state. The caller must hold the lock.
+func (c *ConsistencyChecker) record(peerID string, sample ConsistencySample) {
+	peer, ok := c.peers[peerID]
+	if !ok {
+		peer = &PeerConsistency{PeerID: peerID}
+		c.peers[peerID] = peer
+	}
+
+	peer.History = append(peer.History, sample)
+	if len(peer.History) > consistencyHistoryLength {
+		peer.History = peer.History[len(peer.History)-consistencyHistoryLength:]
+	}
+	peer.TransactionCount = sample.Theirs
+	peer.Difference = sample.Difference()
+
+	if abs(peer.Difference) <= c.threshold {
+		peer.OutOfSyncSince = nil
+		peer.Diverged = false
+		return
+	}
+	if peer.OutOfSyncSince == nil {
+		since := sample.Timestamp
+		peer.OutOfSyncSince = &since
+	}
+	peer.Diverged = sample.Timestamp.Sub(*peer.OutOfSyncSince) >= c.gracePeriod
+}
+
+func (c *ConsistencyChecker) setError(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.report.Error = err.Error()
+}
+
+// Report returns the result of the most recent check
+func (c *ConsistencyChecker) Report() ConsistencyReport {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.report
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"nuts-foundation/nuts-monitor/client"
+	"nuts-foundation/nuts-monitor/client/diagnostics"
+	"nuts-foundation/nuts-monitor/client/network"
+	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/test"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistencyChecker_Check(t *testing.T) {
+	t.Run("reports the difference per peer", func(t *testing.T) {
+		checker := consistencyTestChecker(t, 100, map[string]float32{"in-sync": 98, "behind-bootstrap": 50}, time.Minute)
+
+		err := checker.Check(context.Background())
+
+		require.NoError(t, err)
+		report := checker.Report()
+		assert.Equal(t, 100, report.TransactionCount)
+		assert.Equal(t, "xor", report.DagXor)
+		require.Len(t, report.Peers, 2)
+		assert.Equal(t, "behind", report.Peers[0].PeerID)
+		assert.Equal(t, -50, report.Peers[0].Difference)
+		assert.NotNil(t, report.Peers[0].OutOfSyncSince)
+		assert.False(t, report.Peers[0].Diverged)
+		assert.Equal(t, "in-sync", report.Peers[1].PeerID)
+		assert.Nil(t, report.Peers[1].OutOfSyncSince)
+		assert.Empty(t, report.Diverged)
+	})
+
+	t.Run("flags peers that stay out of sync longer than the grace period", func(t *testing.T) {
+		checker := consistencyTestChecker(t, 100, map[string]float32{"behind": 50}, 0)
+
+		_ = checker.Check(context.Background())
+
+		report := checker.Report()
+		require.Len(t, report.Peers, 1)
+		assert.True(t, report.Peers[0].Diverged)
+		assert.Equal(t, []string{"behind"}, report.Diverged)
+	})
+
+	t.Run("keeps a limited history", func(t *testing.T) {
+		checker := consistencyTestChecker(t, 100, map[string]float32{"peer": 100}, time.Minute)
+
+		for i := 0; i < consistencyHistoryLength+5; i++ {
+			_ = checker.Check(context.Background())
+		}
+
+		assert.Len(t, checker.Report().Peers[0].History, consistencyHistoryLength)
+	})
+
+	t.Run("records the error when the node can't be reached", func(t *testing.T) {
+		ts := test.BasicTestNode(t)
+		checker := NewConsistencyChecker(client.HTTPClient{Config: config.Config{NutsNodeAddr: ts.URL()}}, time.Minute, 10, time.Minute)
+
+		err := checker.Check(context.Background())
+
+		assert.Error(t, err)
+		assert.NotEmpty(t, checker.Report().Error)
+	})
+}
+
+func TestConsistencyChecker_record(t *testing.T) {
+	checker := NewConsistencyChecker(client.HTTPClient{}, time.Minute, 10, time.Minute)
+	now := time.Now()
+
+	checker.record("peer", ConsistencySample{Timestamp: now, Ours: 100, Theirs: 120})
+	checker.record("peer", ConsistencySample{Timestamp: now.Add(2 * time.Minute), Ours: 100, Theirs: 120})
+
+	peer := checker.peers["peer"]
+	assert.Equal(t, 20, peer.Difference)
+	assert.Equal(t, now, *peer.OutOfSyncSince)
+	assert.True(t, peer.Diverged)
+
+	checker.record("peer", ConsistencySample{Timestamp: now.Add(3 * time.Minute), Ours: 120, Theirs: 120})
+
+	assert.Nil(t, peer.OutOfSyncSince)
+	assert.False(t, peer.Diverged)
+}
+
+func consistencyTestChecker(t *testing.T, ours int, peers map[string]float32, gracePeriod time.Duration) *ConsistencyChecker {
+	ts := test.BasicTestNode(t)
+	d := diagnostics.Diagnostics{}
+	d.Network.State.TransactionCount = ours
+	d.Network.State.DagXor = "xor"
+	diagnosticsBytes, _ := json.Marshal(d)
+	peerDiagnostics := map[string]network.PeerDiagnostics{}
+	for peerID, count := range peers {
+		c := count
+		peerDiagnostics[peerID] = network.PeerDiagnostics{TransactionNum: &c}
+	}
+	peerDiagnosticsBytes, _ := json.Marshal(peerDiagnostics)
+	ts.HandleFunc("/status/diagnostics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(diagnosticsBytes)
+	})
+	ts.HandleFunc("/internal/network/v1/diagnostics/peers", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(peerDiagnosticsBytes)
+	})
+
+	return NewConsistencyChecker(client.HTTPClient{Config: config.Config{NutsNodeAddr: ts.URL()}}, time.Minute, 10, gracePeriod)
+}
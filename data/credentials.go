@@ -0,0 +1,346 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Content types of the transactions that issue and revoke credentials
+const (
+	credentialType = "application/vc+json"
+	revocationType = "application/ld+json;type=revocation"
+)
+
+// UnknownCredentialType is reported for credentials without a type and revocations of credentials that haven't been seen
+const UnknownCredentialType = "unknown"
+
+// maxMassRevocations is the number of most recent mass revocations that are kept
+const maxMassRevocations = 100
+
+// maxCredentialRefs is the number of transactions with the highest LC that are kept to ignore transactions that are added twice
+const maxCredentialRefs = 10000
+
+// CredentialStats contains the issuance and revocation activity of an issuer for a credential type
+type CredentialStats struct {
+	Issuer string `json:"issuer"`
+	// Type contains the types of the credential other than VerifiableCredential, separated by a comma
+	Type    string `json:"type"`
+	Issued  uint32 `json:"issued"`
+	Revoked uint32 `json:"revoked"`
+	// IssuedLastHour and RevokedLastHour are the counts since the start of the current hour
+	IssuedLastHour  uint32 `json:"issued_last_hour"`
+	RevokedLastHour uint32 `json:"revoked_last_hour"`
+	// IssuanceRate and RevocationRate are the average number of credentials per hour over the last 24 hours
+	IssuanceRate   float64 `json:"issuance_rate"`
+	RevocationRate float64 `json:"revocation_rate"`
+}
+
+// MassRevocation is reported when an issuer revokes at least the threshold number of credentials within the window.
+// Revocations that follow within the window are added to it.
+type MassRevocation struct {
+	Issuer string `json:"issuer"`
+	// Start is the signature time of the first revocation
+	Start time.Time `json:"start"`
+	// End is the signature time of the last revocation
+	End   time.Time `json:"end"`
+	Count int       `json:"count"`
+	// Types contains the number of revoked credentials per type
+	Types map[string]int `json:"types"`
+}
+
+// CredentialReport contains the credential activity per issuer and type, and the most recent mass revocations
+type CredentialReport struct {
+	// Threshold is the configured number of revocations that is reported as a mass revocation, 0 if not checked
+	Threshold int `json:"threshold"`
+	// Window is the configured time in seconds in which the revocations must be signed
+	Window float64 `json:"window"`
+	// Stats is sorted on issuer and type
+	Stats []CredentialStats `json:"stats"`
+	// MassRevocations contains the most recent mass revocations, the latest first
+	MassRevocations []MassRevocation `json:"mass_revocations"`
+}
+
+// credentialPayload contains the fields of a credential or revocation that are used for the statistics
+type credentialPayload struct {
+	ID string `json:"id"`
+	// Issuer is either a DID or an object with the DID as id
+	Issuer interface{} `json:"issuer"`
+	// Type is either a single type or a list of types
+	Type interface{} `json:"type"`
+	// Subject is the ID of the revoked credential
+	Subject string `json:"subject"`
+}
+
+// revocation is a recent revocation of an issuer, for the mass revocation check
+type revocation struct {
+	sigTime        time.Time
+	credentialType string
+}
+
+// credentialRef is a transaction that has been classified
+type credentialRef struct {
+	ref string
+	lc  int
+}
+
+// credentialTracker classifies credentials and revocations per issuer and type.
+type credentialTracker struct {
+	threshold int
+	window    time.Duration
+	// refs contains the transactions with the highest LC that have been classified, ordered by LC
+	refs  []credentialRef
+	stats map[string]*CredentialStats
+	// types contains the type per credential ID, to classify revocations
+	types map[string]string
+	// issued and revoked count per hour for the last day, per issuer and type
+	issued  *slidingWindow
+	revoked *slidingWindow
+	// revocations contains the revocations per issuer within the window before the last one, ordered on signature time
+	revocations map[string][]revocation
+	// ongoing contains the last mass revocation per issuer, it's extended with revocations within the window after it
+	ongoing         map[string]*MassRevocation
+	massRevocations []*MassRevocation
+}
+
+func newCredentialTracker(threshold int, window time.Duration) *credentialTracker {
+	c := &credentialTracker{
+		threshold:   threshold,
+		window:      window,
+		stats:       map[string]*CredentialStats{},
+		types:       map[string]string{},
+		issued:      NewSlidingWindow(time.Hour, 24*time.Hour, time.Minute),
+		revoked:     NewSlidingWindow(time.Hour, 24*time.Hour, time.Minute),
+		revocations: map[string][]revocation{},
+		ongoing:     map[string]*MassRevocation{},
+	}
+	// the rates are per hour, a correction for the clock drift of signers isn't needed
+	c.issued.clockdrift = 0
+	c.revoked.clockdrift = 0
+	return c
+}
+
+// add classifies the credential or revocation in the transaction. The payload may be nil if it couldn't be fetched,
+// the transaction is then counted for its signer with an unknown type. A transaction that has been added already is ignored.
+func (c *credentialTracker) add(transaction Transaction, payload []byte) {
+	if !c.remember(transaction) {
+		return
+	}
+	parsed := credentialPayload{}
+	if len(payload) > 0 {
+		_ = json.Unmarshal(payload, &parsed)
+	}
+	issuer := parsed.issuer()
+	if issuer == "" {
+		issuer = transaction.Signer
+	}
+
+	if transaction.ContentType == revocationType {
+		credentialType, ok := c.types[parsed.Subject]
+		if !ok {
+			credentialType = UnknownCredentialType
+		}
+		c.statsFor(issuer, credentialType).Revoked++
+		c.revoked.AddCount(statsKey(issuer, credentialType), transaction.SigTime)
+		c.checkMassRevocation(issuer, revocation{sigTime: transaction.SigTime, credentialType: credentialType})
+		return
+	}
+
+	credentialType := parsed.credentialType()
+	if parsed.ID != "" {
+		c.types[parsed.ID] = credentialType
+	}
+	c.statsFor(issuer, credentialType).Issued++
+	c.issued.AddCount(statsKey(issuer, credentialType), transaction.SigTime)
+}
+
+// remember records the transaction and returns false if it has been added already.
+// The history is loaded while new transactions arrive, so the transactions with the highest LC are kept.
+func (c *credentialTracker) remember(transaction Transaction) bool {
+	i := sort.Search(len(c.refs), func(i int) bool { return c.refs[i].lc >= transaction.LC })
+	for j := i; j < len(c.refs) && c.refs[j].lc == transaction.LC; j++ {
+		if c.refs[j].ref == transaction.Ref {
+			return false
+		}
+	}
+	if i == 0 && len(c.refs) == maxCredentialRefs {
+		// older than the kept transactions
+		return true
+	}
+	c.refs = slices.Insert(c.refs, i, credentialRef{ref: transaction.Ref, lc: transaction.LC})
+	if len(c.refs) > maxCredentialRefs {
+		c.refs = c.refs[1:]
+	}
+	return true
+}
+
+func (c *credentialTracker) statsFor(issuer string, credentialType string) *CredentialStats {
+	key := statsKey(issuer, credentialType)
+	stats, ok := c.stats[key]
+	if !ok {
+		stats = &CredentialStats{Issuer: issuer, Type: credentialType}
+		c.stats[key] = stats
+	}
+	return stats
+}
+
+// checkMassRevocation reports the issuer when it revoked at least threshold credentials within the window
+func (c *credentialTracker) checkMassRevocation(issuer string, current revocation) {
+	if c.threshold <= 0 {
+		return
+	}
+	// revocations close to an ongoing mass revocation are part of it
+	if event, ok := c.ongoing[issuer]; ok && !current.sigTime.Before(event.Start.Add(-c.window)) && !current.sigTime.After(event.End.Add(c.window)) {
+		event.Count++
+		event.Types[current.credentialType]++
+		if current.sigTime.Before(event.Start) {
+			event.Start = current.sigTime
+		}
+		if current.sigTime.After(event.End) {
+			event.End = current.sigTime
+		}
+		return
+	}
+
+	// the history is ordered on LC, so the signature times are nearly ordered
+	recent := c.revocations[issuer]
+	i := sort.Search(len(recent), func(i int) bool { return recent[i].sigTime.After(current.sigTime) })
+	recent = append(recent, revocation{})
+	copy(recent[i+1:], recent[i:])
+	recent[i] = current
+	last := recent[len(recent)-1].sigTime
+	first := sort.Search(len(recent), func(i int) bool { return !recent[i].sigTime.Before(last.Add(-c.window)) })
+	recent = recent[first:]
+	c.revocations[issuer] = recent
+
+	if len(recent) < c.threshold {
+		return
+	}
+	event := &MassRevocation{Issuer: issuer, Start: recent[0].sigTime, End: last, Count: len(recent), Types: map[string]int{}}
+	for _, r := range recent {
+		event.Types[r.credentialType]++
+	}
+	c.ongoing[issuer] = event
+	c.revocations[issuer] = nil
+	c.massRevocations = append(c.massRevocations, event)
+	if len(c.massRevocations) > maxMassRevocations {
+		c.massRevocations = c.massRevocations[len(c.massRevocations)-maxMassRevocations:]
+	}
+}
+
+func (c *credentialTracker) report() CredentialReport {
+	now := time.Now()
+	issued := consolidateLocked(c.issued, now)
+	revoked := consolidateLocked(c.revoked, now)
+
+	report := CredentialReport{
+		Threshold:       c.threshold,
+		Window:          c.window.Seconds(),
+		Stats:           make([]CredentialStats, 0, len(c.stats)),
+		MassRevocations: make([]MassRevocation, len(c.massRevocations)),
+	}
+	for key, stats := range c.stats {
+		result := *stats
+		result.IssuedLastHour, result.IssuanceRate = rates(issued[key])
+		result.RevokedLastHour, result.RevocationRate = rates(revoked[key])
+		report.Stats = append(report.Stats, result)
+	}
+	sort.Slice(report.Stats, func(i, j int) bool {
+		a, b := report.Stats[i], report.Stats[j]
+		if a.Issuer != b.Issuer {
+			return a.Issuer < b.Issuer
+		}
+		return a.Type < b.Type
+	})
+	for i, event := range c.massRevocations {
+		result := *event
+		result.Types = make(map[string]int, len(event.Types))
+		for credentialType, count := range event.Types {
+			result.Types[credentialType] = count
+		}
+		report.MassRevocations[len(c.massRevocations)-1-i] = result
+	}
+	return report
+}
+
+func consolidateLocked(window *slidingWindow, now time.Time) map[string][]DataPoint {
+	window.mutex.Lock()
+	defer window.mutex.Unlock()
+
+	return window.consolidate(now)
+}
+
+// rates returns the count of the current hour and the average count per hour, for the hourly data points of a day
+func rates(dataPoints []DataPoint) (uint32, float64) {
+	if len(dataPoints) == 0 {
+		return 0, 0
+	}
+	var total uint32
+	for _, dataPoint := range dataPoints {
+		total += dataPoint.Count
+	}
+	return dataPoints[len(dataPoints)-1].Count, float64(total) / float64(len(dataPoints))
+}
+
+func statsKey(issuer string, credentialType string) string {
+	return issuer + " " + credentialType
+}
+
+func (p credentialPayload) issuer() string {
+	switch issuer := p.Issuer.(type) {
+	case string:
+		return issuer
+	case map[string]interface{}:
+		id, _ := issuer["id"].(string)
+		return id
+	}
+	return ""
+}
+
+// credentialType returns the types other than VerifiableCredential, separated by a comma
+func (p credentialPayload) credentialType() string {
+	var types []string
+	switch value := p.Type.(type) {
+	case string:
+		types = []string{value}
+	case []interface{}:
+		for _, t := range value {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	var result []string
+	for _, t := range types {
+		if t != "VerifiableCredential" {
+			result = append(result, t)
+		}
+	}
+	if len(result) == 0 {
+		if len(types) > 0 {
+			return "VerifiableCredential"
+		}
+		return UnknownCredentialType
+	}
+	return strings.Join(result, ",")
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"fmt"
+	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/test"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialTracker(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	count := 0
+	transaction := func(contentType string, sigTime time.Time) Transaction {
+		count++
+		return Transaction{Ref: fmt.Sprintf("%d", count), Signer: "did:nuts:signer", ContentType: contentType, SigTime: sigTime}
+	}
+
+	t.Run("classifies on issuer and type", func(t *testing.T) {
+		tracker := newCredentialTracker(0, time.Hour)
+
+		tracker.add(transaction(credentialType, now), []byte(`{"id":"1","issuer":"did:nuts:a","type":["VerifiableCredential","NutsOrganizationCredential"]}`))
+		tracker.add(transaction(credentialType, now), []byte(`{"id":"2","issuer":{"id":"did:nuts:a"},"type":"VerifiableCredential"}`))
+		tracker.add(transaction(credentialType, now.Add(-2*time.Hour)), []byte(`{"id":"3","issuer":"did:nuts:a","type":["VerifiableCredential","NutsOrganizationCredential"]}`))
+		tracker.add(transaction(revocationType, now), []byte(`{"issuer":"did:nuts:a","subject":"1"}`))
+		tracker.add(transaction(revocationType, now), []byte(`{"issuer":"did:nuts:b","subject":"4"}`))
+		// the payload couldn't be fetched
+		tracker.add(transaction(credentialType, now), nil)
+
+		report := tracker.report()
+		assert.Empty(t, report.MassRevocations)
+		assert.Equal(t, []CredentialStats{
+			{Issuer: "did:nuts:a", Type: "NutsOrganizationCredential", Issued: 2, Revoked: 1, IssuedLastHour: 1, RevokedLastHour: 1, IssuanceRate: 2.0 / 24, RevocationRate: 1.0 / 24},
+			{Issuer: "did:nuts:a", Type: "VerifiableCredential", Issued: 1, IssuedLastHour: 1, IssuanceRate: 1.0 / 24},
+			{Issuer: "did:nuts:b", Type: UnknownCredentialType, Revoked: 1, RevokedLastHour: 1, RevocationRate: 1.0 / 24},
+			{Issuer: "did:nuts:signer", Type: UnknownCredentialType, Issued: 1, IssuedLastHour: 1, IssuanceRate: 1.0 / 24},
+		}, report.Stats)
+	})
+	t.Run("ignores transactions that are added twice", func(t *testing.T) {
+		tracker := newCredentialTracker(0, time.Hour)
+		at := func(ref string, lc int) Transaction {
+			transaction := transaction(credentialType, now)
+			transaction.Ref = ref
+			transaction.LC = lc
+			return transaction
+		}
+
+		// new transactions arrive while the history is loaded
+		tracker.add(at("2", 2), nil)
+		tracker.add(at("1", 1), nil)
+		tracker.add(at("2", 2), nil)
+
+		assert.Equal(t, uint32(2), tracker.report().Stats[0].Issued)
+	})
+	t.Run("reports mass revocations", func(t *testing.T) {
+		tracker := newCredentialTracker(3, time.Hour)
+		revoke := func(sigTime time.Time) {
+			tracker.add(transaction(revocationType, sigTime), []byte(`{"issuer":"did:nuts:a","subject":"1"}`))
+		}
+
+		// spread over more than the window
+		revoke(now.Add(-5 * time.Hour))
+		revoke(now.Add(-3 * time.Hour))
+		revoke(now.Add(-1 * time.Hour))
+		assert.Empty(t, tracker.report().MassRevocations)
+
+		// the revocation out of order completes the first mass revocation, the next one extends it
+		revoke(now.Add(-30 * time.Minute))
+		revoke(now.Add(-45 * time.Minute))
+		revoke(now)
+		// a new mass revocation after the window
+		revoke(now.Add(3 * time.Hour))
+		revoke(now.Add(3 * time.Hour))
+		revoke(now.Add(3 * time.Hour))
+
+		report := tracker.report()
+		require.Len(t, report.MassRevocations, 2)
+		assert.Equal(t, MassRevocation{Issuer: "did:nuts:a", Start: now.Add(3 * time.Hour), End: now.Add(3 * time.Hour), Count: 3, Types: map[string]int{UnknownCredentialType: 3}}, report.MassRevocations[0])
+		assert.Equal(t, MassRevocation{Issuer: "did:nuts:a", Start: now.Add(-time.Hour), End: now, Count: 4, Types: map[string]int{UnknownCredentialType: 4}}, report.MassRevocations[1])
+	})
+	t.Run("mass revocation check is disabled", func(t *testing.T) {
+		tracker := newCredentialTracker(0, time.Hour)
+
+		for i := 0; i < 10; i++ {
+			tracker.add(transaction(revocationType, now), []byte(`{"issuer":"did:nuts:a","subject":"1"}`))
+		}
+
+		assert.Empty(t, tracker.report().MassRevocations)
+	})
+}
+
+func TestStore_GetCredentials(t *testing.T) {
+	simulator := test.NewSimulator(t)
+	store := newTestStore(t, simulator)
+	store.Reconfigure(config.Config{MassRevocationThreshold: 2, MassRevocationWindow: time.Hour})
+	issuer := simulator.CreateDID(t)
+	now := time.Now()
+	first := simulator.IssueCredential(t, issuer, "NutsOrganizationCredential", now)
+	second := simulator.IssueCredential(t, issuer, "NutsOrganizationCredential", now)
+	simulator.IssueCredential(t, issuer, "NutsEmployeeCredential", now)
+	simulator.RevokeCredential(t, issuer, first, now)
+	simulator.RevokeCredential(t, issuer, second, now)
+	// the payloads are fetched from the node, like for the history
+	addTransactions(t, store, simulator.Transactions()...)
+
+	report := store.GetCredentials()
+	assert.Equal(t, 2, report.Threshold)
+	assert.Equal(t, float64(3600), report.Window)
+	require.Len(t, report.Stats, 2)
+	assert.Equal(t, "NutsEmployeeCredential", report.Stats[0].Type)
+	assert.Equal(t, issuer.ID, report.Stats[0].Issuer)
+	assert.Equal(t, uint32(1), report.Stats[0].Issued)
+	assert.Equal(t, "NutsOrganizationCredential", report.Stats[1].Type)
+	assert.Equal(t, issuer.ID, report.Stats[1].Issuer)
+	assert.Equal(t, uint32(2), report.Stats[1].Issued)
+	assert.Equal(t, uint32(2), report.Stats[1].Revoked)
+	require.Len(t, report.MassRevocations, 1)
+	assert.Equal(t, map[string]int{"NutsOrganizationCredential": 2}, report.MassRevocations[0].Types)
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nuts-foundation/go-did/did"
+)
+
+// maxDIDChanges is the number of most recent DID document changes that are kept
+const maxDIDChanges = 10000
+
+// maxDocumentVersions is the number of most recent versions that are kept per DID document, to place versions that arrive late
+const maxDocumentVersions = 10
+
+// Reasons a DIDChange is unexpected
+const (
+	// UnexpectedSigner is reported when the document is updated by a DID that isn't a controller of the previous version
+	UnexpectedSigner = "signer_not_controller"
+	// UnexpectedControllers is reported when controllers are added to or removed from the document
+	UnexpectedControllers = "controllers_changed"
+	// UnexpectedAfterDeactivation is reported when a deactivated document is updated
+	UnexpectedAfterDeactivation = "updated_after_deactivation"
+)
+
+// DIDChange is the difference between a version of a DID document and the previous version
+type DIDChange struct {
+	DID string `json:"did"`
+	// Ref is the reference of the transaction that contains the version
+	Ref    string `json:"ref"`
+	LC     int    `json:"lc"`
+	Signer string `json:"signer"`
+	// SigTime is the signature time of the transaction
+	SigTime time.Time `json:"sigt"`
+	// Created is true for the first version of the document
+	Created bool `json:"created"`
+	// Deactivated is true when the version removes all verification methods and controllers
+	Deactivated                bool     `json:"deactivated"`
+	VerificationMethodsAdded   []string `json:"verification_methods_added,omitempty"`
+	VerificationMethodsRemoved []string `json:"verification_methods_removed,omitempty"`
+	ServicesAdded              []string `json:"services_added,omitempty"`
+	ServicesRemoved            []string `json:"services_removed,omitempty"`
+	// ServicesChanged contains the services with a different type or endpoint
+	ServicesChanged    []string `json:"services_changed,omitempty"`
+	ControllersAdded   []string `json:"controllers_added,omitempty"`
+	ControllersRemoved []string `json:"controllers_removed,omitempty"`
+	// Unexpected contains the reasons the change is a security signal: UnexpectedSigner, UnexpectedControllers or UnexpectedAfterDeactivation
+	Unexpected []string `json:"unexpected,omitempty"`
+}
+
+// didChangeLog keeps the last versions of every DID document and the changes between the versions.
+type didChangeLog struct {
+	// versions contains the most recent versions per DID, ordered by LC
+	versions map[string][]documentVersion
+	// changes is ordered by LC
+	changes []DIDChange
+}
+
+// documentVersion is a version of a DID document and the transaction that contains it
+type documentVersion struct {
+	ref      string
+	lc       int
+	signer   string
+	sigTime  time.Time
+	document did.Document
+}
+
+func newDIDChangeLog() *didChangeLog {
+	return &didChangeLog{
+		versions: map[string][]documentVersion{},
+	}
+}
+
+// add compares the document in the transaction with the previous version of the document and records the change.
+// Versions are compared in LC order: the history is loaded while new transactions arrive, so a version may arrive after a later one.
+// A transaction that has been added already, or that is older than the kept versions of the document, is ignored.
+func (l *didChangeLog) add(transaction Transaction, document did.Document) {
+	id := document.ID.String()
+	versions := l.versions[id]
+	for _, version := range versions {
+		if version.ref == transaction.Ref {
+			return
+		}
+	}
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].lc > transaction.LC })
+	if i == 0 && len(versions) == maxDocumentVersions {
+		return
+	}
+	version := documentVersion{ref: transaction.Ref, lc: transaction.LC, signer: transaction.Signer, sigTime: transaction.SigTime, document: document}
+	versions = slices.Insert(versions, i, version)
+
+	var previous *did.Document
+	if i > 0 {
+		previous = &versions[i-1].document
+	}
+	l.record(compare(id, previous, version))
+	if i+1 < len(versions) {
+		// the next version was compared with an older version
+		l.replace(compare(id, &document, versions[i+1]))
+	}
+	if len(versions) > maxDocumentVersions {
+		versions = versions[1:]
+	}
+	l.versions[id] = versions
+}
+
+// compare returns the change the version made to the previous version of the document, the document is created if there's no previous version
+func compare(id string, previous *did.Document, version documentVersion) DIDChange {
+	document := version.document
+	change := DIDChange{DID: id, Ref: version.ref, LC: version.lc, Signer: version.signer, SigTime: version.sigTime}
+	if previous == nil {
+		change.Created = true
+		return change
+	}
+
+	change.VerificationMethodsAdded, change.VerificationMethodsRemoved = diff(methodIDs(*previous), methodIDs(document))
+	change.ControllersAdded, change.ControllersRemoved = diff(controllerIDs(*previous), controllerIDs(document))
+	previousServices := services(*previous)
+	currentServices := services(document)
+	change.ServicesAdded, change.ServicesRemoved = diff(idSet(previousServices), idSet(currentServices))
+	for id, service := range currentServices {
+		if old, ok := previousServices[id]; ok && (old.Type != service.Type || !reflect.DeepEqual(old.ServiceEndpoint, service.ServiceEndpoint)) {
+			change.ServicesChanged = append(change.ServicesChanged, id)
+		}
+	}
+	sort.Strings(change.ServicesChanged)
+
+	wasDeactivated := deactivated(*previous)
+	change.Deactivated = !wasDeactivated && deactivated(document)
+
+	// a document without controllers is controlled by itself
+	allowed := controllerIDs(*previous)
+	if len(allowed) == 0 {
+		allowed = map[string]bool{id: true}
+	}
+	if !allowed[version.signer] {
+		change.Unexpected = append(change.Unexpected, UnexpectedSigner)
+	}
+	if len(change.ControllersAdded) > 0 || len(change.ControllersRemoved) > 0 {
+		change.Unexpected = append(change.Unexpected, UnexpectedControllers)
+	}
+	if wasDeactivated {
+		change.Unexpected = append(change.Unexpected, UnexpectedAfterDeactivation)
+	}
+	return change
+}
+
+// record adds the change in LC order, only the most recent changes are kept
+func (l *didChangeLog) record(change DIDChange) {
+	i := sort.Search(len(l.changes), func(i int) bool { return l.changes[i].LC > change.LC })
+	l.changes = slices.Insert(l.changes, i, change)
+	if len(l.changes) > maxDIDChanges {
+		l.changes = l.changes[len(l.changes)-maxDIDChanges:]
+	}
+}
+
+// replace replaces the recorded change of the same transaction, if it's still kept
+func (l *didChangeLog) replace(change DIDChange) {
+	for i := len(l.changes) - 1; i >= 0; i-- {
+		if l.changes[i].Ref == change.Ref {
+			l.changes[i] = change
+			return
+		}
+	}
+}
+
+// search returns the changes of the DIDs that contain the query, the latest first.
+// Only unexpected changes are returned if unexpected is true, at most limit changes are returned if limit is positive.
+func (l *didChangeLog) search(query string, unexpected bool, limit int) []DIDChange {
+	result := make([]DIDChange, 0)
+	for i := len(l.changes) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		change := l.changes[i]
+		if !strings.Contains(change.DID, query) || (unexpected && len(change.Unexpected) == 0) {
+			continue
+		}
+		result = append(result, change)
+	}
+	return result
+}
+
+// deactivated returns true if the document can't be changed anymore: it has no verification methods and no controllers
+func deactivated(document did.Document) bool {
+	return len(document.VerificationMethod) == 0 && len(document.Controller) == 0
+}
+
+func methodIDs(document did.Document) map[string]bool {
+	result := map[string]bool{}
+	for _, method := range document.VerificationMethod {
+		result[method.ID.String()] = true
+	}
+	return result
+}
+
+func controllerIDs(document did.Document) map[string]bool {
+	result := map[string]bool{}
+	for _, controller := range document.Controller {
+		result[controller.String()] = true
+	}
+	return result
+}
+
+func services(document did.Document) map[string]did.Service {
+	result := map[string]did.Service{}
+	for _, service := range document.Service {
+		result[service.ID.String()] = service
+	}
+	return result
+}
+
+func idSet[V any](m map[string]V) map[string]bool {
+	result := make(map[string]bool, len(m))
+	for key := range m {
+		result[key] = true
+	}
+	return result
+}
+
+// diff returns the sorted elements that are only in current and the sorted elements that are only in previous
+func diff(previous, current map[string]bool) (added []string, removed []string) {
+	for id := range current {
+		if !previous[id] {
+			added = append(added, id)
+		}
+	}
+	for id := range previous {
+		if !current[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"fmt"
+	"nuts-foundation/nuts-monitor/test"
+	"testing"
+	"time"
+
+	"github.com/nuts-foundation/go-did/did"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDIDChangeLog(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	transaction := func(ref string, signer string) Transaction {
+		return Transaction{Ref: ref, Signer: signer, ContentType: didDocumentType, SigTime: now}
+	}
+	document := func(controllers []string, methods []string, services map[string]string) did.Document {
+		result := did.Document{ID: did.MustParseDID("did:nuts:a")}
+		for _, controller := range controllers {
+			result.Controller = append(result.Controller, did.MustParseDID(controller))
+		}
+		for _, method := range methods {
+			result.VerificationMethod = append(result.VerificationMethod, &did.VerificationMethod{ID: did.MustParseDIDURL(method)})
+		}
+		for id, endpoint := range services {
+			result.Service = append(result.Service, did.Service{ID: did.MustParseDIDURL(id).URI(), Type: "test", ServiceEndpoint: endpoint})
+		}
+		return result
+	}
+
+	t.Run("first version is created", func(t *testing.T) {
+		log := newDIDChangeLog()
+
+		log.add(transaction("1", "did:nuts:a"), document(nil, []string{"did:nuts:a#1"}, nil))
+
+		assert.Equal(t, []DIDChange{{DID: "did:nuts:a", Ref: "1", Signer: "did:nuts:a", SigTime: now, Created: true}}, log.search("", false, 0))
+	})
+	t.Run("diffs keys and services", func(t *testing.T) {
+		log := newDIDChangeLog()
+
+		log.add(transaction("1", "did:nuts:a"), document(nil, []string{"did:nuts:a#1"}, map[string]string{"did:nuts:a#s1": "https://a", "did:nuts:a#s2": "https://b"}))
+		log.add(transaction("2", "did:nuts:a"), document(nil, []string{"did:nuts:a#2"}, map[string]string{"did:nuts:a#s2": "https://c", "did:nuts:a#s3": "https://d"}))
+
+		changes := log.search("", false, 0)
+		require.Len(t, changes, 2)
+		change := changes[0]
+		assert.False(t, change.Created)
+		assert.False(t, change.Deactivated)
+		assert.Equal(t, []string{"did:nuts:a#2"}, change.VerificationMethodsAdded)
+		assert.Equal(t, []string{"did:nuts:a#1"}, change.VerificationMethodsRemoved)
+		assert.Equal(t, []string{"did:nuts:a#s3"}, change.ServicesAdded)
+		assert.Equal(t, []string{"did:nuts:a#s1"}, change.ServicesRemoved)
+		assert.Equal(t, []string{"did:nuts:a#s2"}, change.ServicesChanged)
+		assert.Empty(t, change.Unexpected)
+	})
+	t.Run("reports controller changes and signers that aren't a controller", func(t *testing.T) {
+		log := newDIDChangeLog()
+
+		log.add(transaction("1", "did:nuts:a"), document([]string{"did:nuts:b"}, []string{"did:nuts:a#1"}, nil))
+		// signed by the controller
+		log.add(transaction("2", "did:nuts:b"), document([]string{"did:nuts:b"}, []string{"did:nuts:a#1", "did:nuts:a#2"}, nil))
+		// signed by the DID itself, which isn't a controller, and the controller is replaced
+		log.add(transaction("3", "did:nuts:a"), document([]string{"did:nuts:c"}, []string{"did:nuts:a#1", "did:nuts:a#2"}, nil))
+
+		changes := log.search("", true, 0)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "3", changes[0].Ref)
+		assert.Equal(t, []string{"did:nuts:c"}, changes[0].ControllersAdded)
+		assert.Equal(t, []string{"did:nuts:b"}, changes[0].ControllersRemoved)
+		assert.Equal(t, []string{UnexpectedSigner, UnexpectedControllers}, changes[0].Unexpected)
+	})
+	t.Run("reports deactivation and updates after it", func(t *testing.T) {
+		log := newDIDChangeLog()
+
+		log.add(transaction("1", "did:nuts:a"), document(nil, []string{"did:nuts:a#1"}, nil))
+		log.add(transaction("2", "did:nuts:a"), document(nil, nil, nil))
+		log.add(transaction("3", "did:nuts:a"), document(nil, []string{"did:nuts:a#2"}, nil))
+
+		changes := log.search("", false, 0)
+		require.Len(t, changes, 3)
+		assert.True(t, changes[1].Deactivated)
+		assert.Empty(t, changes[1].Unexpected)
+		assert.False(t, changes[0].Deactivated)
+		assert.Equal(t, []string{UnexpectedAfterDeactivation}, changes[0].Unexpected)
+	})
+	t.Run("ignores transactions that are processed twice", func(t *testing.T) {
+		log := newDIDChangeLog()
+
+		log.add(transaction("1", "did:nuts:a"), document(nil, []string{"did:nuts:a#1"}, nil))
+		log.add(transaction("1", "did:nuts:a"), document(nil, []string{"did:nuts:a#1"}, nil))
+
+		assert.Len(t, log.search("", false, 0), 1)
+	})
+	t.Run("versions are compared in LC order", func(t *testing.T) {
+		log := newDIDChangeLog()
+		at := func(ref string, lc int) Transaction {
+			transaction := transaction(ref, "did:nuts:a")
+			transaction.LC = lc
+			return transaction
+		}
+
+		// new transactions arrive while the history is loaded
+		log.add(at("3", 30), document(nil, []string{"did:nuts:a#2"}, nil))
+		log.add(at("1", 10), document(nil, []string{"did:nuts:a#1"}, nil))
+		log.add(at("2", 20), document(nil, []string{"did:nuts:a#1", "did:nuts:a#2"}, nil))
+
+		changes := log.search("", false, 0)
+		require.Len(t, changes, 3)
+		assert.Equal(t, []string{"3", "2", "1"}, []string{changes[0].Ref, changes[1].Ref, changes[2].Ref})
+		assert.True(t, changes[2].Created)
+		assert.False(t, changes[1].Created)
+		assert.Equal(t, []string{"did:nuts:a#2"}, changes[1].VerificationMethodsAdded)
+		assert.False(t, changes[0].Created)
+		assert.Empty(t, changes[0].VerificationMethodsAdded)
+		assert.Equal(t, []string{"did:nuts:a#1"}, changes[0].VerificationMethodsRemoved)
+	})
+	t.Run("versions older than the kept versions are ignored", func(t *testing.T) {
+		log := newDIDChangeLog()
+		for i := 1; i <= maxDocumentVersions+1; i++ {
+			transaction := transaction(fmt.Sprintf("%d", i), "did:nuts:a")
+			transaction.LC = i
+			log.add(transaction, document(nil, []string{"did:nuts:a#1"}, nil))
+		}
+		late := transaction("late", "did:nuts:a")
+		late.LC = 1
+
+		log.add(late, document(nil, nil, nil))
+		// processed before, but no longer kept
+		log.add(transaction("1", "did:nuts:a"), document(nil, []string{"did:nuts:a#1"}, nil))
+
+		assert.Len(t, log.search("", false, 0), maxDocumentVersions+1)
+		assert.Len(t, log.versions["did:nuts:a"], maxDocumentVersions)
+	})
+	t.Run("searches on DID", func(t *testing.T) {
+		log := newDIDChangeLog()
+		other := document(nil, []string{"did:nuts:b#1"}, nil)
+		other.ID = did.MustParseDID("did:nuts:b")
+
+		log.add(transaction("1", "did:nuts:a"), document(nil, []string{"did:nuts:a#1"}, nil))
+		log.add(transaction("2", "did:nuts:a"), document(nil, []string{"did:nuts:a#2"}, nil))
+		log.add(transaction("3", "did:nuts:b"), other)
+
+		assert.Len(t, log.search("nuts:a", false, 0), 2)
+		assert.Len(t, log.search("did:nuts:b", false, 0), 1)
+		assert.Len(t, log.search("did:nuts:c", false, 0), 0)
+		changes := log.search("", false, 2)
+		require.Len(t, changes, 2)
+		assert.Equal(t, "3", changes[0].Ref)
+	})
+}
+
+func TestStore_GetDIDChanges(t *testing.T) {
+	simulator := test.NewSimulator(t)
+	store := newTestStore(t, simulator)
+	controller := simulator.CreateDID(t)
+	subject := simulator.CreateDID(t)
+	simulator.AddService(t, subject, "test", "https://example.com")
+	simulator.RotateKey(t, subject)
+	simulator.SetControllers(t, subject, controller)
+	simulator.Deactivate(t, subject)
+	// the payloads are fetched from the node
+	addTransactions(t, store, simulator.Transactions()...)
+
+	changes := store.GetDIDChanges(subject.ID, false, 0)
+	require.Len(t, changes, 5)
+	assert.True(t, changes[4].Created)
+	assert.Len(t, changes[3].ServicesAdded, 1)
+	assert.Len(t, changes[2].VerificationMethodsAdded, 1)
+	assert.Len(t, changes[2].VerificationMethodsRemoved, 1)
+	assert.Equal(t, []string{controller.ID}, changes[1].ControllersAdded)
+	assert.Equal(t, []string{UnexpectedControllers}, changes[1].Unexpected)
+	assert.True(t, changes[0].Deactivated)
+	// the DID isn't a controller of its document anymore
+	assert.Equal(t, []string{UnexpectedSigner, UnexpectedControllers}, changes[0].Unexpected)
+	assert.Equal(t, simulator.Transactions()[5].Ref, changes[0].Ref)
+	assert.Len(t, store.GetDIDChanges("", true, 0), 2)
+}
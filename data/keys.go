@@ -0,0 +1,278 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"nuts-foundation/nuts-monitor/client/vdr"
+)
+
+// didDocumentType is the content type of transactions that create or update a DID document
+const didDocumentType = "application/did+json"
+
+// maxKeyEvents is the number of most recent key events that are kept
+const maxKeyEvents = 100
+
+// maxKeyUses is the number of most recent uses that are kept per key, to place uses that arrive late
+const maxKeyUses = 10
+
+// Types of KeyEvent
+const (
+	// KeyRotated is reported when a signer uses a new key, after it signed with another key
+	KeyRotated = "rotated"
+	// KeyReactivated is reported when a key signs after it hasn't been used for longer than the dormancy period
+	KeyReactivated = "reactivated"
+	// KeyUsedAfterRemoval is reported when a key signs after it was removed from the DID document
+	KeyUsedAfterRemoval = "used_after_removal"
+)
+
+// KeyStats contains the usage of a single signing key
+type KeyStats struct {
+	// KeyID is the ID of the key, the DID of the signer with the key fragment
+	KeyID  string `json:"kid"`
+	Signer string `json:"signer"`
+	Count  uint32 `json:"count"`
+	// FirstSeen is the signature time of the first transaction signed with the key
+	FirstSeen time.Time `json:"first_seen"`
+	// LastSeen is the signature time of the last transaction signed with the key
+	LastSeen time.Time `json:"last_seen"`
+	// Removed is true when the key isn't in the last resolved DID document of the signer
+	Removed bool `json:"removed"`
+	// UsedAfterRemoval is the number of transactions signed with the key after it was removed from the DID document
+	UsedAfterRemoval int `json:"used_after_removal"`
+}
+
+// KeyEvent is a notable use of a key
+type KeyEvent struct {
+	// Type is one of KeyRotated, KeyReactivated or KeyUsedAfterRemoval
+	Type   string `json:"type"`
+	KeyID  string `json:"kid"`
+	Signer string `json:"signer"`
+	LC     int    `json:"lc"`
+	// SigTime is the signature time of the transaction
+	SigTime time.Time `json:"sigt"`
+	// Previous is the key the signer used before, for rotations
+	Previous string `json:"previous,omitempty"`
+	// Inactive is the time in seconds the key wasn't used, for reactivations
+	Inactive float64 `json:"inactive,omitempty"`
+}
+
+// KeyReport contains the statistics per signing key and the most recent key events
+type KeyReport struct {
+	// Dormancy is the configured time in seconds after which a key that signs again is reported as reactivated
+	Dormancy float64 `json:"dormancy"`
+	// Keys is sorted on signer and the time the key was first seen
+	Keys []KeyStats `json:"keys"`
+	// Events contains the most recent events, the latest first
+	Events []KeyEvent `json:"events"`
+}
+
+// documentKeys contains the keys of a resolved DID document
+type documentKeys struct {
+	keys map[string]bool
+	// missing contains the keys that were used but aren't in the document, the document isn't resolved again for them
+	missing map[string]bool
+	// updated is the time the document was last changed, transactions signed after it must use one of the keys
+	updated time.Time
+}
+
+// keyUse is a use of a key by its signer
+type keyUse struct {
+	keyID   string
+	lc      int
+	sigTime time.Time
+}
+
+// keyTracker keeps the statistics per signing key
+type keyTracker struct {
+	dormancy time.Duration
+	keys     map[string]*KeyStats
+	// signerKeys contains the keys per signer, ordered by the LC of their first use
+	signerKeys map[string][]keyUse
+	// uses contains the most recent uses per key, ordered by LC
+	uses      map[string][]keyUse
+	documents map[string]documentKeys
+	// events is ordered by LC
+	events []KeyEvent
+}
+
+func newKeyTracker(dormancy time.Duration) *keyTracker {
+	return &keyTracker{
+		dormancy:   dormancy,
+		keys:       map[string]*KeyStats{},
+		signerKeys: map[string][]keyUse{},
+		uses:       map[string][]keyUse{},
+		documents:  map[string]documentKeys{},
+	}
+}
+
+// add counts the transaction for its key and reports rotations and reactivations
+func (k *keyTracker) add(transaction Transaction) {
+	use := keyUse{keyID: transaction.KeyID, lc: transaction.LC, sigTime: transaction.SigTime}
+	stats, ok := k.keys[transaction.KeyID]
+	if !ok {
+		stats = &KeyStats{KeyID: transaction.KeyID, Signer: transaction.Signer, FirstSeen: transaction.SigTime, LastSeen: transaction.SigTime}
+		k.keys[transaction.KeyID] = stats
+		k.rotate(use, transaction.Signer)
+	}
+	k.use(use, transaction.Signer)
+	stats.Count++
+	if transaction.SigTime.Before(stats.FirstSeen) {
+		stats.FirstSeen = transaction.SigTime
+	}
+	if transaction.SigTime.After(stats.LastSeen) {
+		stats.LastSeen = transaction.SigTime
+	}
+}
+
+// rotate reports the first use of a key as a rotation from the key the signer used before it.
+// The history is loaded while new transactions arrive, so a key may be seen after a later key: the rotation to the later key is then reported from this key.
+func (k *keyTracker) rotate(use keyUse, signer string) {
+	uses := k.signerKeys[signer]
+	i := sort.Search(len(uses), func(i int) bool { return uses[i].lc > use.lc })
+	uses = slices.Insert(uses, i, use)
+	k.signerKeys[signer] = uses
+
+	if i > 0 {
+		k.event(KeyEvent{Type: KeyRotated, KeyID: use.keyID, Signer: signer, LC: use.lc, SigTime: use.sigTime, Previous: uses[i-1].keyID})
+	}
+	if i+1 == len(uses) {
+		return
+	}
+	next := uses[i+1]
+	for j := range k.events {
+		if k.events[j].Type == KeyRotated && k.events[j].KeyID == next.keyID {
+			k.events[j].Previous = use.keyID
+			return
+		}
+	}
+	if i == 0 {
+		// the next key was the first key of the signer
+		k.event(KeyEvent{Type: KeyRotated, KeyID: next.keyID, Signer: signer, LC: next.lc, SigTime: next.sigTime, Previous: use.keyID})
+	}
+}
+
+// use reports the use of a key as a reactivation when the key wasn't used for longer than the dormancy before it.
+// Uses are compared in LC order, like the rotations. A use that is older than the kept uses of the key isn't compared.
+func (k *keyTracker) use(use keyUse, signer string) {
+	uses := k.uses[use.keyID]
+	i := sort.Search(len(uses), func(i int) bool { return uses[i].lc > use.lc })
+	if i == 0 && len(uses) == maxKeyUses {
+		return
+	}
+	uses = slices.Insert(uses, i, use)
+
+	if i > 0 {
+		k.reactivate(uses[i-1], use, signer)
+	}
+	if i+1 < len(uses) {
+		// the next use was compared with an older use
+		next := uses[i+1]
+		k.events = slices.DeleteFunc(k.events, func(event KeyEvent) bool {
+			return event.Type == KeyReactivated && event.KeyID == next.keyID && event.LC == next.lc
+		})
+		k.reactivate(use, next, signer)
+	}
+	if len(uses) > maxKeyUses {
+		uses = uses[1:]
+	}
+	k.uses[use.keyID] = uses
+}
+
+// reactivate reports the use if the key wasn't used for longer than the dormancy since the previous use
+func (k *keyTracker) reactivate(previous keyUse, use keyUse, signer string) {
+	if inactive := use.sigTime.Sub(previous.sigTime); k.dormancy > 0 && inactive > k.dormancy {
+		k.event(KeyEvent{Type: KeyReactivated, KeyID: use.keyID, Signer: signer, LC: use.lc, SigTime: use.sigTime, Inactive: inactive.Seconds()})
+	}
+}
+
+// needsDocument returns true if the DID document of the signer must be resolved to check the key of the transaction:
+// the document is unknown, it doesn't contain the key (it may have been added) or the transaction may have changed it
+func (k *keyTracker) needsDocument(transaction Transaction) bool {
+	document, ok := k.documents[transaction.Signer]
+	return !ok || (!document.keys[transaction.KeyID] && !document.missing[transaction.KeyID]) || transaction.ContentType == didDocumentType
+}
+
+// setDocument stores the keys of a resolved DID document
+func (k *keyTracker) setDocument(did string, result vdr.DIDResolutionResult) {
+	document := documentKeys{keys: map[string]bool{}, missing: map[string]bool{}}
+	for _, method := range result.Document.VerificationMethod {
+		document.keys[method.ID.String()] = true
+	}
+	updated := result.DocumentMetadata.Created
+	if result.DocumentMetadata.Updated != nil {
+		updated = *result.DocumentMetadata.Updated
+	}
+	// the time is only used to prevent false reports, so an invalid time is ignored
+	document.updated, _ = time.Parse(time.RFC3339, updated)
+	k.documents[did] = document
+}
+
+// checkRemoval reports the transaction if it's signed after its key was removed from the DID document of the signer.
+// Transactions signed before the last change of the document aren't reported, the key may have been removed later.
+func (k *keyTracker) checkRemoval(transaction Transaction) {
+	document, ok := k.documents[transaction.Signer]
+	if !ok || document.keys[transaction.KeyID] {
+		return
+	}
+	document.missing[transaction.KeyID] = true
+	if !transaction.SigTime.After(document.updated) {
+		return
+	}
+	k.keys[transaction.KeyID].UsedAfterRemoval++
+	k.event(KeyEvent{Type: KeyUsedAfterRemoval, KeyID: transaction.KeyID, Signer: transaction.Signer, LC: transaction.LC, SigTime: transaction.SigTime})
+}
+
+// event adds the event in LC order, only the most recent events are kept
+func (k *keyTracker) event(event KeyEvent) {
+	i := sort.Search(len(k.events), func(i int) bool { return k.events[i].LC > event.LC })
+	k.events = slices.Insert(k.events, i, event)
+	if len(k.events) > maxKeyEvents {
+		k.events = k.events[len(k.events)-maxKeyEvents:]
+	}
+}
+
+func (k *keyTracker) report() KeyReport {
+	report := KeyReport{
+		Dormancy: k.dormancy.Seconds(),
+		Keys:     make([]KeyStats, 0, len(k.keys)),
+		Events:   make([]KeyEvent, len(k.events)),
+	}
+	for _, stats := range k.keys {
+		key := *stats
+		if document, ok := k.documents[key.Signer]; ok {
+			key.Removed = !document.keys[key.KeyID]
+		}
+		report.Keys = append(report.Keys, key)
+	}
+	sort.Slice(report.Keys, func(i, j int) bool {
+		a, b := report.Keys[i], report.Keys[j]
+		if a.Signer != b.Signer {
+			return a.Signer < b.Signer
+		}
+		return a.FirstSeen.Before(b.FirstSeen)
+	})
+	for i, event := range k.events {
+		report.Events[len(k.events)-1-i] = event
+	}
+	return report
+}
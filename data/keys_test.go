@@ -0,0 +1,173 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"nuts-foundation/nuts-monitor/client/common"
+	"nuts-foundation/nuts-monitor/client/vdr"
+	"nuts-foundation/nuts-monitor/test"
+	"testing"
+	"time"
+
+	"github.com/nuts-foundation/go-did/did"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyTracker(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	transaction := func(keyID string, sigTime time.Time) Transaction {
+		return Transaction{Signer: "did:nuts:a", KeyID: keyID, ContentType: "application/vc+json", SigTime: sigTime}
+	}
+	document := func(updated time.Time, keyIDs ...string) vdr.DIDResolutionResult {
+		result := vdr.DIDResolutionResult{DocumentMetadata: common.DIDDocumentMetadata{Created: updated.Format(time.RFC3339)}}
+		for _, keyID := range keyIDs {
+			result.Document.VerificationMethod = append(result.Document.VerificationMethod, &did.VerificationMethod{ID: did.MustParseDIDURL(keyID)})
+		}
+		return result
+	}
+
+	t.Run("counts per key", func(t *testing.T) {
+		tracker := newKeyTracker(0)
+
+		tracker.add(transaction("did:nuts:a#1", now.Add(-time.Hour)))
+		tracker.add(transaction("did:nuts:a#1", now))
+		tracker.add(transaction("did:nuts:a#1", now.Add(-2*time.Hour)))
+
+		report := tracker.report()
+		assert.Equal(t, []KeyStats{{KeyID: "did:nuts:a#1", Signer: "did:nuts:a", Count: 3, FirstSeen: now.Add(-2 * time.Hour), LastSeen: now}}, report.Keys)
+		assert.Empty(t, report.Events)
+	})
+	t.Run("reports rotations", func(t *testing.T) {
+		tracker := newKeyTracker(0)
+
+		tracker.add(transaction("did:nuts:a#1", now.Add(-time.Hour)))
+		tracker.add(transaction("did:nuts:a#2", now))
+		tracker.add(transaction("did:nuts:a#2", now))
+
+		report := tracker.report()
+		require.Len(t, report.Keys, 2)
+		assert.Equal(t, "did:nuts:a#1", report.Keys[0].KeyID)
+		assert.Equal(t, []KeyEvent{{Type: KeyRotated, KeyID: "did:nuts:a#2", Signer: "did:nuts:a", SigTime: now, Previous: "did:nuts:a#1"}}, report.Events)
+	})
+	t.Run("rotations are ordered by LC", func(t *testing.T) {
+		tracker := newKeyTracker(0)
+		at := func(keyID string, lc int) Transaction {
+			transaction := transaction(keyID, now)
+			transaction.LC = lc
+			return transaction
+		}
+
+		// new transactions arrive while the history is loaded
+		tracker.add(at("did:nuts:a#3", 30))
+		tracker.add(at("did:nuts:a#1", 10))
+		tracker.add(at("did:nuts:a#2", 20))
+
+		assert.Equal(t, []KeyEvent{
+			{Type: KeyRotated, KeyID: "did:nuts:a#3", Signer: "did:nuts:a", LC: 30, SigTime: now, Previous: "did:nuts:a#2"},
+			{Type: KeyRotated, KeyID: "did:nuts:a#2", Signer: "did:nuts:a", LC: 20, SigTime: now, Previous: "did:nuts:a#1"},
+		}, tracker.report().Events)
+	})
+	t.Run("reports reactivations", func(t *testing.T) {
+		tracker := newKeyTracker(24 * time.Hour)
+
+		tracker.add(transaction("did:nuts:a#1", now.Add(-72*time.Hour)))
+		tracker.add(transaction("did:nuts:a#1", now.Add(-50*time.Hour)))
+		tracker.add(transaction("did:nuts:a#1", now))
+
+		report := tracker.report()
+		assert.Equal(t, []KeyEvent{{Type: KeyReactivated, KeyID: "did:nuts:a#1", Signer: "did:nuts:a", SigTime: now, Inactive: 50 * 3600}}, report.Events)
+	})
+	t.Run("reactivations are checked in LC order", func(t *testing.T) {
+		tracker := newKeyTracker(24 * time.Hour)
+		at := func(lc int, sigTime time.Time) Transaction {
+			transaction := transaction("did:nuts:a#1", sigTime)
+			transaction.LC = lc
+			return transaction
+		}
+
+		tracker.add(at(1, now.Add(-60*time.Hour)))
+		// a new transaction arrives while the history is loaded
+		tracker.add(at(4, now))
+		tracker.add(at(2, now.Add(-40*time.Hour)))
+		tracker.add(at(3, now.Add(-20*time.Hour)))
+
+		assert.Empty(t, tracker.report().Events)
+	})
+	t.Run("reports keys used after removal", func(t *testing.T) {
+		tracker := newKeyTracker(0)
+		tracker.setDocument("did:nuts:a", document(now, "did:nuts:a#2"))
+
+		// signed before the document was changed, the key may have been removed later
+		before := transaction("did:nuts:a#1", now.Add(-time.Hour))
+		tracker.add(before)
+		tracker.checkRemoval(before)
+		after := transaction("did:nuts:a#1", now.Add(time.Minute))
+		tracker.add(after)
+		tracker.checkRemoval(after)
+		current := transaction("did:nuts:a#2", now.Add(time.Minute))
+		tracker.add(current)
+		tracker.checkRemoval(current)
+
+		report := tracker.report()
+		require.Len(t, report.Keys, 2)
+		assert.True(t, report.Keys[0].Removed)
+		assert.Equal(t, 1, report.Keys[0].UsedAfterRemoval)
+		assert.False(t, report.Keys[1].Removed)
+		require.Len(t, report.Events, 2)
+		assert.Equal(t, KeyRotated, report.Events[0].Type)
+		assert.Equal(t, KeyEvent{Type: KeyUsedAfterRemoval, KeyID: "did:nuts:a#1", Signer: "did:nuts:a", SigTime: now.Add(time.Minute)}, report.Events[1])
+		// the document isn't resolved again for the removed key, until the document changes
+		assert.False(t, tracker.needsDocument(after))
+		assert.True(t, tracker.needsDocument(Transaction{Signer: "did:nuts:a", KeyID: "did:nuts:a#1", ContentType: didDocumentType}))
+	})
+}
+
+func TestStore_GetKeys(t *testing.T) {
+	simulator := test.NewSimulator(t)
+	store := newTestStore(t, simulator)
+	add := func(transactions ...test.SimulatedTransaction) {
+		addTransactions(t, store, transactions...)
+	}
+	signer := simulator.CreateDID(t)
+	previousKey := signer.KeyID
+	add(simulator.Transactions()...)
+	add(simulator.AddTransaction(t, signer, "application/vc+json", time.Now().Add(-time.Minute)))
+
+	// the update of the DID document is signed with the old key, the store resolves the document again
+	previous := simulator.RotateKey(t, signer)
+	add(simulator.Transactions()[2])
+	add(simulator.AddTransaction(t, signer, "application/vc+json", time.Now().Add(time.Minute)))
+	add(simulator.AddTransaction(t, previous, "application/vc+json", time.Now().Add(time.Hour)))
+
+	report := store.GetKeys()
+	require.Len(t, report.Keys, 2)
+	assert.Equal(t, previousKey, report.Keys[0].KeyID)
+	assert.Equal(t, uint32(4), report.Keys[0].Count)
+	assert.True(t, report.Keys[0].Removed)
+	assert.Equal(t, 1, report.Keys[0].UsedAfterRemoval)
+	assert.Equal(t, signer.KeyID, report.Keys[1].KeyID)
+	assert.Equal(t, uint32(1), report.Keys[1].Count)
+	assert.False(t, report.Keys[1].Removed)
+	require.Len(t, report.Events, 2)
+	assert.Equal(t, KeyUsedAfterRemoval, report.Events[0].Type)
+	assert.Equal(t, previousKey, report.Events[0].KeyID)
+	assert.Equal(t, KeyRotated, report.Events[1].Type)
+	assert.Equal(t, previousKey, report.Events[1].Previous)
+}
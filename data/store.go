@@ -20,22 +20,37 @@ package data
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"nuts-foundation/nuts-monitor/client"
+	"nuts-foundation/nuts-monitor/client/vdr"
+	"nuts-foundation/nuts-monitor/config"
+	"sync"
 	"time"
+
+	"github.com/nuts-foundation/go-did/did"
 )
 
 // Store is an in-memory store that contains a mapping from transaction signer to its controller.
 // It also contains three sliding windows with length and resolution of: (1 hour, 1 minute), (1 day, 1 hour), (30 days, 1 day).
 // A transaction can be added, the store will resolve the signer and the controller of the signer.
+// It's safe for concurrent use: transactions are added by the NATS consumer and history loader while the API reads them.
+// The mutex guards the mapping, the counts and the trackers, which aren't safe for concurrent use themselves.
+// The sliding windows and the clock skew detector have their own mutex, since reading a window moves it.
 type Store struct {
 	client         client.HTTPClient
+	mutex          sync.RWMutex
 	mapping        map[string]string
 	slidingWindows []*slidingWindow
 	didCount       map[string]uint32
 	// rootDIDCount is the number of unique root DIDs, we can't use the length of the mapping because
 	// the mapping may contain multiple levels of mapping before getting to the root DID
 	rootDIDCount uint32
+	clockSkew    *clockSkewDetector
+	keys         *keyTracker
+	didChanges   *didChangeLog
+	credentials  *credentialTracker
+	anomalies    *anomalyDetector
 }
 
 func NewStore(client client.HTTPClient) *Store {
@@ -43,6 +58,12 @@ func NewStore(client client.HTTPClient) *Store {
 		client:   client,
 		mapping:  make(map[string]string),
 		didCount: make(map[string]uint32),
+		// the checks are disabled until the thresholds are configured
+		clockSkew:   newClockSkewDetector(0, 0),
+		keys:        newKeyTracker(0),
+		didChanges:  newDIDChangeLog(),
+		credentials: newCredentialTracker(0, 0),
+		anomalies:   newAnomalyDetector(0, 0, time.Now()),
 	}
 
 	// initialize all windows with empty dataPoints using the init function
@@ -53,26 +74,129 @@ func NewStore(client client.HTTPClient) *Store {
 	return s
 }
 
-// Start the sliding windows
+// Start the sliding windows and the anomaly detection, which checks every minute whether an hour has passed
 func (s *Store) Start(ctx context.Context) {
 	for i := range s.slidingWindows {
 		s.slidingWindows[i].Start(ctx)
 	}
+
+	done := ctx.Done()
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				s.mutex.Lock()
+				s.anomalies.detect(now)
+				s.mutex.Unlock()
+			}
+		}
+	}()
+}
+
+// Reconfigure changes the clock drift the sliding windows correct for, the thresholds for reporting clock skew,
+// the time after which a key that signs again is reported as reactivated, the number of revocations within a window that is reported as a mass revocation
+// and the thresholds for anomalies. Transactions that have been added aren't moved to other data points.
+func (s *Store) Reconfigure(c config.Config) {
+	windows := []*slidingWindow{s.anomalies.activity}
+	for _, window := range append(windows, s.slidingWindows...) {
+		window.mutex.Lock()
+		window.clockdrift = c.ClockDrift
+		window.mutex.Unlock()
+	}
+	s.clockSkew.reconfigure(c.ClockSkewMaxFuture, c.ClockSkewMaxPast)
+
+	s.mutex.Lock()
+	s.keys.dormancy = c.KeyDormancy
+	s.credentials.threshold = c.MassRevocationThreshold
+	s.credentials.window = c.MassRevocationWindow
+	s.anomalies.threshold = c.AnomalyThreshold
+	s.anomalies.minCount = c.AnomalyMinCount
+	s.mutex.Unlock()
 }
 
 // Add a transaction to the sliding windows and resolve the controller of the signer
 func (s *Store) Add(transaction Transaction) {
+	s.clockSkew.check(transaction, time.Now())
+	lookups := s.lookup(transaction)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	// first add the transaction to the sliding windows
 	for i := range s.slidingWindows {
 		s.slidingWindows[i].AddCount(transaction.ContentType, transaction.SigTime)
 	}
 
-	controller, newRoot := s.resolveController(transaction.Signer)
+	// a signer that is resolved for the first time has a fresh DID document
+	_, known := s.mapping[transaction.Signer]
+	controller, newRoot := s.resolveController(transaction.Signer, lookups.documents)
 	if newRoot {
 		// a new root so add it to the count
 		s.rootDIDCount++
 	}
 	s.didCount[controller]++
+	s.anomalies.add(controller, transaction)
+
+	if transaction.KeyID != "" {
+		s.keys.add(transaction)
+		if document := lookups.documents[transaction.Signer]; known && document != nil && s.keys.needsDocument(transaction) {
+			s.keys.setDocument(transaction.Signer, *document)
+		}
+		s.keys.checkRemoval(transaction)
+	}
+
+	switch transaction.ContentType {
+	case didDocumentType:
+		s.addDocument(transaction, lookups.payload, lookups.payloadErr)
+	case credentialType, revocationType:
+		s.addCredential(transaction, lookups.payload, lookups.payloadErr)
+	}
+}
+
+// lookups contains the data a transaction needs from the node
+type lookups struct {
+	// documents contains the DID documents by DID, nil if the DID couldn't be resolved
+	documents  map[string]*vdr.DIDResolutionResult
+	payload    []byte
+	payloadErr error
+}
+
+// lookup resolves the DID documents of the signer and its controllers that aren't resolved yet, and the payload of the transaction.
+// It doesn't hold the lock while calling the node, so the API isn't blocked by the requests.
+func (s *Store) lookup(transaction Transaction) lookups {
+	result := lookups{documents: map[string]*vdr.DIDResolutionResult{}}
+	s.mutex.RLock()
+	_, known := s.mapping[transaction.Signer]
+	// the document of a known signer is resolved again to check the keys it signs with
+	refresh := known && transaction.KeyID != "" && s.keys.needsDocument(transaction)
+	s.mutex.RUnlock()
+	if refresh {
+		result.documents[transaction.Signer] = s.resolve(transaction.Signer)
+	}
+
+	// resolve the controller chain up to the first DID that is resolved already
+	for id := transaction.Signer; id != ""; {
+		s.mutex.RLock()
+		_, resolved := s.mapping[id]
+		s.mutex.RUnlock()
+		if _, fetched := result.documents[id]; resolved || fetched {
+			break
+		}
+		document := s.resolve(id)
+		result.documents[id] = document
+		id = controllerOf(id, document)
+	}
+
+	switch transaction.ContentType {
+	case didDocumentType, credentialType, revocationType:
+		result.payload, result.payloadErr = s.payload(transaction)
+	}
+	return result
 }
 
 // GetTransactions returns the transactions of the sliding windows
@@ -80,48 +204,147 @@ func (s *Store) Add(transaction Transaction) {
 func (s *Store) GetTransactions() [3]map[string][]DataPoint {
 	var transactions [3]map[string][]DataPoint
 
+	now := time.Now()
 	for i, window := range s.slidingWindows {
-		transactions[i] = map[string][]DataPoint{}
-		for cty, a := range window.dataPoints {
-			transactions[i][cty] = a
-		}
+		window.mutex.Lock()
+		transactions[i] = window.consolidate(now)
+		window.mutex.Unlock()
 	}
 
 	return transactions
 }
 
+// GetClockSkew returns the signers and transactions with a signature time far from the arrival time
+func (s *Store) GetClockSkew() ClockSkewReport {
+	return s.clockSkew.report()
+}
+
+// GetKeys returns the statistics per signing key and the most recent rotations, reactivations and uses of removed keys
+func (s *Store) GetKeys() KeyReport {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.keys.report()
+}
+
+// GetDIDChanges returns the changes of the DID documents with a DID that contains the query, the latest first.
+// Only changes that are a security signal are returned if unexpected is true, at most limit changes are returned if limit is positive.
+func (s *Store) GetDIDChanges(query string, unexpected bool, limit int) []DIDChange {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.didChanges.search(query, unexpected, limit)
+}
+
+// GetCredentials returns the issuance and revocation activity per issuer and credential type and the most recent mass revocations
+func (s *Store) GetCredentials() CredentialReport {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.credentials.report()
+}
+
+// GetAnomalies returns the most recent spikes and silences in the hourly transaction counts per content type and root DID
+func (s *Store) GetAnomalies() AnomalyReport {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.anomalies.report()
+}
+
 // GetTransactionCounts returns the transaction count per root DID and the total number of roots
 func (s *Store) GetTransactionCounts() (map[string]uint32, uint32) {
-	return s.didCount, s.rootDIDCount
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	counts := make(map[string]uint32, len(s.didCount))
+	for did, count := range s.didCount {
+		counts[did] = count
+	}
+	return counts, s.rootDIDCount
 }
 
-func (s *Store) resolveController(txDID string) (string, bool) {
+// resolveController returns the root of the controller chain of the DID, using the documents that were looked up.
+// Every document is used once, so a chain with a cycle ends at the DID that is seen again.
+func (s *Store) resolveController(txDID string, documents map[string]*vdr.DIDResolutionResult) (string, bool) {
 	// check if the did is already resolved
 	if controller, ok := s.mapping[txDID]; ok {
 		return controller, false
 	}
 
-	// resolve the did
-	result, err := s.client.DIDDocument(context.Background(), txDID)
-	if err != nil {
+	result := documents[txDID]
+	delete(documents, txDID)
+	if result == nil {
 		// resolving failed, just return the original did
-		log.Printf("error resolving did: %s\n", err.Error())
 		return txDID, true
 	}
 
+	s.keys.setDocument(txDID, *result)
 	root := txDID
 	newRoot := true
 
 	// check if the DID document contains a controller that differs from the did
-	for _, controller := range result.Document.Controller {
-		if controller.String() != txDID {
-			// call resolveController recursively to resolve the controller of the controller
-			root, newRoot = s.resolveController(controller.String())
-			break
-		}
+	if controller := controllerOf(txDID, result); controller != "" {
+		// call resolveController recursively to resolve the controller of the controller
+		root, newRoot = s.resolveController(controller, documents)
 	}
 	// add the mapping to the store
 	s.mapping[txDID] = root
 
 	return root, newRoot
 }
+
+// controllerOf returns the first controller of the DID document that differs from the DID, or an empty string if there's none
+func controllerOf(txDID string, result *vdr.DIDResolutionResult) string {
+	if result == nil {
+		return ""
+	}
+	for _, controller := range result.Document.Controller {
+		if controller.String() != txDID {
+			return controller.String()
+		}
+	}
+	return ""
+}
+
+// resolve returns the DID document of the DID, or nil if it can't be resolved
+func (s *Store) resolve(txDID string) *vdr.DIDResolutionResult {
+	ctx := client.WithPriority(context.Background(), client.PriorityBackground)
+	result, err := s.client.DIDDocument(ctx, txDID)
+	if err != nil {
+		log.Printf("error resolving did: %s\n", err.Error())
+		return nil
+	}
+	return result
+}
+
+// addDocument records the change of the DID document in the transaction
+func (s *Store) addDocument(transaction Transaction, payload []byte, err error) {
+	if err != nil {
+		log.Printf("error fetching DID document: %s\n", err.Error())
+		return
+	}
+	document := did.Document{}
+	if err := json.Unmarshal(payload, &document); err != nil {
+		log.Printf("error parsing DID document: %s\n", err.Error())
+		return
+	}
+	s.didChanges.add(transaction, document)
+}
+
+// addCredential classifies the credential or revocation in the transaction, it's counted with an unknown type if the payload can't be fetched
+func (s *Store) addCredential(transaction Transaction, payload []byte, err error) {
+	if err != nil {
+		log.Printf("error fetching credential: %s\n", err.Error())
+	}
+	s.credentials.add(transaction, payload)
+}
+
+// payload returns the payload of the transaction, it's fetched from the node if the transaction doesn't contain it
+func (s *Store) payload(transaction Transaction) ([]byte, error) {
+	if len(transaction.Payload) > 0 {
+		return transaction.Payload, nil
+	}
+	ctx := client.WithPriority(context.Background(), client.PriorityBackground)
+	return s.client.TransactionPayload(ctx, transaction.Ref)
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package data
+
+import (
+	"fmt"
+	"nuts-foundation/nuts-monitor/client"
+	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/test"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore returns a store that resolves DIDs with the simulator
+func newTestStore(t *testing.T, simulator *test.Simulator) *Store {
+	httpClient, err := client.NewHTTPClient(config.Config{NutsNodeAddr: simulator.URL()})
+	require.NoError(t, err)
+	return NewStore(httpClient)
+}
+
+// addTransactions adds the simulated transactions to the store without their payloads, like the history loader
+func addTransactions(t *testing.T, store *Store, transactions ...test.SimulatedTransaction) {
+	for _, tx := range transactions {
+		transaction, err := FromJWS(tx.JWS)
+		require.NoError(t, err)
+		store.Add(*transaction)
+	}
+}
+
+func TestStore_Add(t *testing.T) {
+	simulator := test.NewSimulator(t)
+
+	t.Run("transactions are counted for the root of the controller chain", func(t *testing.T) {
+		store := newTestStore(t, simulator)
+		vendor := simulator.CreateDID(t)
+		organization := simulator.CreateDID(t, vendor)
+		department := simulator.CreateDID(t, organization)
+		other := simulator.CreateDID(t)
+
+		addTransactions(t, store, simulator.Transactions()...)
+		addTransactions(t, store,
+			simulator.AddTransaction(t, department, "application/vc+json", time.Now()),
+			simulator.AddTransaction(t, other, "application/vc+json", time.Now()),
+		)
+
+		counts, roots := store.GetTransactionCounts()
+		assert.Equal(t, uint32(2), roots)
+		assert.Equal(t, map[string]uint32{vendor.ID: 4, other.ID: 2}, counts)
+	})
+	t.Run("transactions are counted per content type", func(t *testing.T) {
+		store := newTestStore(t, simulator)
+		signer := simulator.CreateDID(t)
+
+		addTransactions(t, store,
+			simulator.AddTransaction(t, signer, "application/vc+json", time.Now()),
+			simulator.AddTransaction(t, signer, "application/vc+json", time.Now()),
+			simulator.AddTransaction(t, signer, "application/ld+json;type=revocation", time.Now()),
+		)
+
+		perHour := store.GetTransactions()[0]
+		total := func(contentType string) (result uint32) {
+			for _, dataPoint := range perHour[contentType] {
+				result += dataPoint.Count
+			}
+			return
+		}
+		assert.Equal(t, uint32(2), total("application/vc+json"))
+		assert.Equal(t, uint32(1), total("application/ld+json;type=revocation"))
+	})
+	t.Run("signer is its own root when its DID document can't be resolved", func(t *testing.T) {
+		store := newTestStore(t, simulator)
+		signer := simulator.CreateDID(t)
+		simulator.Fail("/internal/vdr/v1/did/", test.ServerError(503))
+		defer simulator.Reset()
+
+		addTransactions(t, store, simulator.AddTransaction(t, signer, "application/vc+json", time.Now()))
+
+		counts, roots := store.GetTransactionCounts()
+		assert.Equal(t, uint32(1), roots)
+		assert.Equal(t, map[string]uint32{signer.ID: 1}, counts)
+	})
+	t.Run("a controller cycle ends at the DID that is seen again", func(t *testing.T) {
+		store := newTestStore(t, simulator)
+		a := simulator.CreateDID(t)
+		b := simulator.CreateDID(t, a)
+		simulator.SetControllers(t, a, b)
+
+		addTransactions(t, store, simulator.AddTransaction(t, a, "application/vc+json", time.Now()))
+
+		counts, roots := store.GetTransactionCounts()
+		assert.Equal(t, uint32(1), roots)
+		assert.Equal(t, map[string]uint32{a.ID: 1}, counts)
+	})
+	t.Run("reads don't wait for requests to the node", func(t *testing.T) {
+		store := newTestStore(t, simulator)
+		signer := simulator.CreateDID(t)
+		tx := simulator.AddTransaction(t, signer, "application/vc+json", time.Now())
+		simulator.SetLatency("/internal/vdr/v1/did/", time.Second)
+		defer simulator.Reset()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			addTransactions(t, store, tx)
+		}()
+		time.Sleep(100 * time.Millisecond)
+		start := time.Now()
+		store.GetTransactionCounts()
+		store.GetKeys()
+
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+		<-done
+	})
+}
+
+func TestStore_Reconfigure(t *testing.T) {
+	simulator := test.NewSimulator(t)
+	store := newTestStore(t, simulator)
+	signer := simulator.CreateDID(t)
+	now := time.Now()
+
+	store.Reconfigure(config.Config{ClockDrift: time.Minute, ClockSkewMaxFuture: 2 * time.Minute, ClockSkewMaxPast: time.Hour})
+	// with a clock drift of a minute, a transaction signed 30 seconds in the future is counted but not reported
+	for _, sigTime := range []time.Time{now.Add(30 * time.Second), now.Add(3 * time.Minute)} {
+		addTransactions(t, store, simulator.AddTransaction(t, signer, "application/vc+json", sigTime))
+	}
+
+	perHour := store.GetTransactions()[0]
+	var count uint32
+	for _, dataPoint := range perHour["application/vc+json"] {
+		count += dataPoint.Count
+	}
+	assert.Equal(t, uint32(1), count)
+	report := store.GetClockSkew()
+	assert.Equal(t, 1, report.Future)
+	require.Len(t, report.Signers, 1)
+	assert.Equal(t, signer.ID, report.Signers[0].Signer)
+}
+
+// BenchmarkStore_replay measures loading the history of a large DAG, spread over the 30 days of the largest window
+func BenchmarkStore_replay(b *testing.B) {
+	const signers = 1000
+	contentTypes := []string{"application/did+json", "application/vc+json", "application/ld+json;type=revocation"}
+	now := time.Now()
+	transactions := make([]Transaction, 100000)
+	for i := range transactions {
+		signer := fmt.Sprintf("did:nuts:%d", i%signers)
+		transactions[i] = Transaction{
+			ContentType: contentTypes[i%len(contentTypes)],
+			Signer:      signer,
+			SigTime:     now.Add(-30 * 24 * time.Hour).Add(time.Duration(i) * 30 * 24 * time.Hour / time.Duration(len(transactions))),
+			LC:          i,
+			Ref:         strconv.Itoa(i),
+		}
+		// the payloads are included, like in transactions from the NATS stream
+		switch transactions[i].ContentType {
+		case didDocumentType:
+			transactions[i].Payload = []byte(fmt.Sprintf(`{"id":"%s"}`, signer))
+		case credentialType:
+			transactions[i].Payload = []byte(fmt.Sprintf(`{"id":"%s#%d","issuer":"%s","type":["VerifiableCredential","NutsOrganizationCredential"]}`, signer, i, signer))
+		default:
+			transactions[i].Payload = []byte(fmt.Sprintf(`{"issuer":"%s","subject":"%s#%d"}`, signer, signer, i-1))
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store := NewStore(client.HTTPClient{})
+		// the signers are resolved up front, the benchmark doesn't measure the Nuts node
+		for j := 0; j < signers; j++ {
+			store.mapping[fmt.Sprintf("did:nuts:%d", j)] = fmt.Sprintf("did:nuts:%d", j)
+		}
+		for _, transaction := range transactions {
+			store.Add(transaction)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(transactions)), "ns/tx")
+}
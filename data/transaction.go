@@ -19,6 +19,8 @@
 package data
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"github.com/lestrrat-go/jwx/jws"
 	"strings"
@@ -38,8 +40,18 @@ type Transaction struct {
 	ContentType string
 	// Signer is extracted from the key used to sign the transaction
 	Signer string
+	// KeyID is the ID of the key used to sign the transaction, the DID of the signer with the key fragment
+	KeyID string
 	// SigTime is the signature time in seconds since the Unix epoch
 	SigTime time.Time
+	// LC is the Lamport Clock value of the transaction in the DAG
+	LC int
+	// Received is the time the transaction arrived through the NATS stream, it's zero for transactions loaded from the history
+	Received time.Time
+	// Ref is the reference of the transaction, the hex encoded SHA-256 hash of the JWS
+	Ref string
+	// Payload is the detached payload of the transaction, it's only set for transactions that arrived through the NATS stream
+	Payload []byte
 }
 
 func FromJWS(transaction string) (*Transaction, error) {
@@ -59,9 +71,21 @@ func FromJWS(transaction string) (*Transaction, error) {
 	// parse the sigt string value to time field
 	sigTime := time.Unix(int64(sigt.(float64)), 0)
 
+	// the reference is the hash of the JWS, it's used to fetch the payload from the node
+	sum := sha256.Sum256([]byte(transaction))
+	ref := hex.EncodeToString(sum[:])
+
 	// then extract the Content-Type from the "cty" field
 	contentType := jwsToken.Signatures()[0].ProtectedHeaders().ContentType()
 
+	// the "lc" field contains the Lamport Clock value, it's optional for parsing
+	lc := 0
+	if lcValue, ok := jwsToken.Signatures()[0].ProtectedHeaders().Get("lc"); ok {
+		if f, ok := lcValue.(float64); ok {
+			lc = int(f)
+		}
+	}
+
 	// the signer can either be extracted from the "kid" header or from the embedded key
 	// we first try to extract it from the "kid" header
 	signer, ok := jwsToken.Signatures()[0].ProtectedHeaders().Get("kid")
@@ -74,7 +98,7 @@ func FromJWS(transaction string) (*Transaction, error) {
 		if index == -1 {
 			return nil, ErrInvalidSigner
 		}
-		return &Transaction{ContentType: contentType, Signer: signer.(string)[:index], SigTime: sigTime}, nil
+		return &Transaction{ContentType: contentType, Signer: signer.(string)[:index], KeyID: signer.(string), SigTime: sigTime, LC: lc, Ref: ref}, nil
 	}
 
 	// if the "kid" header is not present, we try to extract the signer from the embedded key
@@ -89,8 +113,9 @@ func FromJWS(transaction string) (*Transaction, error) {
 		if index == -1 {
 			return nil, ErrInvalidSigner
 		}
-		return &Transaction{ContentType: contentType, Signer: kid[:index], SigTime: sigTime}, nil
+		return &Transaction{ContentType: contentType, Signer: kid[:index], KeyID: kid, SigTime: sigTime, LC: lc, Ref: ref}, nil
 	}
 
-	return &Transaction{}, nil
+	// without a signer the transaction can't be counted
+	return nil, ErrInvalidSigner
 }
@@ -18,6 +18,8 @@
 package data
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -46,6 +48,8 @@ func TestFromJWS(t *testing.T) {
 		require.NoError(t, err)
 		assert.NotNil(t, transaction)
 		assert.Equal(t, time.Unix(1653986130, 0), transaction.SigTime)
+		assert.Equal(t, "did:nuts:Cor328J51hNxSuyEuBgaVuVnQpEgKs91sMJGaPu3B6Jr", transaction.Signer)
+		assert.Equal(t, "did:nuts:Cor328J51hNxSuyEuBgaVuVnQpEgKs91sMJGaPu3B6Jr#r3C3ndXqLOF3ZJBNHyIS8HQ3J4UBiJDjeA4FDAQJNu8", transaction.KeyID)
 	})
 
 	t.Run("extract transaction from a valid JWS without a jwk field", func(t *testing.T) {
@@ -53,12 +57,16 @@ func TestFromJWS(t *testing.T) {
 
 		require.NoError(t, err)
 		assert.NotNil(t, transaction)
+		assert.Equal(t, 10, transaction.LC)
+		sum := sha256.Sum256([]byte(ExampleJWS2))
+		assert.Equal(t, hex.EncodeToString(sum[:]), transaction.Ref)
+		assert.Equal(t, "did:nuts:9bW8VhVk2k4W5p15ZfTDtpQDaSk6oL6yaGBZzu7g29PZ#f7Qt72VTHg22l--VfvUfPNDtWF_YlD6aCMJ-BgoKyxQ", transaction.KeyID)
 	})
 	t.Run("extract transaction from a valid JWS without a jwk field and without a kid field", func(t *testing.T) {
 		transaction, err := FromJWS(ExampleJWS5)
 
-		require.NoError(t, err)
-		assert.NotNil(t, transaction)
+		assert.ErrorIs(t, err, ErrInvalidSigner)
+		assert.Nil(t, transaction)
 	})
 	t.Run("extract transaction from a valid JWS without a sigt field", func(t *testing.T) {
 		transaction, err := FromJWS(ExampleJWS3)
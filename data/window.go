@@ -29,13 +29,19 @@ type DataPoint struct {
 	Count     uint32
 }
 
+// slidingWindow counts transactions per content type in buckets of resolution, for the last length.
+// The counts of a content type are kept in a ring buffer: the position of a bucket is its number of resolutions since the Unix epoch modulo the number of buckets.
+// Adding a transaction doesn't allocate, unless it's the first transaction of a content type.
 type slidingWindow struct {
 	resolution       time.Duration
 	length           time.Duration
 	evictionInterval time.Duration
 	mutex            sync.Mutex
-	dataPoints       map[string][]DataPoint
-	clockdrift       time.Duration
+	// counts contains a ring buffer of maxLength buckets per content type
+	counts map[string][]uint32
+	// head is the number of the newest bucket, buckets older than maxLength before it are reused
+	head       int64
+	clockdrift time.Duration
 }
 
 func NewSlidingWindow(resolution, length, evictionInterval time.Duration) *slidingWindow {
@@ -43,11 +49,11 @@ func NewSlidingWindow(resolution, length, evictionInterval time.Duration) *slidi
 		resolution:       resolution,
 		length:           length,
 		evictionInterval: evictionInterval,
-		dataPoints:       map[string][]DataPoint{},
+		counts:           map[string][]uint32{},
 		clockdrift:       5 * time.Second,
 	}
 
-	s.consolidate()
+	s.slide(time.Now())
 
 	return s
 }
@@ -57,14 +63,15 @@ func (s *slidingWindow) Start(ctx context.Context) {
 
 	go func() {
 		ticker := time.NewTicker(s.evictionInterval)
+		defer ticker.Stop()
 
 		for {
 			select {
 			case <-done:
 				return
-			case <-ticker.C:
+			case now := <-ticker.C:
 				s.mutex.Lock()
-				s.consolidate()
+				s.slide(now)
 				s.mutex.Unlock()
 			}
 		}
@@ -76,101 +83,85 @@ func (s *slidingWindow) maxLength() int {
 	return int(s.length / s.resolution)
 }
 
-// slide removes dataPoints older than length
+// slide moves the head of the window to now and resets the buckets that move out of the window, for all content types
 func (s *slidingWindow) slide(now time.Time) {
-	for cty, a := range s.dataPoints {
-		cutoff := -1
-		for j := len(a) - 1; j >= 0; j-- {
-			if a[j].Timestamp.Before(now.Add(-s.length).Add(time.Nanosecond)) {
-				cutoff = j + 1
-				break
-			}
-		}
-
-		if cutoff == -1 {
-			return
+	current := s.bucket(now)
+	if current <= s.head {
+		return
+	}
+	// at most all buckets are reset, also when the window wasn't moved for a long time
+	from := max(s.head+1, current-int64(s.maxLength())+1)
+	for _, counts := range s.counts {
+		for b := from; b <= current; b++ {
+			counts[s.index(b)] = 0
 		}
-
-		s.dataPoints[cty] = a[cutoff:]
 	}
+	s.head = current
 }
 
 // AddCount adds +1 to the DataPoint at the correct moment
 func (s *slidingWindow) AddCount(txType string, at time.Time) {
-	// first we apply the clockdrift
-	at = at.Add(-1 * s.clockdrift)
-
-	// first we truncate the at time to the correct resolution
-	at = at.Truncate(s.resolution)
-
-	// then we check if the at time is in the sliding window
-	if at.Before(time.Now().Add(-s.length).Truncate(s.resolution)) {
-		// the at time is before the sliding window, we ignore it
-		return
-	}
-
-	// add the Count to the correct DataPoint
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if _, ok := s.dataPoints[txType]; !ok {
-		s.dataPoints[txType] = []DataPoint{}
+	s.add(txType, at, time.Now())
+}
+
+// add adds +1 to the bucket of at, after sliding the window to now.
+// Transactions before the window or after now are ignored, after correcting at for the clock drift.
+func (s *slidingWindow) add(txType string, at time.Time, now time.Time) {
+	s.slide(now)
+
+	// the clock of the signer may be ahead of ours
+	b := s.bucket(at.Add(-1 * s.clockdrift))
+	if b > s.head || b <= s.head-int64(s.maxLength()) {
+		return
 	}
 
-	for i, dataPoint := range s.dataPoints[txType] {
-		if dataPoint.Timestamp.Equal(at) {
-			s.dataPoints[txType][i].Count++
-			return
+	counts, ok := s.counts[txType]
+	if !ok {
+		if s.counts == nil {
+			s.counts = map[string][]uint32{}
 		}
+		counts = make([]uint32, s.maxLength())
+		s.counts[txType] = counts
 	}
-
-	// no DataPoint found, create a new one
-	s.dataPoints[txType] = append(s.dataPoints[txType], DataPoint{
-		Timestamp: at,
-		Count:     1,
-	})
-
-	// consolidate the window
-	s.consolidate()
-
-	return
+	counts[s.index(b)]++
 }
 
-// consolidate the window, this will fill any gaps in the dataPoints slice
-// it'll first truncate the slice and remove all old dataPoints
-// then it'll fill the slice with new DataPoints
-// and sort it afterwards
-func (s *slidingWindow) consolidate() {
-	now := time.Now().Truncate(s.resolution)
-
+// consolidate returns the window at now per content type: maxLength DataPoints without gaps, the oldest first
+func (s *slidingWindow) consolidate(now time.Time) map[string][]DataPoint {
 	s.slide(now)
 
-	for cty, slice := range s.dataPoints {
-		// first create a new slice with maxLen
-		newDataPoints := make([]DataPoint, s.maxLength())
-
-		// prefill the new slice with DataPoints
-		for i := len(newDataPoints) - 1; i >= 0; i-- {
-			newDataPoints[i] = DataPoint{
-				Timestamp: now.Add(time.Duration(i-len(newDataPoints)+1) * s.resolution),
-				Count:     0,
-			}
-		}
-
-		// then loop over the current dataPoints and add the count from those points to the correct new DataPoint
-		for _, dataPoint := range slice {
-			index := s.toIndex(dataPoint, now)
-			if index < len(newDataPoints) && index >= 0 { // huge clockdrift can cause this, so ignore it
-				newDataPoints[index].Count += dataPoint.Count
+	result := make(map[string][]DataPoint, len(s.counts))
+	for cty, counts := range s.counts {
+		dataPoints := make([]DataPoint, s.maxLength())
+		for i := range dataPoints {
+			b := s.head - int64(len(dataPoints)-1-i)
+			dataPoints[i] = DataPoint{
+				Timestamp: time.Unix(0, b*int64(s.resolution)),
+				Count:     counts[s.index(b)],
 			}
 		}
+		result[cty] = dataPoints
+	}
+	return result
+}
 
-		// set the new dataPoints
-		s.dataPoints[cty] = newDataPoints
+// bucket returns the number of the bucket that contains t, the number of resolutions since the Unix epoch.
+// This matches truncating t to the resolution, for resolutions that fit a whole number of times in a day.
+func (s *slidingWindow) bucket(t time.Time) int64 {
+	nanos, resolution := t.UnixNano(), int64(s.resolution)
+	b := nanos / resolution
+	if nanos%resolution < 0 {
+		// round towards the past for times before the epoch
+		b--
 	}
+	return b
 }
 
-// toIndex converts a datapoint to an index in the dataPoints slice
-func (s *slidingWindow) toIndex(dp DataPoint, now time.Time) int {
-	return s.maxLength() - int(now.Sub(dp.Timestamp)/s.resolution) - 1
+// index returns the position of a bucket in the ring buffers
+func (s *slidingWindow) index(bucket int64) int {
+	length := int64(s.maxLength())
+	return int((bucket%length + length) % length)
 }
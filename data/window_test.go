@@ -20,9 +20,12 @@ package data
 
 import (
 	"context"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"math/rand"
 	"testing"
+	"testing/quick"
 	"time"
 )
 
@@ -33,29 +36,30 @@ func TestSlidingWindow_AddCount(t *testing.T) {
 		window := slidingWindow{
 			resolution: time.Second,
 			length:     time.Second * 10,
-			dataPoints: map[string][]DataPoint{},
 		}
 
 		window.AddCount("test", now)
 
-		assert.Len(t, window.dataPoints["test"], 10)
-		assert.Equal(t, now.Truncate(time.Second), window.dataPoints["test"][9].Timestamp)
+		dataPoints := window.consolidate(now)["test"]
+		assert.Len(t, dataPoints, 10)
+		assert.Equal(t, now.Truncate(time.Second).Unix(), dataPoints[9].Timestamp.Unix())
+		assert.Equal(t, uint32(1), dataPoints[9].Count)
 	})
 
 	t.Run("adds a new DataPoint with a clockdrift", func(t *testing.T) {
-		now := time.Now().Add(time.Second * 2)
+		now := time.Now()
 
 		window := slidingWindow{
 			resolution: time.Second,
 			length:     time.Second * 10,
 			clockdrift: time.Second * 5,
-			dataPoints: map[string][]DataPoint{},
 		}
 
-		window.AddCount("test", now)
+		window.add("test", now.Add(time.Second*2), now)
 
-		assert.Len(t, window.dataPoints["test"], 10)
-		assert.Equal(t, uint32(1), window.dataPoints["test"][6].Count)
+		dataPoints := window.consolidate(now)["test"]
+		assert.Len(t, dataPoints, 10)
+		assert.Equal(t, uint32(1), dataPoints[6].Count)
 	})
 
 	t.Run("increases Count of existing DataPoint", func(t *testing.T) {
@@ -63,58 +67,108 @@ func TestSlidingWindow_AddCount(t *testing.T) {
 		window := slidingWindow{
 			resolution: time.Second,
 			length:     time.Second * 10,
-			dataPoints: map[string][]DataPoint{
-				"test": {
-					{Timestamp: now, Count: 1},
-				},
-			},
 		}
 
-		window.AddCount("test", now)
+		window.add("test", now, now)
+		window.add("test", now, now)
+
+		dataPoints := window.consolidate(now)
+		assert.Len(t, dataPoints, 1)
+		assert.Equal(t, uint32(2), dataPoints["test"][9].Count)
+	})
+
+	t.Run("ignores DataPoints before the window and in the future", func(t *testing.T) {
+		now := time.Now().Truncate(time.Second)
+		window := slidingWindow{
+			resolution: time.Second,
+			length:     time.Second * 10,
+		}
+
+		window.add("test", now.Add(-10*time.Second), now)
+		window.add("test", now.Add(time.Second), now)
 
-		assert.Len(t, window.dataPoints, 1)
-		assert.Equal(t, uint32(2), window.dataPoints["test"][0].Count)
+		assert.Empty(t, window.consolidate(now))
 	})
 }
 
 func TestSlidingWindow_slide(t *testing.T) {
 	t.Run("removes dataPoints older than length", func(t *testing.T) {
 		now := time.Now().Truncate(time.Second)
-		slightlyOlder := now.Add(-time.Millisecond)
 		window := slidingWindow{
 			resolution: time.Second,
 			length:     time.Second * 10,
-			dataPoints: map[string][]DataPoint{
-				"test": {
-					{Timestamp: slightlyOlder.Add(time.Second * -10), Count: 1},
-					{Timestamp: now.Add(time.Second * -9), Count: 1},
-					{Timestamp: now.Add(time.Second * -8), Count: 1},
-				},
-			},
-		}
-
-		window.slide(now)
-
-		assert.Len(t, window.dataPoints["test"], 2)
-		assert.Equal(t, now.Add(time.Second*-9), window.dataPoints["test"][0].Timestamp)
-		assert.Equal(t, uint32(1), window.dataPoints["test"][0].Count)
-		assert.Equal(t, now.Add(time.Second*-8), window.dataPoints["test"][1].Timestamp)
-		assert.Equal(t, uint32(1), window.dataPoints["test"][1].Count)
+		}
+		window.add("test", now.Add(time.Second*-9), now)
+		window.add("test", now.Add(time.Second*-8), now)
+		window.add("test", now.Add(time.Second*-7), now)
+
+		window.slide(now.Add(2 * time.Second))
+
+		dataPoints := window.consolidate(now.Add(2 * time.Second))["test"]
+		assert.Equal(t, now.Add(time.Second*-7).Unix(), dataPoints[0].Timestamp.Unix())
+		assert.Equal(t, uint32(1), dataPoints[0].Count)
+		assert.Equal(t, uint32(1), total(dataPoints))
+	})
+
+	t.Run("removes all dataPoints after a long time", func(t *testing.T) {
+		now := time.Now().Truncate(time.Second)
+		window := slidingWindow{
+			resolution: time.Second,
+			length:     time.Second * 10,
+		}
+		window.add("test", now, now)
+
+		window.slide(now.Add(time.Hour))
+
+		assert.Equal(t, uint32(0), total(window.consolidate(now.Add(time.Hour))["test"]))
+	})
+
+	t.Run("removes dataPoints of all content types", func(t *testing.T) {
+		now := time.Now().Truncate(time.Second)
+		window := slidingWindow{
+			resolution: time.Second,
+			length:     time.Second * 10,
+		}
+		// the first content type has no dataPoints that move out of the window
+		window.add("recent", now, now)
+		window.add("old", now.Add(time.Second*-9), now)
+		window.add("other", now.Add(time.Second*-9), now)
+
+		window.slide(now.Add(time.Second))
+
+		dataPoints := window.consolidate(now.Add(time.Second))
+		assert.Equal(t, uint32(1), total(dataPoints["recent"]))
+		assert.Equal(t, uint32(0), total(dataPoints["old"]))
+		assert.Equal(t, uint32(0), total(dataPoints["other"]))
+	})
+
+	t.Run("doesn't move back", func(t *testing.T) {
+		now := time.Now().Truncate(time.Second)
+		window := slidingWindow{
+			resolution: time.Second,
+			length:     time.Second * 10,
+		}
+		window.add("test", now, now)
+
+		window.slide(now.Add(-time.Minute))
+
+		assert.Equal(t, uint32(1), window.consolidate(now)["test"][9].Count)
 	})
 }
 
 func TestSlidingWindow_consolidate(t *testing.T) {
 	t.Run("it fills up a window to the length", func(t *testing.T) {
+		now := time.Now()
 		window := slidingWindow{
 			resolution: time.Second,
 			length:     time.Second * 10,
-			dataPoints: map[string][]DataPoint{"test": {}},
 		}
+		window.add("test", now, now)
 
-		window.consolidate()
+		dataPoints := window.consolidate(now)["test"]
 
-		assert.Len(t, window.dataPoints["test"], 10)
-		assert.Equal(t, uint32(0), window.dataPoints["test"][0].Count)
+		assert.Len(t, dataPoints, 10)
+		assert.Equal(t, uint32(0), dataPoints[0].Count)
 	})
 
 	t.Run("it fills gaps in the window", func(t *testing.T) {
@@ -122,37 +176,35 @@ func TestSlidingWindow_consolidate(t *testing.T) {
 		window := slidingWindow{
 			resolution: time.Second,
 			length:     time.Second * 5,
-			dataPoints: map[string][]DataPoint{"test": {
-				{Timestamp: now.Add(time.Second * -4), Count: 1},
-				{Timestamp: now.Add(time.Second * -2), Count: 1},
-				{Timestamp: now, Count: 2},
-			},
-			},
-		}
-
-		window.consolidate()
-
-		require.Len(t, window.dataPoints["test"], 5)
-		assert.Equal(t, uint32(1), window.dataPoints["test"][0].Count)
-		assert.Equal(t, uint32(0), window.dataPoints["test"][1].Count)
-		assert.Equal(t, uint32(1), window.dataPoints["test"][2].Count)
-		assert.Equal(t, uint32(0), window.dataPoints["test"][3].Count)
-		assert.Equal(t, uint32(2), window.dataPoints["test"][4].Count)
+		}
+		window.add("test", now.Add(time.Second*-4), now)
+		window.add("test", now.Add(time.Second*-2), now)
+		window.add("test", now, now)
+		window.add("test", now, now)
+
+		dataPoints := window.consolidate(now)["test"]
+
+		require.Len(t, dataPoints, 5)
+		assert.Equal(t, uint32(1), dataPoints[0].Count)
+		assert.Equal(t, uint32(0), dataPoints[1].Count)
+		assert.Equal(t, uint32(1), dataPoints[2].Count)
+		assert.Equal(t, uint32(0), dataPoints[3].Count)
+		assert.Equal(t, uint32(2), dataPoints[4].Count)
+		for i, dataPoint := range dataPoints {
+			assert.Equal(t, now.Add(time.Duration(i-4)*time.Second).Unix(), dataPoint.Timestamp.Unix())
+		}
 	})
 }
 
 func TestSlidingWindow_Start(t *testing.T) {
-	t.Run("slides & consolidates periodically", func(t *testing.T) {
+	t.Run("slides periodically", func(t *testing.T) {
+		now := time.Now()
 		window := slidingWindow{
 			resolution:       time.Second,
 			length:           time.Second * 5,
 			evictionInterval: time.Millisecond,
-			dataPoints: map[string][]DataPoint{"test": {
-				{Timestamp: time.Now().Truncate(time.Second).Add(-5 * time.Second), Count: 2},
-				{Timestamp: time.Now().Truncate(time.Second).Add(-4 * time.Second), Count: 1},
-			},
-			},
 		}
+		window.add("test", now.Add(-time.Hour), now.Add(-time.Hour))
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
@@ -163,31 +215,198 @@ func TestSlidingWindow_Start(t *testing.T) {
 		window.mutex.Lock()
 		defer window.mutex.Unlock()
 
-		require.Len(t, window.dataPoints["test"], 5)
-		assert.Equal(t, uint32(1), window.dataPoints["test"][0].Count)
+		assert.Equal(t, window.bucket(time.Now()), window.head)
+		assert.Equal(t, make([]uint32, 5), window.counts["test"])
 	})
 }
 
-func TestSlidingWindow_toIndex(t *testing.T) {
-	// 5 second window, 1 second resolution
-	// add a datapoint for each second starting at -11 * time.Now()
-	// call toIndex with each datapoint
-	// assert that the index is correct
-	now := time.Now().Truncate(time.Second)
+func TestSlidingWindow_bucket(t *testing.T) {
 	window := slidingWindow{
-		resolution: time.Second,
-		length:     time.Second * 5,
-		dataPoints: map[string][]DataPoint{"test": {
-			{Timestamp: now.Add(time.Second * -4), Count: 1}, // -4 to -3 interval
-			{Timestamp: now.Add(time.Second * -3), Count: 1}, // -3 to -2 interval
-			{Timestamp: now.Add(time.Second * -2), Count: 1}, // -2 to -1 interval
-			{Timestamp: now.Add(time.Second * -1), Count: 1}, // -1 to truncated interval
-			{Timestamp: now, Count: 1},                       // truncated at current second
-		},
-		},
+		resolution: 24 * time.Hour,
+		length:     30 * 24 * time.Hour,
+	}
+
+	// the bucket starts at the same time as truncating to the resolution
+	for _, at := range []time.Time{time.Now(), time.Unix(0, 0), time.Unix(-1, 0), time.Date(2023, 3, 1, 23, 59, 59, 0, time.UTC)} {
+		assert.Equal(t, at.Truncate(window.resolution).Unix(), time.Unix(0, window.bucket(at)*int64(window.resolution)).Unix())
+	}
+	// the buckets of a window have different positions in the ring buffer
+	positions := map[int]bool{}
+	for b := int64(-15); b < 15; b++ {
+		positions[window.index(b)] = true
+	}
+	assert.Len(t, positions, 30)
+}
+
+// windowOperation is a random operation on a sliding window of 10 seconds, used by the property tests
+type windowOperation struct {
+	// ContentType is one of 4 content types
+	ContentType uint8
+	// Offset of the signature time in seconds relative to now, transactions may be in the future or before the window
+	Offset int8
+	// Advance moves now forward by 0 to 3 seconds before the transaction is added
+	Advance uint8
+}
+
+// windowModel is a reference implementation of the sliding window: it keeps all transactions and counts them when asked
+type windowModel struct {
+	length time.Duration
+	// added contains the signature times per content type of the transactions that were in the window when they were added
+	added map[string][]time.Time
+}
+
+func (m *windowModel) add(contentType string, at time.Time, now time.Time) {
+	// a transaction is counted from the start of its second, until the second after the window
+	start := at.Truncate(time.Second)
+	if start.After(now) || !start.After(now.Truncate(time.Second).Add(-m.length)) {
+		return
+	}
+	m.added[contentType] = append(m.added[contentType], start)
+}
+
+func (m *windowModel) count(contentType string, second time.Time) (result uint32) {
+	for _, at := range m.added[contentType] {
+		if at.Equal(second) {
+			result++
+		}
+	}
+	return
+}
+
+func TestSlidingWindow_properties(t *testing.T) {
+	const length = 10 * time.Second
+	contentTypes := []string{"application/did+json", "application/vc+json", "application/ld+json;type=revocation", "application/other"}
+	start := time.Now().Truncate(time.Second)
+	config := &quick.Config{MaxCount: 500}
+
+	// run applies the operations to a window and the model, it returns the time after the last operation
+	run := func(operations []windowOperation, clockdrift time.Duration, window *slidingWindow, model *windowModel) time.Time {
+		now := start
+		for _, operation := range operations {
+			now = now.Add(time.Duration(operation.Advance%4) * time.Second)
+			contentType := contentTypes[int(operation.ContentType)%len(contentTypes)]
+			at := now.Add(time.Duration(operation.Offset) * time.Second)
+			window.add(contentType, at, now)
+			model.add(contentType, at.Add(-clockdrift), now)
+		}
+		return now
+	}
+
+	t.Run("counts match the model", func(t *testing.T) {
+		property := func(operations []windowOperation, drift uint8) bool {
+			clockdrift := time.Duration(drift%8) * time.Second
+			window := &slidingWindow{resolution: time.Second, length: length, clockdrift: clockdrift}
+			model := &windowModel{length: length, added: map[string][]time.Time{}}
+
+			now := run(operations, clockdrift, window, model)
+
+			for contentType, dataPoints := range window.consolidate(now) {
+				for _, dataPoint := range dataPoints {
+					if dataPoint.Count != model.count(contentType, dataPoint.Timestamp) {
+						t.Logf("%s at %s: expected %d, got %d", contentType, dataPoint.Timestamp, model.count(contentType, dataPoint.Timestamp), dataPoint.Count)
+						return false
+					}
+				}
+			}
+			// every content type with a counted transaction has a series, even if the transaction has been removed
+			for contentType := range model.added {
+				if _, ok := window.counts[contentType]; !ok {
+					return false
+				}
+			}
+			return true
+		}
+
+		assert.NoError(t, quick.Check(property, config))
+	})
+	t.Run("consolidated windows are complete and end at now", func(t *testing.T) {
+		property := func(operations []windowOperation, later uint16) bool {
+			window := &slidingWindow{resolution: time.Second, length: length}
+			model := &windowModel{length: length, added: map[string][]time.Time{}}
+			now := run(operations, 0, window, model).Add(time.Duration(later) * time.Millisecond)
+
+			for _, dataPoints := range window.consolidate(now) {
+				if len(dataPoints) != window.maxLength() || !dataPoints[len(dataPoints)-1].Timestamp.Equal(now.Truncate(time.Second)) {
+					return false
+				}
+				for i := 1; i < len(dataPoints); i++ {
+					if dataPoints[i].Timestamp.Sub(dataPoints[i-1].Timestamp) != time.Second {
+						return false
+					}
+				}
+			}
+			return true
+		}
+
+		assert.NoError(t, quick.Check(property, config))
+	})
+	t.Run("the order of transactions doesn't matter", func(t *testing.T) {
+		property := func(operations []windowOperation, seed int64) bool {
+			// all transactions are added at the same time, so only their order differs
+			for i := range operations {
+				operations[i].Advance = 0
+			}
+			shuffled := append([]windowOperation(nil), operations...)
+			rand.New(rand.NewSource(seed)).Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+			window := &slidingWindow{resolution: time.Second, length: length}
+			other := &slidingWindow{resolution: time.Second, length: length}
+			model := &windowModel{length: length, added: map[string][]time.Time{}}
+
+			now := run(operations, 0, window, model)
+			run(shuffled, 0, other, model)
+
+			return assert.ObjectsAreEqual(window.consolidate(now), other.consolidate(now))
+		}
+
+		assert.NoError(t, quick.Check(property, config))
+	})
+	t.Run("sliding only removes transactions", func(t *testing.T) {
+		property := func(operations []windowOperation, advance uint8) bool {
+			window := &slidingWindow{resolution: time.Second, length: length}
+			model := &windowModel{length: length, added: map[string][]time.Time{}}
+			now := run(operations, 0, window, model)
+			before := window.consolidate(now)
+
+			later := now.Add(time.Duration(advance%16) * time.Second)
+			after := window.consolidate(later)
+
+			for contentType, dataPoints := range after {
+				// the dataPoints that are still in the window keep their count, new ones are empty
+				shift := int(later.Sub(now) / time.Second)
+				for i, dataPoint := range dataPoints {
+					expected := uint32(0)
+					if i+shift < len(dataPoints) {
+						expected = before[contentType][i+shift].Count
+					}
+					if dataPoint.Count != expected {
+						return false
+					}
+				}
+			}
+			return len(after) == len(before)
+		}
+
+		assert.NoError(t, quick.Check(property, config))
+	})
+}
+
+func BenchmarkSlidingWindow_AddCount(b *testing.B) {
+	window := NewSlidingWindow(24*time.Hour, 30*24*time.Hour, time.Minute)
+	contentTypes := make([]string, 10)
+	for i := range contentTypes {
+		contentTypes[i] = fmt.Sprintf("application/type-%d", i)
 	}
+	now := time.Now()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		window.AddCount(contentTypes[i%len(contentTypes)], now.Add(-time.Duration(i%(30*24))*time.Hour))
+	}
+}
 
-	for i := 0; i < 5; i++ {
-		assert.Equal(t, i, window.toIndex(window.dataPoints["test"][i], now))
+// total returns the sum of the counts of the dataPoints
+func total(dataPoints []DataPoint) (result uint32) {
+	for _, dataPoint := range dataPoints {
+		result += dataPoint.Count
 	}
+	return
 }
@@ -0,0 +1,79 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package graph
+
+import "sort"
+
+// Analysis contains the results of analysing a network graph
+type Analysis struct {
+	// Partitioned is true when the network consists of more than one connected component
+	Partitioned bool `json:"partitioned"`
+	// Components lists the PeerIDs per connected component, largest first
+	Components [][]string `json:"components"`
+	// CriticalPeers are the articulation points: peers whose removal would partition the network
+	CriticalPeers []string `json:"critical_peers"`
+	// Bridges are connections whose removal would partition the network
+	Bridges [][2]string `json:"bridges"`
+	// Peers contains the statistics per peer, sorted by PeerID
+	Peers []VertexStats `json:"peers"`
+}
+
+// VertexStats contains the statistics of a single vertex
+type VertexStats struct {
+	PeerID string `json:"peer_id"`
+	// Degree is the number of connections
+	Degree int `json:"degree"`
+	// Eccentricity is the greatest number of hops to any other peer in the same component
+	Eccentricity int `json:"eccentricity"`
+	// Critical is true when the peer is an articulation point
+	Critical bool `json:"critical"`
+}
+
+// Analyze computes the components, articulation points, bridges and per vertex statistics of the graph
+func (g *Graph) Analyze() Analysis {
+	components := g.Components()
+	if components == nil {
+		components = [][]string{}
+	}
+	analysis := Analysis{
+		Partitioned:   len(components) > 1,
+		Components:    components,
+		CriticalPeers: g.ArticulationPoints(),
+		Bridges:       g.Bridges(),
+		Peers:         make([]VertexStats, 0, len(g.vertices)),
+	}
+
+	critical := map[string]bool{}
+	for _, v := range analysis.CriticalPeers {
+		critical[v] = true
+	}
+	for _, v := range g.vertices {
+		analysis.Peers = append(analysis.Peers, VertexStats{
+			PeerID:       v,
+			Degree:       g.Degree(v),
+			Eccentricity: g.Eccentricity(v),
+			Critical:     critical[v],
+		})
+	}
+	sort.Slice(analysis.Peers, func(i, j int) bool {
+		return analysis.Peers[i].PeerID < analysis.Peers[j].PeerID
+	})
+
+	return analysis
+}
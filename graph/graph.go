@@ -0,0 +1,230 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package graph
+
+import (
+	"sort"
+)
+
+// Graph is an undirected graph of vertices identified by a string, e.g. a PeerID.
+// Vertices keep the order in which they were added, analysis results are sorted.
+type Graph struct {
+	vertices []string
+	index    map[string]int
+	adjacent [][]int
+}
+
+// New creates a graph from the given vertices and edges. Vertices that only appear in an edge are added as well.
+// Self-loops and duplicate edges are ignored.
+func New(vertices []string, edges [][2]string) *Graph {
+	g := &Graph{index: map[string]int{}}
+	for _, v := range vertices {
+		g.addVertex(v)
+	}
+	for _, e := range edges {
+		a, b := g.addVertex(e[0]), g.addVertex(e[1])
+		if a == b || g.connected(a, b) {
+			continue
+		}
+		g.adjacent[a] = append(g.adjacent[a], b)
+		g.adjacent[b] = append(g.adjacent[b], a)
+	}
+	return g
+}
+
+func (g *Graph) addVertex(v string) int {
+	if i, ok := g.index[v]; ok {
+		return i
+	}
+	g.index[v] = len(g.vertices)
+	g.vertices = append(g.vertices, v)
+	g.adjacent = append(g.adjacent, nil)
+	return len(g.vertices) - 1
+}
+
+func (g *Graph) connected(a, b int) bool {
+	for _, n := range g.adjacent[a] {
+		if n == b {
+			return true
+		}
+	}
+	return false
+}
+
+// Degree returns the number of neighbours of the given vertex, or 0 if it doesn't exist
+func (g *Graph) Degree(v string) int {
+	i, ok := g.index[v]
+	if !ok {
+		return 0
+	}
+	return len(g.adjacent[i])
+}
+
+// Components returns the connected components of the graph.
+// Each component is sorted, the components are sorted by size (largest first) and then by their first vertex.
+func (g *Graph) Components() [][]string {
+	visited := make([]bool, len(g.vertices))
+	var components [][]string
+	for start := range g.vertices {
+		if visited[start] {
+			continue
+		}
+		var component []string
+		for _, i := range g.bfs(start, visited) {
+			component = append(component, g.vertices[i])
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		if len(components[i]) != len(components[j]) {
+			return len(components[i]) > len(components[j])
+		}
+		return components[i][0] < components[j][0]
+	})
+	return components
+}
+
+// Eccentricity returns the greatest distance from the given vertex to any other vertex in its component.
+// It returns -1 if the vertex doesn't exist.
+func (g *Graph) Eccentricity(v string) int {
+	start, ok := g.index[v]
+	if !ok {
+		return -1
+	}
+	distance := make([]int, len(g.vertices))
+	for i := range distance {
+		distance[i] = -1
+	}
+	distance[start] = 0
+	eccentricity := 0
+	queue := []int{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, n := range g.adjacent[current] {
+			if distance[n] == -1 {
+				distance[n] = distance[current] + 1
+				if distance[n] > eccentricity {
+					eccentricity = distance[n]
+				}
+				queue = append(queue, n)
+			}
+		}
+	}
+	return eccentricity
+}
+
+// bfs returns all vertices reachable from start that haven't been visited yet and marks them as visited
+func (g *Graph) bfs(start int, visited []bool) []int {
+	visited[start] = true
+	result := []int{start}
+	for i := 0; i < len(result); i++ {
+		for _, n := range g.adjacent[result[i]] {
+			if !visited[n] {
+				visited[n] = true
+				result = append(result, n)
+			}
+		}
+	}
+	return result
+}
+
+// ArticulationPoints returns the vertices whose removal increases the number of connected components, sorted.
+func (g *Graph) ArticulationPoints() []string {
+	result := []string{}
+	isCut, _ := g.cuts()
+	for i, cut := range isCut {
+		if cut {
+			result = append(result, g.vertices[i])
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Bridges returns the edges whose removal increases the number of connected components.
+// The vertices within an edge are sorted and the edges themselves are sorted as well.
+func (g *Graph) Bridges() [][2]string {
+	_, bridges := g.cuts()
+	result := make([][2]string, 0, len(bridges))
+	for _, b := range bridges {
+		e := [2]string{g.vertices[b[0]], g.vertices[b[1]]}
+		if e[0] > e[1] {
+			e[0], e[1] = e[1], e[0]
+		}
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i][0] != result[j][0] {
+			return result[i][0] < result[j][0]
+		}
+		return result[i][1] < result[j][1]
+	})
+	return result
+}
+
+// cuts finds articulation points and bridges using Tarjan's algorithm
+func (g *Graph) cuts() ([]bool, [][2]int) {
+	n := len(g.vertices)
+	discovery := make([]int, n)
+	low := make([]int, n)
+	isCut := make([]bool, n)
+	var bridges [][2]int
+	for i := range discovery {
+		discovery[i] = -1
+	}
+	timer := 0
+
+	var visit func(v, parent int)
+	visit = func(v, parent int) {
+		discovery[v] = timer
+		low[v] = timer
+		timer++
+		children := 0
+		for _, w := range g.adjacent[v] {
+			if w == parent {
+				continue
+			}
+			if discovery[w] != -1 {
+				low[v] = min(low[v], discovery[w])
+				continue
+			}
+			children++
+			visit(w, v)
+			low[v] = min(low[v], low[w])
+			if parent != -1 && low[w] >= discovery[v] {
+				isCut[v] = true
+			}
+			if low[w] > discovery[v] {
+				bridges = append(bridges, [2]int{v, w})
+			}
+		}
+		if parent == -1 && children > 1 {
+			isCut[v] = true
+		}
+	}
+
+	for v := range g.vertices {
+		if discovery[v] == -1 {
+			visit(v, -1)
+		}
+	}
+	return isCut, bridges
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// triangleWithTail is a triangle a-b-c where c also connects to d, and d to e
+func triangleWithTail() *Graph {
+	return New(nil, [][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}, {"c", "d"}, {"d", "e"}})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("ignores self-loops and duplicate edges", func(t *testing.T) {
+		g := New([]string{"a"}, [][2]string{{"a", "a"}, {"a", "b"}, {"b", "a"}})
+
+		assert.Equal(t, 1, g.Degree("a"))
+		assert.Equal(t, 1, g.Degree("b"))
+	})
+	t.Run("unknown vertex has degree 0", func(t *testing.T) {
+		assert.Equal(t, 0, New(nil, nil).Degree("a"))
+	})
+}
+
+func TestGraph_Components(t *testing.T) {
+	t.Run("single component", func(t *testing.T) {
+		assert.Equal(t, [][]string{{"a", "b", "c", "d", "e"}}, triangleWithTail().Components())
+	})
+	t.Run("isolated vertices and multiple components", func(t *testing.T) {
+		g := New([]string{"z"}, [][2]string{{"a", "b"}, {"c", "d"}, {"d", "e"}})
+
+		assert.Equal(t, [][]string{{"c", "d", "e"}, {"a", "b"}, {"z"}}, g.Components())
+	})
+}
+
+func TestGraph_ArticulationPoints(t *testing.T) {
+	t.Run("triangle with tail", func(t *testing.T) {
+		assert.Equal(t, []string{"c", "d"}, triangleWithTail().ArticulationPoints())
+	})
+	t.Run("star", func(t *testing.T) {
+		g := New(nil, [][2]string{{"hub", "a"}, {"hub", "b"}, {"hub", "c"}})
+
+		assert.Equal(t, []string{"hub"}, g.ArticulationPoints())
+	})
+	t.Run("cycle has none", func(t *testing.T) {
+		g := New(nil, [][2]string{{"a", "b"}, {"b", "c"}, {"c", "d"}, {"d", "a"}})
+
+		assert.Empty(t, g.ArticulationPoints())
+	})
+}
+
+func TestGraph_Bridges(t *testing.T) {
+	t.Run("triangle with tail", func(t *testing.T) {
+		assert.Equal(t, [][2]string{{"c", "d"}, {"d", "e"}}, triangleWithTail().Bridges())
+	})
+	t.Run("cycle has none", func(t *testing.T) {
+		g := New(nil, [][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}})
+
+		assert.Empty(t, g.Bridges())
+	})
+}
+
+func TestGraph_Eccentricity(t *testing.T) {
+	g := triangleWithTail()
+
+	assert.Equal(t, 3, g.Eccentricity("a"))
+	assert.Equal(t, 2, g.Eccentricity("c"))
+	assert.Equal(t, 3, g.Eccentricity("e"))
+	assert.Equal(t, -1, g.Eccentricity("unknown"))
+}
+
+func TestGraph_Analyze(t *testing.T) {
+	t.Run("partitioned network", func(t *testing.T) {
+		g := New([]string{"us"}, [][2]string{{"a", "b"}})
+
+		analysis := g.Analyze()
+
+		assert.True(t, analysis.Partitioned)
+		assert.Len(t, analysis.Components, 2)
+		assert.Equal(t, [][2]string{{"a", "b"}}, analysis.Bridges)
+	})
+	t.Run("peer statistics", func(t *testing.T) {
+		analysis := triangleWithTail().Analyze()
+
+		assert.False(t, analysis.Partitioned)
+		require.Len(t, analysis.Peers, 5)
+		assert.Equal(t, VertexStats{PeerID: "c", Degree: 3, Eccentricity: 2, Critical: true}, analysis.Peers[2])
+		assert.Equal(t, VertexStats{PeerID: "e", Degree: 1, Eccentricity: 3, Critical: false}, analysis.Peers[4])
+	})
+	t.Run("empty graph", func(t *testing.T) {
+		analysis := New(nil, nil).Analyze()
+
+		assert.False(t, analysis.Partitioned)
+		assert.Empty(t, analysis.Components)
+		assert.Empty(t, analysis.Peers)
+	})
+}
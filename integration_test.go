@@ -20,20 +20,25 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"nuts-foundation/nuts-monitor/api"
 	"nuts-foundation/nuts-monitor/client"
-	"nuts-foundation/nuts-monitor/client/diagnostics"
-	"nuts-foundation/nuts-monitor/client/network"
 	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/data"
+	"nuts-foundation/nuts-monitor/graph"
 	"nuts-foundation/nuts-monitor/test"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -56,6 +61,11 @@ func TestStatusAndHealth(t *testing.T) {
 		testCases := []operation{
 			{path: "/status"},
 			{path: "/health"},
+			{path: "/web/transactions/clockskew"},
+			{path: "/web/transactions/keys"},
+			{path: "/web/transactions/credentials"},
+			{path: "/web/dids/changes"},
+			{path: "/web/anomalies"},
 		}
 
 		for _, testCase := range testCases {
@@ -67,39 +77,88 @@ func TestStatusAndHealth(t *testing.T) {
 	})
 }
 
-func TestNetworkTopology(t *testing.T) {
-	ts := test.BasicTestNode(t)
+func TestNodes(t *testing.T) {
+	ts := topologyTestNode(t)
 	os.Setenv("NUTS_NUTSNODEADDR", ts.URL())
 	defer os.Clearenv()
 	httpPort := startServer(t)
-	diagnosticsBytes, _ := json.Marshal(diagnostics.Diagnostics{
-		Network: diagnostics.Network{
-			Connections: struct {
-				ConnectedPeers      []diagnostics.ConnectedPeer `json:"connected_peers"`
-				ConnectedPeersCount int                         `json:"connected_peers_count"`
-				PeerId              string                      `json:"peer_id"`
-			}{
-				ConnectedPeersCount: 0,
-				PeerId:              "us",
-			},
-		},
-	})
-	peers := []string{"us"}
-	peerDiagnosticsBytes, _ := json.Marshal(map[string]network.PeerDiagnostics{
-		"them": {
-			Peers: &peers,
-		},
-	})
-	ts.HandleFunc("/internal/network/v1/diagnostics/peers", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(peerDiagnosticsBytes)
+	baseUrl := fmt.Sprintf("http://localhost:%d", httpPort)
+
+	t.Run("overview", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/web/nodes", baseUrl))
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		overview := api.NodesOverview{}
+		bytes, _ := io.ReadAll(resp.Body)
+		_ = json.Unmarshal(bytes, &overview)
+		require.Len(t, overview.Nodes, 1)
+		assert.Equal(t, "default", overview.Nodes[0].Name)
+		assert.Equal(t, "UP", overview.Nodes[0].Status)
+		assert.Equal(t, "us", overview.Nodes[0].PeerID)
+		assert.True(t, overview.Consistent)
 	})
-	ts.HandleFunc("/status/diagnostics", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(diagnosticsBytes)
+	t.Run("select node by name", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/web/network_topology?node=default", baseUrl))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+	t.Run("unknown node", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/web/network_topology?node=unknown", baseUrl))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 	})
+}
+
+func TestNodes_networks(t *testing.T) {
+	acceptance1, acceptance2, production := test.NewSimulator(t), test.NewSimulator(t), test.NewSimulator(t)
+	production.CreateDID(t)
+	configFile := filepath.Join(t.TempDir(), "nodes.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf(`nodes:
+  - name: acceptance1
+    address: %q
+    network: acceptance
+  - name: acceptance2
+    address: %q
+    network: acceptance
+  - name: production
+    address: %q
+    network: production
+`, acceptance1.URL(), acceptance2.URL(), production.URL())), 0600))
+	t.Setenv("NUTS_CONFIGFILE", configFile)
+	httpPort := startServer(t)
+	overview := func(t *testing.T) api.NodesOverview {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/web/nodes", httpPort))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		result := api.NodesOverview{}
+		bytes, _ := io.ReadAll(resp.Body)
+		require.NoError(t, json.Unmarshal(bytes, &result))
+		return result
+	}
+
+	t.Run("only nodes of the same network are compared", func(t *testing.T) {
+		result := overview(t)
+
+		require.Len(t, result.Nodes, 3)
+		assert.Equal(t, "acceptance", result.Nodes[0].Network)
+		assert.NotEqual(t, result.Nodes[0].DagXor, result.Nodes[2].DagXor)
+		assert.True(t, result.Consistent)
+	})
+	t.Run("nodes of the same network with different DAGs", func(t *testing.T) {
+		acceptance2.CreateDID(t)
+
+		assert.False(t, overview(t).Consistent)
+	})
+}
+
+func TestNetworkTopology(t *testing.T) {
+	ts := topologyTestNode(t)
+	os.Setenv("NUTS_NUTSNODEADDR", ts.URL())
+	defer os.Clearenv()
+	httpPort := startServer(t)
 
 	baseUrl := fmt.Sprintf("http://localhost:%d", httpPort)
 	resp, err := http.Get(fmt.Sprintf("%s%s", baseUrl, "/web/network_topology"))
@@ -116,9 +175,475 @@ func TestNetworkTopology(t *testing.T) {
 	assert.Equal(t, "us", topology.Peers[0].PeerID)
 }
 
+func TestNetworkTopology_export(t *testing.T) {
+	ts := topologyTestNode(t)
+	os.Setenv("NUTS_NUTSNODEADDR", ts.URL())
+	defer os.Clearenv()
+	httpPort := startServer(t)
+	baseUrl := fmt.Sprintf("http://localhost:%d", httpPort)
+
+	testCases := []struct {
+		format      string
+		contentType string
+		status      int
+	}{
+		{format: "dot", contentType: "text/vnd.graphviz", status: http.StatusOK},
+		{format: "graphml", contentType: "application/graphml+xml", status: http.StatusOK},
+		{format: "jgf", contentType: "application/vnd.jgf+json", status: http.StatusOK},
+		{format: "png", contentType: "text/plain", status: http.StatusBadRequest},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.format, func(t *testing.T) {
+			resp, err := http.Get(fmt.Sprintf("%s/web/network_topology?format=%s", baseUrl, testCase.format))
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.status, resp.StatusCode)
+			assert.Equal(t, testCase.contentType, resp.Header.Get("Content-Type"))
+		})
+	}
+}
+
+func TestRenderDAG(t *testing.T) {
+	ts := test.BasicTestNode(t)
+	ts.HandleFunc("/internal/network/v1/diagnostics/graph", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("digraph {\n  \"a\" -> \"b\"\n}"))
+	})
+	os.Setenv("NUTS_NUTSNODEADDR", ts.URL())
+	os.Setenv("NUTS_DAGRENDERMAXRANGE", "100")
+	defer os.Clearenv()
+	httpPort := startServer(t)
+	baseUrl := fmt.Sprintf("http://localhost:%d", httpPort)
+
+	t.Run("ok", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/web/dag?start=0&end=100", baseUrl))
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		dag := client.DAG{}
+		bytes, _ := io.ReadAll(resp.Body)
+		_ = json.Unmarshal(bytes, &dag)
+		assert.Len(t, dag.Nodes, 2)
+	})
+	t.Run("range too large", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/web/dag?start=0&end=101", baseUrl))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+	t.Run("missing range", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/web/dag?start=0", baseUrl))
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestRenderDAG_transaction(t *testing.T) {
+	simulator := test.NewSimulator(t)
+	signer := simulator.CreateDID(t)
+	for i := 0; i < 5; i++ {
+		simulator.AddTransaction(t, signer, "application/vc+json", time.Now())
+	}
+	os.Setenv("NUTS_NUTSNODEADDR", simulator.URL())
+	defer os.Clearenv()
+	httpPort := startServer(t)
+	baseUrl := fmt.Sprintf("http://localhost:%d", httpPort)
+	render := func(t *testing.T, query string) (*http.Response, client.DAG) {
+		resp, err := http.Get(fmt.Sprintf("%s/web/dag?%s", baseUrl, query))
+		require.NoError(t, err)
+		dag := client.DAG{}
+		bytes, _ := io.ReadAll(resp.Body)
+		_ = json.Unmarshal(bytes, &dag)
+		return resp, dag
+	}
+
+	t.Run("renders around the transaction", func(t *testing.T) {
+		resp, dag := render(t, "transaction="+simulator.Transactions()[3].Ref+"&radius=1")
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Len(t, dag.Nodes, 3)
+	})
+	t.Run("radius is clamped at the start of the DAG", func(t *testing.T) {
+		resp, dag := render(t, "transaction="+simulator.Transactions()[0].Ref+"&radius=2")
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Len(t, dag.Nodes, 3)
+	})
+	t.Run("unknown transaction", func(t *testing.T) {
+		resp, _ := render(t, "transaction=unknown")
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+	t.Run("node fails", func(t *testing.T) {
+		simulator.Fail("/internal/network/v1/transaction/", test.ServerError(http.StatusInternalServerError))
+		defer simulator.Reset()
+
+		resp, _ := render(t, "transaction="+simulator.Transactions()[3].Ref)
+
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	})
+}
+
+func TestNetworkAnalysis(t *testing.T) {
+	ts := topologyTestNode(t)
+	os.Setenv("NUTS_NUTSNODEADDR", ts.URL())
+	defer os.Clearenv()
+	httpPort := startServer(t)
+
+	baseUrl := fmt.Sprintf("http://localhost:%d", httpPort)
+	resp, err := http.Get(fmt.Sprintf("%s%s", baseUrl, "/web/network/analysis"))
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	analysis := graph.Analysis{}
+	bytes, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(bytes, &analysis)
+	assert.False(t, analysis.Partitioned)
+	assert.Equal(t, [][2]string{{"them", "us"}}, analysis.Bridges)
+	require.Len(t, analysis.Peers, 2)
+	assert.Equal(t, 1, analysis.Peers[0].Degree)
+}
+
+func TestAuthentication(t *testing.T) {
+	ts := test.BasicTestNode(t)
+	os.Setenv("NUTS_NUTSNODEADDR", ts.URL())
+	t.Setenv("NUTS_CONFIGFILE", "test/auth.config.yaml")
+	httpPort := startServer(t)
+	baseUrl := fmt.Sprintf("http://localhost:%d", httpPort)
+
+	get := func(path string, modifier func(r *http.Request)) *http.Response {
+		request, _ := http.NewRequest(http.MethodGet, baseUrl+path, nil)
+		if modifier != nil {
+			modifier(request)
+		}
+		resp, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("status and health are public", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/status", nil).StatusCode)
+		assert.Equal(t, http.StatusOK, get("/health", nil).StatusCode)
+	})
+	t.Run("API requires authentication", func(t *testing.T) {
+		resp := get("/web/transactions/counts", nil)
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, []string{`Bearer realm="nuts-monitor"`, `Basic realm="nuts-monitor"`}, resp.Header.Values("WWW-Authenticate"))
+	})
+	t.Run("metrics require authentication without an internal address", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, get("/metrics", nil).StatusCode)
+		resp := get("/metrics", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer viewer-token")
+		})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+	t.Run("bearer token", func(t *testing.T) {
+		resp := get("/web/transactions/counts", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer viewer-token")
+		})
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+	t.Run("basic auth", func(t *testing.T) {
+		resp := get("/web/transactions/counts", func(r *http.Request) {
+			r.SetBasicAuth("admin", "secret")
+		})
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+	t.Run("wrong password", func(t *testing.T) {
+		resp := get("/web/transactions/counts", func(r *http.Request) {
+			r.SetBasicAuth("admin", "wrong")
+		})
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestConfigAPI(t *testing.T) {
+	ts := test.BasicTestNode(t)
+	t.Setenv("NUTS_NUTSNODEADDR", ts.URL())
+	t.Setenv("NUTS_CONFIGFILE", "test/auth.config.yaml")
+	httpPort := startServer(t)
+	request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:%d/web/config", httpPort), nil)
+	request.Header.Set("Authorization", "Bearer viewer-token")
+
+	resp, err := http.DefaultClient.Do(request)
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.NotContains(t, string(body), "viewer-token")
+	assert.NotContains(t, string(body), "$2a$04$")
+	var overview api.ConfigOverview
+	require.NoError(t, json.Unmarshal(body, &overview))
+	assert.Equal(t, ts.URL(), overview.Config["nutsnodeaddr"])
+	assert.Equal(t, "test/auth.config.yaml", overview.Config["configfile"])
+	assert.Equal(t, config.RedactedValue, overview.Config["auth"].(map[string]interface{})["tokens"].([]interface{})[0].(map[string]interface{})["token"])
+	assert.Equal(t, map[string]string{
+		"nutsnodeaddr":        config.SourceEnv,
+		"auth.tokens":         config.SourceFile,
+		"consistencyinterval": config.SourceDefault,
+		"configfile":          config.SourceEnv,
+	}, map[string]string{
+		"nutsnodeaddr":        overview.Sources["nutsnodeaddr"],
+		"auth.tokens":         overview.Sources["auth.tokens"],
+		"consistencyinterval": overview.Sources["consistencyinterval"],
+		"configfile":          overview.Sources["configfile"],
+	})
+}
+
+func TestConfigReload(t *testing.T) {
+	first := test.BasicTestNode(t)
+	second := test.BasicTestNode(t)
+	t.Setenv("NUTS_NUTSNODEADDR", first.URL())
+	t.Setenv("NUTS_CONFIGFILE", "test/auth.config.yaml")
+	httpPort := startServer(t)
+	baseUrl := fmt.Sprintf("http://localhost:%d", httpPort)
+	do := func(method string, path string) *http.Response {
+		request, _ := http.NewRequest(method, baseUrl+path, nil)
+		request.SetBasicAuth("admin", "secret")
+		resp, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		return resp
+	}
+	nodeAddress := func() string {
+		overview := api.NodesOverview{}
+		require.NoError(t, json.NewDecoder(do(http.MethodGet, "/web/nodes").Body).Decode(&overview))
+		return overview.Nodes[0].Address
+	}
+	require.Equal(t, first.URL(), nodeAddress())
+
+	t.Run("node address requires a restart", func(t *testing.T) {
+		t.Setenv("NUTS_NUTSNODEADDR", second.URL())
+
+		resp := do(http.MethodPost, "/web/config/reload")
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		status := api.ReloadStatus{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		assert.Equal(t, 1, status.Reloads)
+		assert.Equal(t, config.TriggerAPI, status.Trigger)
+		assert.Equal(t, []string{"nutsnodeaddr"}, status.RestartRequired)
+		assert.Equal(t, first.URL(), nodeAddress())
+	})
+	t.Run("invalid config is not applied", func(t *testing.T) {
+		t.Setenv("NUTS_NUTSNODEADDR", "not-a-url")
+
+		resp := do(http.MethodPost, "/web/config/reload")
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), `nutsnodeaddr: invalid URL "not-a-url"`)
+		assert.Equal(t, first.URL(), nodeAddress())
+		overview := api.ConfigOverview{}
+		require.NoError(t, json.NewDecoder(do(http.MethodGet, "/web/config").Body).Decode(&overview))
+		assert.Equal(t, 1, overview.Reload.Reloads)
+		assert.Contains(t, overview.Reload.Error, "nutsnodeaddr")
+	})
+	t.Run("auth changes require a restart", func(t *testing.T) {
+		t.Setenv("NUTS_AUTH_OIDC_ISSUER", "https://idp.example.com")
+		t.Setenv("NUTS_AUTH_OIDC_CLIENTID", "monitor")
+		t.Setenv("NUTS_AUTH_OIDC_REDIRECTURL", "https://monitor.example.com/auth/callback")
+
+		resp := do(http.MethodPost, "/web/config/reload")
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		status := api.ReloadStatus{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		assert.Equal(t, []string{"auth"}, status.RestartRequired)
+	})
+	t.Run("viewers can't reload", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, baseUrl+"/web/config/reload", nil)
+		request.Header.Set("Authorization", "Bearer viewer-token")
+
+		resp, err := http.DefaultClient.Do(request)
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestLoadHistoryOnce(t *testing.T) {
+	simulator := test.NewSimulator(t)
+	root := simulator.CreateDID(t)
+	organization := simulator.CreateDID(t, root)
+	for i := 0; i < 248; i++ {
+		simulator.AddTransaction(t, organization, "application/vc+json", time.Now())
+	}
+	httpClient, err := client.NewHTTPClient(config.Config{NutsNodeAddr: simulator.URL()})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("ok", func(t *testing.T) {
+		store := data.NewStore(httpClient)
+
+		offset, err := loadHistoryOnce(ctx, store, httpClient, 0)
+
+		require.NoError(t, err)
+		assert.Equal(t, 300, offset)
+		counts, roots := store.GetTransactionCounts()
+		assert.Equal(t, uint32(1), roots)
+		assert.Equal(t, map[string]uint32{root.ID: 250}, counts)
+	})
+	t.Run("continues with the batch that failed", func(t *testing.T) {
+		store := data.NewStore(httpClient)
+		simulator.Fail("/internal/network/v1/transaction", test.MalformedJSON())
+		defer simulator.Reset()
+
+		offset, err := loadHistoryOnce(ctx, store, httpClient, 100)
+
+		assert.Error(t, err)
+		assert.Equal(t, 100, offset)
+		simulator.Reset()
+		offset, err = loadHistoryOnce(ctx, store, httpClient, offset)
+		require.NoError(t, err)
+		assert.Equal(t, 300, offset)
+		counts, _ := store.GetTransactionCounts()
+		assert.Equal(t, uint32(150), counts[root.ID])
+	})
+	t.Run("skips transactions that can't be parsed", func(t *testing.T) {
+		store := data.NewStore(httpClient)
+		valid := simulator.Transactions()[2].JWS
+		simulator.Fail("/internal/network/v1/transaction", test.Failure{Body: fmt.Sprintf(`["invalid", "%s"]`, valid), Times: 1})
+		defer simulator.Reset()
+
+		offset, err := loadHistoryOnce(ctx, store, httpClient, 0)
+
+		require.NoError(t, err)
+		assert.Equal(t, 300, offset)
+		counts, roots := store.GetTransactionCounts()
+		assert.Equal(t, uint32(1), roots)
+		// the valid transaction of the first batch and the 150 transactions of the next batches
+		assert.Equal(t, map[string]uint32{root.ID: 151}, counts)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		timeoutClient, err := client.NewHTTPClient(config.Config{NutsNodeAddr: simulator.URL(), NutsNodeTimeout: 20 * time.Millisecond})
+		require.NoError(t, err)
+		simulator.SetLatency("/internal/network/v1/transaction", time.Second)
+		defer simulator.Reset()
+
+		offset, err := loadHistoryOnce(ctx, data.NewStore(timeoutClient), timeoutClient, 0)
+
+		assert.ErrorContains(t, err, "Client.Timeout exceeded")
+		assert.Equal(t, 0, offset)
+	})
+}
+
+func TestSubscribe(t *testing.T) {
+	simulator := test.NewSimulator(t)
+	streamAddr := simulator.StartNATS(t)
+	signer := simulator.CreateDID(t)
+	conn, err := nats.Connect(streamAddr)
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	httpClient, err := client.NewHTTPClient(config.Config{NutsNodeAddr: simulator.URL()})
+	require.NoError(t, err)
+
+	store := data.NewStore(httpClient)
+	store.Reconfigure(config.Config{ClockSkewMaxFuture: time.Minute, ClockSkewMaxPast: time.Hour})
+
+	err = subscribe(ctx, conn, store)
+
+	require.NoError(t, err)
+	// malformed messages are skipped
+	require.NoError(t, conn.Publish("TRANSACTIONS.event", []byte("not an event")))
+	require.NoError(t, conn.Publish("TRANSACTIONS.jws", []byte(`{"transaction":"not a JWS"}`)))
+	simulator.AddTransaction(t, signer, "application/vc+json", time.Now())
+	// a transaction from the stream that was signed long before it arrived
+	simulator.AddTransaction(t, signer, "application/vc+json", time.Now().Add(-2*time.Hour))
+	js, err := conn.JetStream()
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		info, err := js.StreamInfo("nuts-monitor")
+		return err == nil && info.State.Msgs == 4 && info.State.Consumers == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		counts, _ := store.GetTransactionCounts()
+		return counts[signer.ID] == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, store.GetClockSkew().Past)
+}
+
+func TestMetrics(t *testing.T) {
+	ts := test.BasicTestNode(t)
+	os.Setenv("NUTS_NUTSNODEADDR", ts.URL())
+	httpPort := startServer(t)
+	baseUrl := fmt.Sprintf("http://localhost:%d", httpPort)
+	_, err := http.Get(fmt.Sprintf("%s/health", baseUrl))
+	require.NoError(t, err)
+
+	resp, err := http.Get(fmt.Sprintf("%s/metrics", baseUrl))
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), "# TYPE nuts_monitor_node_requests_total counter")
+	assert.Contains(t, string(body), `nuts_monitor_node_breaker_open{node="default"} 0`)
+}
+
+func TestInternalServer(t *testing.T) {
+	ts := test.BasicTestNode(t)
+	cfg := config.Config{
+		NutsNodeAddr: ts.URL(),
+		Server:       config.ServerConfig{InternalAddress: "localhost:0"},
+	}
+	node, err := newNode(cfg, "default")
+	require.NoError(t, err)
+	nodes := []api.Node{node}
+	watcher := config.NewWatcher(cfg)
+	public, err := newEchoServer(context.Background(), watcher, nodes)
+	require.NoError(t, err)
+	internal := newInternalServer(watcher, nodes)
+
+	get := func(e http.Handler, path string) int {
+		recorder := httptest.NewRecorder()
+		e.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder.Code
+	}
+
+	t.Run("status and health on internal server", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get(internal, "/status"))
+		assert.Equal(t, http.StatusOK, get(internal, "/health"))
+		assert.Equal(t, http.StatusOK, get(internal, "/metrics"))
+		assert.Equal(t, http.StatusNotFound, get(internal, "/web/transactions/counts"))
+	})
+	t.Run("not on public server", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get(public, "/status"))
+		assert.Equal(t, http.StatusNotFound, get(public, "/health"))
+		assert.Equal(t, http.StatusNotFound, get(public, "/metrics"))
+		assert.Equal(t, http.StatusOK, get(public, "/web/transactions/counts"))
+	})
+}
+
+// topologyTestNode returns a test node with two peers: "us" and "them"
+func topologyTestNode(t *testing.T) *test.Simulator {
+	simulator := test.NewSimulator(t)
+	simulator.PeerID = "us"
+	simulator.AddPeer(t, test.SimulatedPeer{ID: "them", Peers: []string{"us"}})
+	return simulator
+}
+
 func startServer(t *testing.T) int {
-	cfg := config.LoadConfig()
-	e := newEchoServer(cfg, nil)
+	watcher := config.NewWatcher(config.LoadConfig())
+	cfg := watcher.Current()
+	reloader := &nodeReloader{ctx: context.Background()}
+	for _, nodeConfig := range cfg.Nodes {
+		node, err := newNode(cfg.ForNode(nodeConfig), nodeConfig.Name)
+		require.NoError(t, err)
+		// the background processes aren't started
+		reloader.add(node, func() {})
+	}
+	nodes := reloader.nodes
+	watcher.OnReload(reloader.apply)
+	e, err := newEchoServer(context.Background(), watcher, nodes)
+	require.NoError(t, err)
 
 	httpPort := test.FreeTCPPort()
 
@@ -126,7 +651,7 @@ func startServer(t *testing.T) int {
 		err := e.Start(fmt.Sprintf(":%d", httpPort))
 		if err != nil {
 			if err.Error() != "http: Server closed" {
-				t.Fatal(err)
+				t.Error(err)
 			}
 		}
 	}()
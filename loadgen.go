@@ -0,0 +1,471 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"nuts-foundation/nuts-monitor/api"
+	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/data"
+	"nuts-foundation/nuts-monitor/mocknode"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/pflag"
+)
+
+// Targets of the load generator
+const (
+	loadgenTargetStore = "store"
+	loadgenTargetNATS  = "nats"
+)
+
+// loadgenProbeInterval is the time between two latency measurements of the API
+const loadgenProbeInterval = 100 * time.Millisecond
+
+// loadgenOptions configure the load generator, see parseLoadgenOptions for the defaults
+type loadgenOptions struct {
+	transactions int
+	dids         int
+	workers      int
+	target       string
+	interval     time.Duration
+	period       time.Duration
+}
+
+// loadgenReport contains the results of a run of the load generator
+type loadgenReport struct {
+	Generated int
+	Ingested  int
+	Duration  time.Duration
+	// IngestTime is the time spent parsing and storing transactions, it's only measured for the store target
+	IngestTime time.Duration
+	HeapAlloc  uint64
+	// APILatencies are the durations of the API requests done while ingesting
+	APILatencies []time.Duration
+}
+
+// runLoadgen runs the loadgen command and returns the exit code
+func runLoadgen(args []string) int {
+	options, err := parseLoadgenOptions(args)
+	if errors.Is(err, pflag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if _, err = loadgen(context.Background(), options, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "load generation failed: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func parseLoadgenOptions(args []string) (loadgenOptions, error) {
+	options := loadgenOptions{}
+	f := pflag.NewFlagSet("loadgen", pflag.ContinueOnError)
+	f.IntVar(&options.transactions, "transactions", 1000000, "Number of transactions to generate")
+	f.IntVar(&options.dids, "dids", 1000, "Number of DIDs that sign the transactions, about half of them are root DIDs")
+	f.IntVar(&options.workers, "workers", runtime.NumCPU(), "Number of Go routines that sign transactions")
+	f.StringVar(&options.target, "target", loadgenTargetStore, "Where the transactions go: 'store' adds them to the data store directly, 'nats' publishes them on an embedded NATS server the monitor subscribes to")
+	f.DurationVar(&options.interval, "interval", 5*time.Second, "Time between two progress reports")
+	f.DurationVar(&options.period, "period", time.Hour, "Signature times are spread over this period before now")
+	if err := f.Parse(args); err != nil {
+		return options, err
+	}
+	if options.transactions < 1 || options.dids < 1 || options.workers < 1 {
+		return options, errors.New("transactions, dids and workers must be at least 1")
+	}
+	if options.target != loadgenTargetStore && options.target != loadgenTargetNATS {
+		return options, fmt.Errorf("unknown target: %s", options.target)
+	}
+	if options.interval <= 0 || options.period <= 0 {
+		return options, errors.New("interval and period must be positive")
+	}
+	return options, nil
+}
+
+// loadgen generates signed transactions and feeds them to the data store of a monitored node, like the NATS consumer and history loader do.
+// It runs fully offline: the DID documents are resolved from the generators and the NATS server is embedded.
+// Progress is written to out every interval, the API is queried during the run to measure its latency.
+func loadgen(ctx context.Context, options loadgenOptions, out io.Writer) (loadgenReport, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// every worker signs with its own DIDs, so the workers don't wait for each other
+	generators := make([]*mocknode.Generator, options.workers)
+	for i := range generators {
+		generators[i] = mocknode.NewGenerator(int64(i+1), (options.dids+options.workers-1)/options.workers)
+	}
+
+	// the store resolves the signers of the transactions through a VDR API that serves the DID documents of the generators
+	vdrURL, err := serveLoadgen(ctx, vdrHandler(generators))
+	if err != nil {
+		return loadgenReport{}, err
+	}
+	cfg := config.Config{NutsNodeAddr: vdrURL}
+	node, err := newNode(cfg, "loadgen")
+	if err != nil {
+		return loadgenReport{}, err
+	}
+	node.DataStore.Start(ctx)
+
+	// the API is served while ingesting, so the latency includes waiting for the store
+	e := echo.New()
+	e.HideBanner = true
+	api.RegisterHandlers(e, api.NewStrictHandler(api.Wrapper{Config: config.NewWatcher(cfg), Nodes: []api.Node{node}}, nil))
+	apiURL, err := serveLoadgen(ctx, e)
+	if err != nil {
+		return loadgenReport{}, err
+	}
+	probe := &latencyProbe{urls: []string{apiURL + "/web/transactions/aggregated", apiURL + "/web/transactions/counts"}}
+
+	ingest, wait, err := loadgenTarget(ctx, options.target, node.DataStore)
+	if err != nil {
+		return loadgenReport{}, err
+	}
+
+	start := time.Now()
+	transactions := make(chan loadgenTransaction, 1024)
+	var generated atomic.Int64
+	generateErr := make(chan error, options.workers)
+	workers := sync.WaitGroup{}
+	for i, generator := range generators {
+		workers.Add(1)
+		go func(generator *mocknode.Generator, seed int64) {
+			defer workers.Done()
+			random := mathrand.New(mathrand.NewSource(seed))
+			for int(generated.Add(1)) <= options.transactions {
+				sigTime := start.Add(-time.Duration(random.Int63n(int64(options.period))))
+				jws, payload, err := generator.Next(sigTime)
+				if err != nil {
+					generateErr <- err
+					return
+				}
+				select {
+				case transactions <- loadgenTransaction{jws: jws, payload: payload}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(generator, int64(i+1))
+	}
+	go func() {
+		workers.Wait()
+		close(transactions)
+	}()
+
+	// a single Go routine ingests the transactions, like the NATS consumer
+	ingestDone := make(chan error, 1)
+	var ingestTime time.Duration
+	go func() {
+		for transaction := range transactions {
+			begin := time.Now()
+			if err := ingest(transaction); err != nil {
+				ingestDone <- err
+				return
+			}
+			ingestTime += time.Since(begin)
+		}
+		ingestDone <- nil
+	}()
+
+	ticker := time.NewTicker(options.interval)
+	defer ticker.Stop()
+	probeTicker := time.NewTicker(loadgenProbeInterval)
+	defer probeTicker.Stop()
+	lastIngested, lastReport := 0, start
+	for done := false; !done; {
+		select {
+		case err = <-generateErr:
+			return loadgenReport{}, fmt.Errorf("failed to generate transaction: %w", err)
+		case err = <-ingestDone:
+			if err != nil {
+				return loadgenReport{}, err
+			}
+			done = true
+		case <-probeTicker.C:
+			probe.measure(ctx)
+		case now := <-ticker.C:
+			ingested := ingestedCount(node.DataStore)
+			latencies := probe.since(lastReport)
+			fmt.Fprintf(out, "%6s generated %d, ingested %d (%.0f tx/s), heap %s, API p50 %s, p99 %s, max %s\n",
+				now.Sub(start).Round(time.Second), min(int(generated.Load()), options.transactions), ingested,
+				float64(ingested-lastIngested)/now.Sub(lastReport).Seconds(), formatBytes(heapAlloc(false)),
+				percentile(latencies, 0.5), percentile(latencies, 0.99), percentile(latencies, 1))
+			lastIngested, lastReport = ingested, now
+		}
+	}
+	// published messages may still be on their way
+	wait(func() int { return ingestedCount(node.DataStore) }, options.transactions)
+	probe.measure(ctx)
+
+	report := loadgenReport{
+		Generated:    options.transactions,
+		Ingested:     ingestedCount(node.DataStore),
+		Duration:     time.Since(start),
+		HeapAlloc:    heapAlloc(true),
+		APILatencies: probe.since(time.Time{}),
+	}
+	if options.target == loadgenTargetStore {
+		report.IngestTime = ingestTime
+	}
+	report.print(out)
+	return report, nil
+}
+
+// loadgenTransaction is a generated transaction in compact JWS format with its payload
+type loadgenTransaction struct {
+	jws     string
+	payload []byte
+}
+
+// loadgenTarget returns the function that ingests a transaction for the given target and a function that waits until the ingested count reaches the expected count
+func loadgenTarget(ctx context.Context, target string, store *data.Store) (func(loadgenTransaction) error, func(func() int, int), error) {
+	if target == loadgenTargetStore {
+		return func(generated loadgenTransaction) error {
+			transaction, err := data.FromJWS(generated.jws)
+			if err != nil {
+				return fmt.Errorf("failed to parse transaction: %w", err)
+			}
+			// like a transaction from the NATS stream, so the store doesn't fetch the payload
+			transaction.Payload = generated.payload
+			store.Add(*transaction)
+			return nil
+		}, func(func() int, int) {}, nil
+	}
+
+	streamURL, err := startLoadgenNATS(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := nats.Connect(streamURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to NATS stream: %w", err)
+	}
+	context.AfterFunc(ctx, conn.Close)
+	if err = subscribe(ctx, conn, store); err != nil {
+		return nil, nil, err
+	}
+	js, err := conn.JetStream(nats.PublishAsyncMaxPending(256))
+	if err != nil {
+		return nil, nil, err
+	}
+	publish := func(generated loadgenTransaction) error {
+		message, _ := json.Marshal(transactionEvent{Transaction: generated.jws, Payload: base64.StdEncoding.EncodeToString(generated.payload)})
+		ref := sha256.Sum256([]byte(generated.jws))
+		if _, err := js.PublishAsync("TRANSACTIONS."+hex.EncodeToString(ref[:]), message); err != nil {
+			return fmt.Errorf("failed to publish transaction: %w", err)
+		}
+		return nil
+	}
+	// the stream discards the oldest messages when the consumer can't keep up, so stop waiting when no more messages arrive
+	wait := func(ingested func() int, expected int) {
+		<-js.PublishAsyncComplete()
+		last := -1
+		for count := ingested(); count < expected && count != last; count = ingested() {
+			last = count
+			time.Sleep(time.Second)
+		}
+	}
+	return publish, wait, nil
+}
+
+// startLoadgenNATS starts an embedded NATS server with JetStream on a random port, it stops when the context is cancelled
+func startLoadgenNATS(ctx context.Context) (string, error) {
+	storeDir, err := os.MkdirTemp("", "nuts-monitor-loadgen")
+	if err != nil {
+		return "", err
+	}
+	server, err := natsserver.NewServer(&natsserver.Options{
+		Host:      "127.0.0.1",
+		Port:      natsserver.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  storeDir,
+		NoSigs:    true,
+		NoLog:     true,
+	})
+	if err != nil {
+		_ = os.RemoveAll(storeDir)
+		return "", err
+	}
+	server.Start()
+	context.AfterFunc(ctx, func() {
+		server.Shutdown()
+		server.WaitForShutdown()
+		_ = os.RemoveAll(storeDir)
+	})
+	if !server.ReadyForConnections(5 * time.Second) {
+		return "", errors.New("NATS server didn't start")
+	}
+	return server.ClientURL(), nil
+}
+
+// vdrHandler serves the DID documents of the generators like the VDR API of a Nuts node
+func vdrHandler(generators []*mocknode.Generator) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /internal/vdr/v1/did/{did}", func(w http.ResponseWriter, r *http.Request) {
+		for _, generator := range generators {
+			if result, ok := generator.Resolve(r.PathValue("did")); ok {
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(result)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	return mux
+}
+
+// serveLoadgen serves the handler on a random local port until the context is cancelled and returns its URL
+func serveLoadgen(ctx context.Context, handler http.Handler) (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("load generator HTTP server stopped: %s", err)
+		}
+	}()
+	context.AfterFunc(ctx, func() { _ = server.Close() })
+	return "http://" + listener.Addr().String(), nil
+}
+
+// latencyProbe measures the duration of API requests
+type latencyProbe struct {
+	urls    []string
+	mutex   sync.Mutex
+	samples []latencySample
+}
+
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// measure requests all URLs once, failed requests are logged and not measured
+func (p *latencyProbe) measure(ctx context.Context) {
+	for _, url := range p.urls {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			log.Printf("invalid API request: %s", err)
+			continue
+		}
+		begin := time.Now()
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			log.Printf("API request failed: %s", err)
+			continue
+		}
+		_, _ = io.Copy(io.Discard, response.Body)
+		_ = response.Body.Close()
+		duration := time.Since(begin)
+		if response.StatusCode != http.StatusOK {
+			log.Printf("API request to %s returned %d", url, response.StatusCode)
+			continue
+		}
+		p.mutex.Lock()
+		p.samples = append(p.samples, latencySample{at: begin, duration: duration})
+		p.mutex.Unlock()
+	}
+}
+
+// since returns the durations of the requests started after the given time
+func (p *latencyProbe) since(t time.Time) []time.Duration {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var result []time.Duration
+	for _, sample := range p.samples {
+		if !sample.at.Before(t) {
+			result = append(result, sample.duration)
+		}
+	}
+	return result
+}
+
+func (r loadgenReport) print(out io.Writer) {
+	fmt.Fprintf(out, "\ningested %d of %d transactions in %s: %.0f tx/s\n", r.Ingested, r.Generated, r.Duration.Round(time.Millisecond),
+		float64(r.Ingested)/r.Duration.Seconds())
+	if r.Ingested < r.Generated {
+		fmt.Fprintf(out, "dropped %d transactions, the consumer couldn't keep up\n", r.Generated-r.Ingested)
+	}
+	if r.IngestTime > 0 {
+		fmt.Fprintf(out, "parsing and storing takes %s per transaction\n", (r.IngestTime / time.Duration(r.Generated)).Round(100*time.Nanosecond))
+	}
+	fmt.Fprintf(out, "heap after GC: %s\n", formatBytes(r.HeapAlloc))
+	fmt.Fprintf(out, "API latency of %d requests: p50 %s, p99 %s, max %s\n", len(r.APILatencies),
+		percentile(r.APILatencies, 0.5), percentile(r.APILatencies, 0.99), percentile(r.APILatencies, 1))
+}
+
+// ingestedCount returns the number of transactions in the store, every transaction is counted for its root DID
+func ingestedCount(store *data.Store) int {
+	counts, _ := store.GetTransactionCounts()
+	total := 0
+	for _, count := range counts {
+		total += int(count)
+	}
+	return total
+}
+
+// heapAlloc returns the bytes of allocated heap objects, optionally after a garbage collection
+func heapAlloc(collect bool) uint64 {
+	if collect {
+		runtime.GC()
+	}
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// percentile returns the p-th percentile (0-1) of the durations, rounded to microseconds
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(p*float64(len(sorted)-1))].Round(time.Microsecond)
+}
+
+// formatBytes formats a number of bytes in MiB
+func formatBytes(b uint64) string {
+	return fmt.Sprintf("%.1f MiB", float64(b)/(1<<20))
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadgen(t *testing.T) {
+	for _, target := range []string{loadgenTargetStore, loadgenTargetNATS} {
+		t.Run(target, func(t *testing.T) {
+			options := loadgenOptions{transactions: 200, dids: 10, workers: 2, target: target, interval: 10 * time.Millisecond, period: time.Hour}
+			out := &bytes.Buffer{}
+
+			report, err := loadgen(context.Background(), options, out)
+
+			require.NoError(t, err)
+			assert.Equal(t, 200, report.Generated)
+			assert.Equal(t, 200, report.Ingested)
+			assert.NotEmpty(t, report.APILatencies)
+			assert.Positive(t, report.HeapAlloc)
+			assert.Contains(t, out.String(), "ingested 200 of 200 transactions")
+		})
+	}
+}
+
+func TestParseLoadgenOptions(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		options, err := parseLoadgenOptions(nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1000000, options.transactions)
+		assert.Equal(t, 1000, options.dids)
+		assert.Equal(t, loadgenTargetStore, options.target)
+	})
+	t.Run("help", func(t *testing.T) {
+		_, err := parseLoadgenOptions([]string{"--help"})
+
+		assert.ErrorIs(t, err, pflag.ErrHelp)
+	})
+	t.Run("unknown target", func(t *testing.T) {
+		_, err := parseLoadgenOptions([]string{"--target", "kafka"})
+
+		assert.EqualError(t, err, "unknown target: kafka")
+	})
+	t.Run("no transactions", func(t *testing.T) {
+		_, err := parseLoadgenOptions([]string{"--transactions", "0"})
+
+		assert.EqualError(t, err, "transactions, dids and workers must be at least 1")
+	})
+}
+
+func TestPercentile(t *testing.T) {
+	durations := []time.Duration{4 * time.Millisecond, time.Millisecond, 3 * time.Millisecond, 2 * time.Millisecond}
+
+	assert.Equal(t, time.Duration(0), percentile(nil, 0.5))
+	assert.Equal(t, 2*time.Millisecond, percentile(durations, 0.5))
+	assert.Equal(t, 4*time.Millisecond, percentile(durations, 1))
+}
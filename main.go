@@ -21,6 +21,7 @@ package main
 import (
 	"context"
 	"embed"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -29,9 +30,12 @@ import (
 	"log"
 	"net/http"
 	"nuts-foundation/nuts-monitor/api"
+	"nuts-foundation/nuts-monitor/auth"
 	"nuts-foundation/nuts-monitor/client"
 	"nuts-foundation/nuts-monitor/config"
 	"nuts-foundation/nuts-monitor/data"
+	"nuts-foundation/nuts-monitor/mocknode"
+	"nuts-foundation/nuts-monitor/server"
 	"os"
 	"path"
 	"time"
@@ -46,71 +50,144 @@ const assetPath = "web"
 var embeddedFiles embed.FS
 
 func main() {
-	// first load the config
-	config := config.LoadConfig()
-	config.Print(log.Writer())
-
-	// create the Node API Client
-	client := client.HTTPClient{
-		Config: config,
+	// the load generator is a separate command, it doesn't use the config
+	if len(os.Args) > 1 && os.Args[1] == "loadgen" {
+		os.Exit(runLoadgen(os.Args[2:]))
 	}
 
-	// then initialize the data storage and fill it with the initial transactions
+	// first load the config, the watcher reloads it when it changes
+	watcher := config.NewWatcher(config.LoadConfig())
+	config := watcher.Current()
+	config.Print(log.Writer())
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	store := data.NewStore(client)
-	// connect to the NATS stream of the nuts node
-	startConsumer(ctx, store, config)
-	// load history async
-	loadHistory(ctx, store, config)
-	// start shifting windows
-	store.Start(ctx)
+
+	// start the mock node before the clients connect to it, the config already points to it
+	if config.WithMockNode {
+		if _, err := mocknode.Start(ctx, config.MockNode); err != nil {
+			log.Fatalf("failed to start the mock node: %s", err)
+		}
+	}
+
+	// initialize the clients and data storage per node and fill them with the initial transactions
+	reloader := &nodeReloader{ctx: ctx}
+	for _, nodeConfig := range config.Nodes {
+		node, err := newNode(config.ForNode(nodeConfig), nodeConfig.Name)
+		if err != nil {
+			log.Fatalf("invalid client configuration for node %s: %s", nodeConfig.Name, err)
+		}
+		reloader.add(node, startNode(ctx, node))
+	}
+	nodes := reloader.nodes
+
+	// apply config changes to the nodes when the config file changes or on SIGHUP
+	watcher.OnReload(reloader.apply)
+	if err := watcher.Start(ctx); err != nil {
+		log.Printf("failed to watch the config file, the config is only reloaded on SIGHUP: %s", err)
+	}
 
 	// start the web server
-	e := newEchoServer(config, store)
+	e, err := newEchoServer(ctx, watcher, nodes)
+	if err != nil {
+		log.Fatalf("failed to setup the HTTP server: %s", err)
+	}
+
+	// Start the internal server for the status, health and metrics endpoints, if configured
+	if config.Server.InternalAddress != "" {
+		internal := newInternalServer(watcher, nodes)
+		go func() {
+			internal.Logger.Fatal(internal.Start(config.Server.InternalAddress))
+		}()
+	}
 
 	// Start server
-	e.Logger.Fatal(e.Start(fmt.Sprintf(":%d", 1313)))
+	e.Logger.Fatal(serve(e, config.Server))
 }
 
-// loadHistory uses a Go routine to load the transactions in the background
-// On error it will retry every 10 seconds
-func loadHistory(context context.Context, store *data.Store, c config.Config) {
-	// initialize the client
-	client := client.HTTPClient{
-		Config: c,
+// serve starts the HTTP server, using TLS if a certificate is configured
+func serve(e *echo.Echo, c config.ServerConfig) error {
+	if !c.TLS.Enabled() {
+		return e.Start(c.Address)
+	}
+	tlsConfig, err := server.TLSConfig(c.TLS)
+	if err != nil {
+		return err
+	}
+	e.TLSServer.Addr = c.Address
+	e.TLSServer.TLSConfig = tlsConfig
+	return e.StartServer(e.TLSServer)
+}
+
+// newNode creates the client, store and consistency checker for a single node.
+// The client is shared by all components, so they reuse its connections and API tokens.
+func newNode(c config.Config, name string) (api.Node, error) {
+	// create the Node API Client
+	client, err := client.NewHTTPClient(c)
+	if err != nil {
+		return api.Node{}, err
 	}
 
+	store := data.NewStore(client)
+	store.Reconfigure(c)
+
+	return api.Node{
+		Name:        name,
+		Client:      client,
+		DataStore:   store,
+		Consistency: data.NewConsistencyChecker(client, c.ConsistencyInterval, c.ConsistencyThreshold, c.ConsistencyGracePeriod),
+	}, nil
+}
+
+// startNode starts the background processes that collect data from a node.
+// It returns the function that stops the NATS consumer, so it can be restarted when the stream address changes.
+func startNode(ctx context.Context, node api.Node) context.CancelFunc {
+	// requests of background processes must not delay requests from users of the API
+	background := client.WithPriority(ctx, client.PriorityBackground)
+	// connect to the NATS stream of the nuts node
+	stopConsumer := startConsumer(ctx, node.DataStore, node.Client.CurrentConfig())
+	// load history async
+	loadHistory(background, node.DataStore, node.Client)
+	// start shifting windows
+	node.DataStore.Start(ctx)
+	// start comparing the DAG with our peers
+	node.Consistency.Start(background)
+	return stopConsumer
+}
+
+// reconnectBackoff is used when loading the history or connecting to the NATS stream fails
+var reconnectBackoff = client.Backoff{Initial: time.Second, Max: time.Minute}
+
+// loadHistory uses a Go routine to load the transactions in the background
+// On error it will retry with an exponential backoff, continuing with the batch that failed
+func loadHistory(ctx context.Context, store *data.Store, client client.HTTPClient) {
 	go func() {
-		// As long there's no error, keep retrying
-		for {
-			err := loadHistoryOnce(store, client)
-			select {
-			case <-context.Done():
+		offset := 0
+		for retry := 0; ; retry++ {
+			var err error
+			offset, err = loadHistoryOnce(ctx, store, client, offset)
+			if err == nil {
+				return
+			}
+			log.Printf("failed to load historic transactions: %s", err)
+			if !reconnectBackoff.Sleep(ctx, retry) {
 				return
-			default:
-				if err == nil {
-					return
-				}
-				log.Printf("failed to load historic transactions: %s", err)
-				log.Printf("retrying in 10 seconds")
-				// sleep for 10 seconds
-				<-time.After(10 * time.Second)
 			}
 		}
 	}()
 }
 
-// loadHistoryOnce loads the transactions from the Nuts node and stores them in the data store
-func loadHistoryOnce(store *data.Store, client client.HTTPClient) error {
+// loadHistoryOnce loads the transactions from the Nuts node, starting at the given offset, and stores them in the data store
+// It returns the offset of the next batch to load
+func loadHistoryOnce(ctx context.Context, store *data.Store, client client.HTTPClient, offset int) (int, error) {
 	// load the initial transactions
 	// ListTransactions per batch of 100, stop if the list is empty
 	// currentOffset is used to determine the offset for the next batch
-	currentOffset := 0
+	currentOffset := offset
 	for {
-		transactions, err := client.ListTransactions(context.Background(), currentOffset, currentOffset+100)
+		transactions, err := client.ListTransactions(ctx, currentOffset, currentOffset+100)
 		if err != nil {
-			return err
+			return currentOffset, err
 		}
 		if len(transactions) == 0 {
 			break
@@ -120,35 +197,34 @@ func loadHistoryOnce(store *data.Store, client client.HTTPClient) error {
 			transaction, err := data.FromJWS(stringTransaction)
 			if err != nil {
 				log.Printf("failed to parse transaction: %s", err)
+				continue
 			}
 			store.Add(*transaction)
 		}
 		// increase offset for next batch
 		currentOffset += 100
 	}
-	return nil
+	return currentOffset, nil
 }
 
-// startConsumer will try to subscribe to NATS every 10 seconds
-// it will retry until it succeeds
-func startConsumer(ctx context.Context, store *data.Store, c config.Config) {
+// startConsumer will try to subscribe to NATS
+// it will retry with an exponential backoff until it succeeds
+// The returned function stops the consumer and closes the connection.
+func startConsumer(ctx context.Context, store *data.Store, c config.Config) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
 	go func() {
-		for {
+		for retry := 0; ; retry++ {
 			err := startConsumerOnce(ctx, store, c)
-			select {
-			case <-ctx.Done():
+			if err == nil {
+				return
+			}
+			log.Printf("failed to start NATS consumer: %s", err)
+			if !reconnectBackoff.Sleep(ctx, retry) {
 				return
-			default:
-				if err == nil {
-					return
-				}
-				log.Printf("failed to start NATS consumer: %s", err)
-				log.Printf("retrying in 10 seconds")
-				// sleep for 10 seconds
-				<-time.After(10 * time.Second)
 			}
 		}
 	}()
+	return cancel
 }
 
 // startConsumerOnce starts the NATS consumer
@@ -160,6 +236,20 @@ func startConsumerOnce(ctx context.Context, store *data.Store, c config.Config)
 	if err != nil {
 		return fmt.Errorf("failed to connect to NATS stream: %w", err)
 	}
+	if err = subscribe(ctx, conn, store); err != nil {
+		conn.Close()
+		return err
+	}
+	// the connection is closed when the consumer is stopped
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	return nil
+}
+
+// subscribe creates the stream if it doesn't exist and subscribes to it
+func subscribe(ctx context.Context, conn *nats.Conn, store *data.Store) error {
 	// setup NATS JetStream
 	js, err := conn.JetStream()
 	if err != nil {
@@ -198,10 +288,18 @@ func startConsumerOnce(ctx context.Context, store *data.Store, c config.Config)
 		err := json.Unmarshal(msg.Data, &event)
 		if err != nil {
 			log.Printf("failed to parse transaction event: %s", err)
+			return
 		}
 		transaction, err := data.FromJWS(event.Transaction)
 		if err != nil {
 			log.Printf("failed to parse transaction: %s", err)
+			return
+		}
+		// the arrival time is used to detect signers with a skewed clock
+		transaction.Received = time.Now()
+		// the payload saves fetching DID documents from the node
+		if payload, err := base64.StdEncoding.DecodeString(event.Payload); err == nil && len(payload) > 0 {
+			transaction.Payload = payload
 		}
 		// add transaction to store
 		store.Add(*transaction)
@@ -220,25 +318,45 @@ type transactionEvent struct {
 	Payload string `json:"payload"`
 }
 
-func newEchoServer(config config.Config, store *data.Store) *echo.Echo {
+func newEchoServer(ctx context.Context, watcher *config.Watcher, nodes []api.Node) (*echo.Echo, error) {
+	config := watcher.Current()
 	// http server
 	e := echo.New()
 	e.HideBanner = true
 	loggerConfig := middleware.DefaultLoggerConfig
 	e.Use(middleware.LoggerWithConfig(loggerConfig))
 
-	// add status endpoint
-	e.GET("/status", func(context echo.Context) error {
-		return context.String(http.StatusOK, "OK")
-	})
+	// authentication of the API, only enabled when configured
+	if config.Auth.Enabled() {
+		authenticators, oidcAuthenticator, err := auth.FromConfig(ctx, config.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup authentication: %w", err)
+		}
+		if oidcAuthenticator != nil {
+			oidcAuthenticator.RegisterRoutes(e)
+		}
+		e.Use(auth.Middleware(func(c echo.Context) bool { return !auth.Protected(c) }, authenticators...))
+	}
 
 	// API endpoints from OAS spec
 	apiWrapper := api.Wrapper{
-		Config: config,
-		Client: client.HTTPClient{
-			Config: config,
-		},
-		DataStore: store,
+		Config: watcher,
+		Nodes:  nodes,
+	}
+
+	// the status, health and metrics endpoints are only served by the internal server if it's configured
+	if config.Server.InternalAddress == "" {
+		e.GET("/status", status)
+		e.GET("/metrics", apiWrapper.Metrics)
+	} else {
+		e.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if c.Request().URL.Path == "/health" || c.Request().URL.Path == "/metrics" {
+					return echo.ErrNotFound
+				}
+				return next(c)
+			}
+		})
 	}
 	api.RegisterHandlers(e, api.NewStrictHandler(apiWrapper, []api.StrictMiddlewareFunc{}))
 
@@ -248,9 +366,27 @@ func newEchoServer(config config.Config, store *data.Store) *echo.Echo {
 	assetHandler := http.FileServer(getFileSystem(useFS))
 	e.GET("/*", echo.WrapHandler(assetHandler))
 
+	return e, nil
+}
+
+// newInternalServer creates the server for the status, health and metrics endpoints, so they can be exposed on an internal interface only
+func newInternalServer(watcher *config.Watcher, nodes []api.Node) *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+
+	apiWrapper := api.Wrapper{Config: watcher, Nodes: nodes}
+	e.GET("/status", status)
+	e.GET("/metrics", apiWrapper.Metrics)
+	handler := api.NewStrictHandler(apiWrapper, []api.StrictMiddlewareFunc{})
+	e.GET("/health", handler.CheckHealth)
+
 	return e
 }
 
+func status(context echo.Context) error {
+	return context.String(http.StatusOK, "OK")
+}
+
 func getFileSystem(useFS bool) http.FileSystem {
 	if useFS {
 		log.Print("using live mode")
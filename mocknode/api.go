@@ -0,0 +1,285 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package mocknode
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"nuts-foundation/nuts-monitor/client/diagnostics"
+	"nuts-foundation/nuts-monitor/client/network"
+)
+
+// Peer is a peer of a mock Nuts node, it's reported in the diagnostics and the peer diagnostics
+type Peer struct {
+	// ID is the peer ID
+	ID string
+	// Address is the address of the peer
+	Address string
+	// NodeDID is the DID of the peer, the connection is authenticated if set
+	NodeDID string
+	// TransactionCount is the number of transactions on the DAG of the peer
+	TransactionCount int
+	// Peers contains the IDs of the peers of the peer
+	Peers []string
+	// SoftwareVersion is the version of the Nuts node of the peer
+	SoftwareVersion string
+	// Certificate is the PEM encoded TLS certificate of the peer
+	Certificate string
+}
+
+// Network returns the peer ID of a mock Nuts node and its peers, it's called for every request that reports them
+type Network func() (string, []Peer)
+
+// api serves the transactions and DID documents of a DAG like the Nuts node API does
+type api struct {
+	dag             *DAG
+	softwareVersion string
+	started         time.Time
+	network         Network
+}
+
+// NewHandler returns the handler of the Nuts node API endpoints the monitor uses, for the transactions and DID documents of the DAG
+// and the peers of the network. The software version is reported as version of the node.
+func NewHandler(dag *DAG, softwareVersion string, network Network) http.Handler {
+	a := &api{dag: dag, softwareVersion: softwareVersion, started: time.Now(), network: network}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", a.health)
+	mux.HandleFunc("GET /status/diagnostics", a.diagnostics)
+	mux.HandleFunc("GET /internal/network/v1/transaction", a.listTransactions)
+	mux.HandleFunc("GET /internal/network/v1/transaction/{ref}", a.getTransaction)
+	mux.HandleFunc("GET /internal/network/v1/transaction/{ref}/payload", a.getPayload)
+	mux.HandleFunc("GET /internal/network/v1/diagnostics/peers", a.peerDiagnostics)
+	mux.HandleFunc("GET /internal/network/v1/diagnostics/graph", a.renderGraph)
+	mux.HandleFunc("GET /internal/network/v1/addressbook", a.addressBook)
+	mux.HandleFunc("GET /internal/network/v1/events", a.listEvents)
+	mux.HandleFunc("GET /internal/vdr/v1/did/{did}", a.getDID)
+	return mux
+}
+
+func (a *api) health(w http.ResponseWriter, _ *http.Request) {
+	up := diagnostics.HealthCheckResult{Status: "UP"}
+	writeJSON(w, http.StatusOK, diagnostics.Health{
+		Status: "UP",
+		Details: map[string]diagnostics.HealthCheckResult{
+			"crypto.filesystem":   up,
+			"network.tls":         up,
+			"network.auth_config": up,
+		},
+	})
+}
+
+func (a *api) diagnostics(w http.ResponseWriter, _ *http.Request) {
+	peerID, peers := a.network()
+	result := diagnostics.Diagnostics{}
+	result.Status = diagnostics.Status{
+		GitCommit:       a.softwareVersion,
+		OsArch:          runtime.GOOS + "/" + runtime.GOARCH,
+		SoftwareVersion: a.softwareVersion,
+		Uptime:          int(time.Since(a.started)),
+	}
+	result.Network.Connections.PeerId = peerID
+	result.Network.Connections.ConnectedPeersCount = len(peers)
+	result.Network.Connections.ConnectedPeers = []diagnostics.ConnectedPeer{}
+	for _, p := range peers {
+		connected := diagnostics.ConnectedPeer{Id: p.ID, Address: p.Address, Authenticated: p.NodeDID != ""}
+		if p.NodeDID != "" {
+			nodeDID := p.NodeDID
+			connected.Nodedid = &nodeDID
+		}
+		result.Network.Connections.ConnectedPeers = append(result.Network.Connections.ConnectedPeers, connected)
+	}
+	count := a.dag.count()
+	result.Network.State.TransactionCount = count
+	result.Network.State.DagLcHigh = max(count-1, 0)
+	result.Network.State.DagXor = a.dag.xor()
+	signers := a.dag.signerIDs()
+	if len(signers) > 0 {
+		result.Network.NodeDid = &signers[0]
+	}
+	result.Vdr.DidDocumentsCount = len(signers)
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (a *api) listTransactions(w http.ResponseWriter, r *http.Request) {
+	start, end, err := lcRange(r, a.dag.count())
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	result := []string{}
+	for _, tx := range a.dag.list(start, end) {
+		result = append(result, tx.JWS)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (a *api) getTransaction(w http.ResponseWriter, r *http.Request) {
+	tx, ok := a.dag.get(r.PathValue("ref"))
+	if !ok {
+		writeProblem(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/jose")
+	_, _ = w.Write([]byte(tx.JWS))
+}
+
+func (a *api) getPayload(w http.ResponseWriter, r *http.Request) {
+	tx, ok := a.dag.get(r.PathValue("ref"))
+	if !ok {
+		writeProblem(w, http.StatusNotFound, "transaction not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(tx.Payload)
+}
+
+func (a *api) peerDiagnostics(w http.ResponseWriter, _ *http.Request) {
+	_, peers := a.network()
+	uptime := float32(time.Since(a.started).Seconds())
+	result := map[string]network.PeerDiagnostics{}
+	for _, p := range peers {
+		transactionNum := float32(p.TransactionCount)
+		diagnostics := network.PeerDiagnostics{
+			Address:         &p.Address,
+			Peers:           &p.Peers,
+			SoftwareVersion: &p.SoftwareVersion,
+			TransactionNum:  &transactionNum,
+			Uptime:          &uptime,
+		}
+		if p.Peers == nil {
+			diagnostics.Peers = &[]string{}
+		}
+		if p.NodeDID != "" {
+			diagnostics.NodeDID = &p.NodeDID
+		}
+		if p.SoftwareVersion != "" {
+			diagnostics.SoftwareID = &p.SoftwareVersion
+		}
+		if p.Certificate != "" {
+			diagnostics.Certificate = &p.Certificate
+		}
+		result[p.ID] = diagnostics
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// renderGraph renders the DAG in DOT format, like the Nuts node does
+func (a *api) renderGraph(w http.ResponseWriter, r *http.Request) {
+	start, end, err := lcRange(r, a.dag.count())
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	builder := strings.Builder{}
+	builder.WriteString("digraph {\n")
+	transactions := a.dag.list(start, end)
+	for _, tx := range transactions {
+		fmt.Fprintf(&builder, "  \"%s\"[shape=\"box\", label=\"%s\\nLC=%d\"];\n", tx.Ref, tx.ContentType, tx.LC)
+	}
+	for _, tx := range transactions {
+		// only render edges within the range, the previous transaction of the first one isn't rendered
+		if tx.LC == start {
+			continue
+		}
+		for _, prev := range tx.Prevs {
+			fmt.Fprintf(&builder, "  \"%s\" -> \"%s\";\n", prev, tx.Ref)
+		}
+	}
+	builder.WriteString("}\n")
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+	_, _ = w.Write([]byte(builder.String()))
+}
+
+func (a *api) addressBook(w http.ResponseWriter, _ *http.Request) {
+	_, peers := a.network()
+	result := []network.Contact{}
+	for _, p := range peers {
+		contact := network.Contact{Address: p.Address}
+		if p.NodeDID != "" {
+			contact.Did = &p.NodeDID
+		}
+		result = append(result, contact)
+	}
+	// a contact the node can't connect to
+	lastAttempt := time.Now().Add(-time.Minute)
+	nextAttempt := time.Now().Add(time.Minute)
+	connectionError := "connection refused"
+	result = append(result, network.Contact{
+		Address:     "unreachable.example.com:5555",
+		Attempts:    5,
+		Error:       &connectionError,
+		LastAttempt: &lastAttempt,
+		NextAttempt: &nextAttempt,
+	})
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (a *api) listEvents(w http.ResponseWriter, _ *http.Request) {
+	// all events are processed
+	writeJSON(w, http.StatusOK, []network.EventSubscriber{})
+}
+
+func (a *api) getDID(w http.ResponseWriter, r *http.Request) {
+	result, ok := a.dag.resolve(r.PathValue("did"))
+	if !ok {
+		writeProblem(w, http.StatusNotFound, "unable to find the DID document")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// lcRange parses the start and end query parameters, end defaults to the number of transactions
+func lcRange(r *http.Request, count int) (int, int, error) {
+	start, end := 0, count
+	var err error
+	if value := r.URL.Query().Get("start"); value != "" {
+		if start, err = strconv.Atoi(value); err != nil {
+			return 0, 0, fmt.Errorf("invalid start: %w", err)
+		}
+	}
+	if value := r.URL.Query().Get("end"); value != "" {
+		if end, err = strconv.Atoi(value); err != nil {
+			return 0, 0, fmt.Errorf("invalid end: %w", err)
+		}
+	}
+	return start, end, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeProblem writes an RFC 7807 problem, like the Nuts node does for errors
+func writeProblem(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"title":  http.StatusText(status),
+		"status": status,
+		"detail": detail,
+	})
+}
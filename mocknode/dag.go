@@ -0,0 +1,497 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package mocknode
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/mr-tron/base58"
+	ssi "github.com/nuts-foundation/go-did"
+	"github.com/nuts-foundation/go-did/did"
+	"nuts-foundation/nuts-monitor/client/common"
+	"nuts-foundation/nuts-monitor/client/vdr"
+)
+
+// Content types of the generated transactions
+const (
+	didDocumentType   = "application/did+json"
+	credentialType    = "application/vc+json"
+	revocationType    = "application/ld+json;type=revocation"
+	transactionFormat = 2
+)
+
+// defaultMaxSigners limits the number of DIDs the mock node creates
+const defaultMaxSigners = 25
+
+// Transaction is a signed transaction on a DAG
+type Transaction struct {
+	// Ref is the reference of the transaction, the SHA-256 hash of the JWS
+	Ref string
+	// JWS is the transaction in compacted JWS format
+	JWS string
+	// Payload is the detached payload of the transaction
+	Payload []byte
+	// LC is the Lamport Clock value of the transaction
+	LC int
+	// Prevs contains the references of the previous transactions
+	Prevs []string
+	// ContentType is the content type of the payload
+	ContentType string
+	// Signer is the DID that signed the transaction
+	Signer string
+	// SigTime is the signature time of the transaction
+	SigTime time.Time
+}
+
+// signer is a DID that signs transactions, with the metadata of its DID document
+type signer struct {
+	id    did.DID
+	keyID did.DIDURL
+	// keys contains all keys the DID had by key ID, also the ones that were rotated
+	keys     map[string]*ecdsa.PrivateKey
+	document did.Document
+	created  time.Time
+	updated  *time.Time
+	txs      []string
+	hash     string
+	prevHash *string
+}
+
+// DAG contains signed transactions and the DID documents of their signers. The transactions form a single chain, every transaction refers to the previous one.
+// The mock node generates random transactions, tests script them with CreateDID, UpdateDID, RotateKey and Sign.
+type DAG struct {
+	mutex        sync.RWMutex
+	rand         *mathrand.Rand
+	maxSigners   int
+	signers      []*signer
+	signersByDID map[string]*signer
+	// keep is false if only the last transaction is kept, the other fields below are only filled if it's true
+	keep         bool
+	last         *Transaction
+	transactions []Transaction
+	byRef        map[string]int
+}
+
+// NewDAG creates an empty DAG that keeps its transactions
+func NewDAG() *DAG {
+	return newDAG(time.Now().UnixNano(), defaultMaxSigners, true)
+}
+
+// newDAG creates a DAG that creates up to maxSigners DIDs. If keep is false, the transactions can't be listed.
+func newDAG(seed int64, maxSigners int, keep bool) *DAG {
+	return &DAG{
+		rand:         mathrand.New(mathrand.NewSource(seed)),
+		maxSigners:   maxSigners,
+		signersByDID: map[string]*signer{},
+		keep:         keep,
+		byRef:        map[string]int{},
+	}
+}
+
+// CreateDID adds a new DID document controlled by the given DIDs, or by itself if none are given.
+// The transaction that creates the document contains the public key of the DID.
+func (d *DAG) CreateDID(sigTime time.Time, controllers ...string) (Transaction, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	controllerDIDs := make([]did.DID, len(controllers))
+	for i, controller := range controllers {
+		id, err := did.ParseDID(controller)
+		if err != nil {
+			return Transaction{}, err
+		}
+		controllerDIDs[i] = *id
+	}
+	return d.createDID(sigTime, controllerDIDs)
+}
+
+// UpdateDID changes the DID document and adds the update, signed with the current key of the DID, to the DAG
+func (d *DAG) UpdateDID(id string, sigTime time.Time, update func(document *did.Document)) (Transaction, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	current, ok := d.signersByDID[id]
+	if !ok {
+		return Transaction{}, fmt.Errorf("unknown DID: %s", id)
+	}
+	return d.update(current, current.keyID.String(), sigTime, update)
+}
+
+// RotateKey replaces the key of the DID with a new key and adds the update of the document, signed with the old key, to the DAG.
+// The old key can still sign transactions with Sign.
+func (d *DAG) RotateKey(id string, sigTime time.Time) (Transaction, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	current, ok := d.signersByDID[id]
+	if !ok {
+		return Transaction{}, fmt.Errorf("unknown DID: %s", id)
+	}
+	key, method, err := newKey(&current.id)
+	if err != nil {
+		return Transaction{}, err
+	}
+	previous := current.keyID
+	current.keyID = method.ID
+	current.keys[method.ID.String()] = key
+	// the update is signed with the key that is in the current document
+	return d.update(current, previous.String(), sigTime, func(document *did.Document) {
+		document.VerificationMethod = nil
+		document.CapabilityInvocation = nil
+		document.AssertionMethod = nil
+		document.AddCapabilityInvocation(method)
+		document.AddAssertionMethod(method)
+	})
+}
+
+// Sign adds a transaction for the payload to the DAG, signed with the key of a DID. Keys that were rotated can sign too.
+func (d *DAG) Sign(keyID string, contentType string, payload []byte, sigTime time.Time) (Transaction, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	id, err := did.ParseDIDURL(keyID)
+	if err != nil {
+		return Transaction{}, err
+	}
+	current, ok := d.signersByDID[id.DID.String()]
+	if !ok {
+		return Transaction{}, fmt.Errorf("unknown DID: %s", id.DID)
+	}
+	return d.add(current, keyID, contentType, payload, sigTime, false)
+}
+
+// KeyID returns the ID of the current key of the DID, or an empty string if the DID isn't on the DAG
+func (d *DAG) KeyID(id string) string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	current, ok := d.signersByDID[id]
+	if !ok {
+		return ""
+	}
+	return current.keyID.String()
+}
+
+// Transactions returns the transactions on the DAG, ordered by LC value
+func (d *DAG) Transactions() []Transaction {
+	return d.list(0, d.count())
+}
+
+// generateHistory adds count transactions with signature times spread over the given period before now
+func (d *DAG) generateHistory(count int, period time.Duration, now time.Time) error {
+	d.mutex.Lock()
+	offsets := make([]time.Duration, count)
+	for i := range offsets {
+		offsets[i] = time.Duration(d.rand.Int63n(int64(period)))
+	}
+	d.mutex.Unlock()
+	// the signature times must increase with the LC value
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] > offsets[j] })
+	for _, offset := range offsets {
+		if _, err := d.next(now.Add(-offset)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next creates a new transaction signed at the given time: a new DID, an update of a DID document, a credential or a revocation
+func (d *DAG) next(sigTime time.Time) (Transaction, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if len(d.signers) == 0 || (len(d.signers) < d.maxSigners && d.rand.Intn(10) == 0) {
+		// half of the DIDs are controlled by another DID, like organizations that are controlled by their vendor
+		var controllers []did.DID
+		if len(d.signers) > 0 && d.rand.Intn(2) == 0 {
+			controllers = []did.DID{d.signers[d.rand.Intn(len(d.signers))].id}
+		}
+		return d.createDID(sigTime, controllers)
+	}
+	current := d.signers[d.rand.Intn(len(d.signers))]
+	switch n := d.rand.Intn(10); {
+	case n < 6:
+		return d.add(current, current.keyID.String(), credentialType, d.payload(credentialType, current), sigTime, false)
+	case n < 8:
+		return d.add(current, current.keyID.String(), revocationType, d.payload(revocationType, current), sigTime, false)
+	default:
+		// an update adds a service to the document
+		endpoint := fmt.Sprintf("grpc://node-%d.example.com:5555", d.rand.Intn(100))
+		return d.update(current, current.keyID.String(), sigTime, func(document *did.Document) {
+			serviceID := ssi.MustParseURI(fmt.Sprintf("%s#service-%d", current.id, len(document.Service)+1))
+			document.Service = append(document.Service, did.Service{ID: serviceID, Type: "NutsComm", ServiceEndpoint: endpoint})
+		})
+	}
+}
+
+// newKey generates a key for the DID, the key ID has the key thumbprint as fragment.
+// If id is nil, the DID is derived from the key like the Nuts node does.
+func newKey(id *did.DID) (*ecdsa.PrivateKey, *did.VerificationMethod, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKey, err := jwk.New(key.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	thumbprint, err := publicKey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, nil, err
+	}
+	if id == nil {
+		derived := did.MustParseDID("did:nuts:" + base58.Encode(thumbprint))
+		id = &derived
+	}
+	keyID := did.DIDURL{DID: *id, Fragment: base64.RawURLEncoding.EncodeToString(thumbprint)}
+	method, err := did.NewVerificationMethod(keyID, ssi.JsonWebKey2020, *id, key.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, method, nil
+}
+
+// createDID adds a new DID document, a DID without controllers controls itself
+func (d *DAG) createDID(sigTime time.Time, controllers []did.DID) (Transaction, error) {
+	key, method, err := newKey(nil)
+	if err != nil {
+		return Transaction{}, err
+	}
+	id := method.Controller
+	document := did.Document{
+		Context:    []interface{}{did.DIDContextV1URI()},
+		ID:         id,
+		Controller: controllers,
+	}
+	if len(controllers) == 0 {
+		document.Controller = []did.DID{id}
+	}
+	document.AddCapabilityInvocation(method)
+	document.AddAssertionMethod(method)
+
+	created := &signer{id: id, keyID: method.ID, keys: map[string]*ecdsa.PrivateKey{method.ID.String(): key}, document: document, created: sigTime}
+	payload, _ := json.Marshal(document)
+	tx, err := d.add(created, method.ID.String(), didDocumentType, payload, sigTime, true)
+	if err != nil {
+		return Transaction{}, err
+	}
+	created.txs = []string{tx.Ref}
+	created.hash = hash(payload)
+	d.signers = append(d.signers, created)
+	d.signersByDID[id.String()] = created
+	return tx, nil
+}
+
+// update changes the DID document of the signer and adds the new version to the DAG, signed with the given key
+func (d *DAG) update(current *signer, keyID string, sigTime time.Time, update func(document *did.Document)) (Transaction, error) {
+	update(&current.document)
+	payload, _ := json.Marshal(current.document)
+	tx, err := d.add(current, keyID, didDocumentType, payload, sigTime, false)
+	if err != nil {
+		return Transaction{}, err
+	}
+	previous := current.hash
+	current.prevHash = &previous
+	current.hash = hash(payload)
+	current.txs = []string{tx.Ref}
+	current.updated = &sigTime
+	return tx, nil
+}
+
+// payload returns a minimal credential or revocation issued by the signer
+func (d *DAG) payload(contentType string, issuer *signer) []byte {
+	id := fmt.Sprintf("%s#%d", issuer.id, d.rand.Int63())
+	var payload map[string]interface{}
+	if contentType == revocationType {
+		payload = map[string]interface{}{"issuer": issuer.id.String(), "subject": id}
+	} else {
+		payload = map[string]interface{}{"id": id, "issuer": issuer.id.String(), "type": []string{"VerifiableCredential", "NutsOrganizationCredential"}}
+	}
+	result, _ := json.Marshal(payload)
+	return result
+}
+
+// add signs a transaction for the payload with the key of the signer and appends it to the DAG.
+// New DIDs embed their public key, other transactions refer to the key.
+func (d *DAG) add(signer *signer, keyID string, contentType string, payload []byte, sigTime time.Time, embedKey bool) (Transaction, error) {
+	key, ok := signer.keys[keyID]
+	if !ok {
+		return Transaction{}, fmt.Errorf("unknown key: %s", keyID)
+	}
+	lc := 0
+	prevs := []string{}
+	if d.last != nil {
+		lc = d.last.LC + 1
+		prevs = append(prevs, d.last.Ref)
+	}
+
+	headers := jws.NewHeaders()
+	crit := []string{"sigt", "ver", "prevs"}
+	if embedKey {
+		publicKey, err := jwk.New(key.Public())
+		if err != nil {
+			return Transaction{}, err
+		}
+		_ = publicKey.Set(jwk.KeyIDKey, keyID)
+		_ = headers.Set(jws.JWKKey, publicKey)
+		crit = append(crit, "jwk")
+	} else {
+		_ = headers.Set(jws.KeyIDKey, keyID)
+		crit = append(crit, "kid")
+	}
+	_ = headers.Set(jws.ContentTypeKey, contentType)
+	_ = headers.Set(jws.CriticalKey, crit)
+	_ = headers.Set("sigt", sigTime.Unix())
+	_ = headers.Set("ver", transactionFormat)
+	_ = headers.Set("prevs", prevs)
+	_ = headers.Set("lc", lc)
+
+	// the payload is detached, the JWS contains its hash
+	signed, err := jws.Sign([]byte(hash(payload)), jwa.ES256, key, jws.WithHeaders(headers))
+	if err != nil {
+		return Transaction{}, err
+	}
+	tx := Transaction{
+		Ref:         hash(signed),
+		JWS:         string(signed),
+		Payload:     payload,
+		LC:          lc,
+		Prevs:       prevs,
+		ContentType: contentType,
+		Signer:      signer.id.String(),
+		SigTime:     sigTime,
+	}
+	d.last = &tx
+	if d.keep {
+		d.transactions = append(d.transactions, tx)
+		d.byRef[tx.Ref] = lc
+	}
+	return tx, nil
+}
+
+// list returns the transactions with an LC value in the range [start, end)
+func (d *DAG) list(start int, end int) []Transaction {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	start = max(start, 0)
+	end = min(end, len(d.transactions))
+	if start >= end {
+		return nil
+	}
+	return append([]Transaction(nil), d.transactions[start:end]...)
+}
+
+// get returns the transaction with the given reference
+func (d *DAG) get(ref string) (Transaction, bool) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	lc, ok := d.byRef[ref]
+	if !ok {
+		return Transaction{}, false
+	}
+	return d.transactions[lc], true
+}
+
+// count returns the number of transactions on the DAG
+func (d *DAG) count() int {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if d.last == nil {
+		return 0
+	}
+	return d.last.LC + 1
+}
+
+// xor returns the XOR of all transaction references in hex form, like the Nuts node reports it
+func (d *DAG) xor() string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	result := make([]byte, sha256.Size)
+	for _, tx := range d.transactions {
+		ref, _ := hex.DecodeString(tx.Ref)
+		for i := range ref {
+			result[i] ^= ref[i]
+		}
+	}
+	return hex.EncodeToString(result)
+}
+
+// resolve returns the DID document of a DID created by the DAG
+func (d *DAG) resolve(id string) (vdr.DIDResolutionResult, bool) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	signer, ok := d.signersByDID[id]
+	if !ok {
+		return vdr.DIDResolutionResult{}, false
+	}
+	document := signer.document
+	// the services change on updates, so the result gets its own copy
+	document.Service = append([]did.Service(nil), document.Service...)
+	result := vdr.DIDResolutionResult{
+		Document: common.DIDDocument(document),
+		DocumentMetadata: common.DIDDocumentMetadata{
+			Created:      signer.created.Format(time.RFC3339),
+			Hash:         signer.hash,
+			PreviousHash: signer.prevHash,
+			Txs:          append([]string(nil), signer.txs...),
+		},
+	}
+	if signer.updated != nil {
+		updated := signer.updated.Format(time.RFC3339)
+		result.DocumentMetadata.Updated = &updated
+	}
+	return result, true
+}
+
+// signerIDs returns the DIDs created by the mock node
+func (d *DAG) signerIDs() []string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	result := make([]string, len(d.signers))
+	for i, s := range d.signers {
+		result[i] = s.id.String()
+	}
+	return result
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
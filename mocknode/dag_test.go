@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package mocknode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/nuts-foundation/go-did/did"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDAG_next(t *testing.T) {
+	dag := newDAG(1, defaultMaxSigners, true)
+	now := time.Now()
+	require.NoError(t, dag.generateHistory(100, time.Hour, now))
+
+	contentTypes := map[string]int{}
+	signersSeen := map[string]bool{}
+	for i, tx := range dag.list(0, 100) {
+		contentTypes[tx.ContentType]++
+		assert.Equal(t, i, tx.LC)
+		if i == 0 {
+			assert.Empty(t, tx.Prevs)
+		} else {
+			assert.Equal(t, []string{dag.transactions[i-1].Ref}, tx.Prevs)
+		}
+		message, err := jws.ParseString(tx.JWS)
+		require.NoError(t, err)
+		headers := message.Signatures()[0].ProtectedHeaders()
+		assert.Equal(t, hash(tx.Payload), string(message.Payload()))
+		// the first transaction of a signer creates its DID document and contains the key
+		if !signersSeen[tx.Signer] {
+			assert.Equal(t, didDocumentType, tx.ContentType)
+			assert.NotNil(t, headers.JWK())
+			signersSeen[tx.Signer] = true
+		} else {
+			assert.Nil(t, headers.JWK())
+			assert.Contains(t, headers.KeyID(), tx.Signer+"#")
+		}
+		// the signature can be verified with the key in the DID document
+		publicKey, err := dag.signersByDID[tx.Signer].document.VerificationMethod[0].PublicKey()
+		require.NoError(t, err)
+		_, err = jws.Verify([]byte(tx.JWS), jwa.ES256, publicKey)
+		assert.NoError(t, err)
+	}
+	assert.Len(t, contentTypes, 3)
+	assert.Equal(t, 100, dag.count())
+	assert.Equal(t, len(signersSeen), len(dag.signerIDs()))
+}
+
+func TestDAG_script(t *testing.T) {
+	dag := NewDAG()
+	now := time.Now()
+	vendor, err := dag.CreateDID(now)
+	require.NoError(t, err)
+	organization, err := dag.CreateDID(now, vendor.Signer)
+	require.NoError(t, err)
+
+	t.Run("controllers", func(t *testing.T) {
+		result, ok := dag.resolve(organization.Signer)
+
+		require.True(t, ok)
+		require.Len(t, result.Document.Controller, 1)
+		assert.Equal(t, vendor.Signer, result.Document.Controller[0].String())
+	})
+	t.Run("update", func(t *testing.T) {
+		tx, err := dag.UpdateDID(organization.Signer, now, func(document *did.Document) {
+			document.Controller = nil
+		})
+
+		require.NoError(t, err)
+		result, _ := dag.resolve(organization.Signer)
+		assert.Empty(t, result.Document.Controller)
+		assert.Equal(t, []string{tx.Ref}, result.DocumentMetadata.Txs)
+		assert.NotNil(t, result.DocumentMetadata.PreviousHash)
+	})
+	t.Run("rotated keys can still sign", func(t *testing.T) {
+		previous := dag.KeyID(vendor.Signer)
+
+		update, err := dag.RotateKey(vendor.Signer, now)
+		require.NoError(t, err)
+		tx, err := dag.Sign(previous, credentialType, []byte("{}"), now)
+
+		require.NoError(t, err)
+		assert.NotEqual(t, previous, dag.KeyID(vendor.Signer))
+		for _, signed := range []Transaction{update, tx} {
+			message, err := jws.ParseString(signed.JWS)
+			require.NoError(t, err)
+			assert.Equal(t, previous, message.Signatures()[0].ProtectedHeaders().KeyID())
+		}
+		result, _ := dag.resolve(vendor.Signer)
+		assert.Equal(t, dag.KeyID(vendor.Signer), result.Document.VerificationMethod[0].ID.String())
+	})
+	t.Run("unknown DID", func(t *testing.T) {
+		_, err := dag.UpdateDID("did:nuts:unknown", now, func(*did.Document) {})
+		assert.EqualError(t, err, "unknown DID: did:nuts:unknown")
+		_, err = dag.Sign("did:nuts:unknown#key", credentialType, []byte("{}"), now)
+		assert.EqualError(t, err, "unknown DID: did:nuts:unknown")
+		assert.Empty(t, dag.KeyID("did:nuts:unknown"))
+	})
+	assert.Len(t, dag.Transactions(), 5)
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package mocknode
+
+import (
+	"time"
+
+	"nuts-foundation/nuts-monitor/client/vdr"
+)
+
+// Generator creates the same signed transactions as the mock node, without keeping them in memory.
+// It's used to generate load, a Generator is safe for concurrent use but a generator per Go routine is faster.
+type Generator struct {
+	dag *DAG
+}
+
+// NewGenerator creates a generator that signs transactions with up to maxDIDs DIDs.
+// Generators with the same seed create the same DIDs and content types, the signatures differ.
+func NewGenerator(seed int64, maxDIDs int) *Generator {
+	return &Generator{dag: newDAG(seed, maxDIDs, false)}
+}
+
+// Next returns a new transaction in compact JWS format and its payload, signed at the given time
+func (g *Generator) Next(sigTime time.Time) (string, []byte, error) {
+	tx, err := g.dag.next(sigTime)
+	if err != nil {
+		return "", nil, err
+	}
+	return tx.JWS, tx.Payload, nil
+}
+
+// Resolve returns the DID document of a DID created by the generator, like the VDR API of the Nuts node
+func (g *Generator) Resolve(id string) (vdr.DIDResolutionResult, bool) {
+	return g.dag.resolve(id)
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package mocknode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nuts-foundation/nuts-monitor/data"
+)
+
+func TestGenerator_Next(t *testing.T) {
+	generator := NewGenerator(1, 10)
+	now := time.Now()
+
+	signers := map[string]bool{}
+	controlled := 0
+	for i := 0; i < 200; i++ {
+		jws, payload, err := generator.Next(now.Add(time.Duration(i) * time.Second))
+		require.NoError(t, err)
+		assert.NotEmpty(t, payload)
+
+		// the transactions can be parsed by the monitor and the signer can be resolved
+		transaction, err := data.FromJWS(jws)
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(time.Duration(i)*time.Second).Unix(), transaction.SigTime.Unix())
+		result, ok := generator.Resolve(transaction.Signer)
+		require.True(t, ok)
+		if !signers[transaction.Signer] && result.Document.Controller[0].String() != transaction.Signer {
+			controlled++
+		}
+		signers[transaction.Signer] = true
+	}
+
+	assert.Len(t, signers, 10)
+	assert.Positive(t, controlled)
+	assert.Equal(t, 200, generator.dag.count())
+	// the transactions aren't kept
+	assert.Empty(t, generator.dag.transactions)
+	_, ok := generator.Resolve("did:nuts:unknown")
+	assert.False(t, ok)
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package mocknode contains an in-process mock Nuts node. It serves the parts of the Nuts node API the monitor uses
+// and publishes generated, signed transactions on an embedded NATS server, so the monitor can be used without a real node.
+package mocknode
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"nuts-foundation/nuts-monitor/config"
+)
+
+// historySize is the number of transactions on the DAG when the mock node starts
+const historySize = 200
+
+// historyPeriod is the period over which the signature times of the initial transactions are spread
+const historyPeriod = 30 * 24 * time.Hour
+
+// softwareVersion is reported by the mock node and its peers
+const softwareVersion = "mock"
+
+// peer is a fake peer of the mock node
+type peer struct {
+	id      string
+	address string
+	nodeDID string
+	// lag is the number of transactions the peer is behind
+	lag int
+}
+
+// newPeers creates the peers of the mock node, one of them is a few transactions behind
+func newPeers(dag *DAG) []peer {
+	signers := dag.signerIDs()
+	peers := make([]peer, 3)
+	for i := range peers {
+		peers[i] = peer{
+			id:      uuid.NewString(),
+			address: fmt.Sprintf("node-%d.example.com:5555", i+1),
+			nodeDID: signers[i%len(signers)],
+		}
+	}
+	peers[len(peers)-1].lag = 3
+	return peers
+}
+
+// Node is an in-process mock Nuts node. It stops when the context passed to Start is cancelled.
+type Node struct {
+	dag      *DAG
+	peers    []peer
+	peerID   string
+	started  time.Time
+	listener net.Listener
+	http     *http.Server
+	nats     *natsserver.Server
+	conn     *nats.Conn
+	storeDir string
+}
+
+// Start starts the HTTP API and NATS server of the mock node and publishes a new transaction every TransactionInterval.
+// Use port 0 in the addresses to listen on a random port.
+func Start(ctx context.Context, cfg config.MockNodeConfig) (*Node, error) {
+	node := &Node{
+		dag:     NewDAG(),
+		peerID:  uuid.NewString(),
+		started: time.Now(),
+	}
+	if err := node.dag.generateHistory(historySize, historyPeriod, node.started); err != nil {
+		return nil, fmt.Errorf("failed to generate transactions: %w", err)
+	}
+	node.peers = newPeers(node.dag)
+
+	if err := node.startNATS(cfg.StreamAddress); err != nil {
+		node.stop()
+		return nil, fmt.Errorf("failed to start NATS server of the mock node: %w", err)
+	}
+	if err := node.startHTTP(cfg.Address); err != nil {
+		node.stop()
+		return nil, fmt.Errorf("failed to start HTTP server of the mock node: %w", err)
+	}
+	log.Printf("Started mock Nuts node on %s (NATS: %s)", node.URL(), node.StreamURL())
+
+	go func() {
+		ticker := time.NewTicker(cfg.TransactionInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				node.stop()
+				return
+			case <-ticker.C:
+				if err := node.publishNext(); err != nil {
+					log.Printf("mock node failed to publish transaction: %s", err)
+				}
+			}
+		}
+	}()
+	return node, nil
+}
+
+// URL returns the address of the HTTP API of the mock node
+func (n *Node) URL() string {
+	return "http://" + connectAddress(n.listener.Addr())
+}
+
+// StreamURL returns the address of the NATS server of the mock node
+func (n *Node) StreamURL() string {
+	return "nats://" + connectAddress(n.nats.Addr())
+}
+
+// connectAddress returns the address to connect to for a listener, listeners on all interfaces are reached through localhost
+func connectAddress(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || !tcpAddr.IP.IsUnspecified() {
+		return addr.String()
+	}
+	return net.JoinHostPort("localhost", strconv.Itoa(tcpAddr.Port))
+}
+
+func (n *Node) startNATS(address string) error {
+	host, port, err := splitAddress(address)
+	if err != nil {
+		return err
+	}
+	// JetStream requires a store directory, even though the monitor only creates memory streams
+	n.storeDir, err = os.MkdirTemp("", "nuts-monitor-mocknode")
+	if err != nil {
+		return err
+	}
+	n.nats, err = natsserver.NewServer(&natsserver.Options{
+		Host:      host,
+		Port:      port,
+		JetStream: true,
+		StoreDir:  n.storeDir,
+		NoSigs:    true,
+		NoLog:     true,
+	})
+	if err != nil {
+		return err
+	}
+	n.nats.Start()
+	if !n.nats.ReadyForConnections(5 * time.Second) {
+		return errors.New("NATS server didn't start in time")
+	}
+	n.conn, err = nats.Connect(n.StreamURL())
+	return err
+}
+
+func (n *Node) startHTTP(address string) error {
+	var err error
+	n.listener, err = net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	n.http = &http.Server{Handler: NewHandler(n.dag, softwareVersion, n.network), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := n.http.Serve(n.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("mock node HTTP server stopped: %s", err)
+		}
+	}()
+	return nil
+}
+
+// network returns the peers of the mock node, they're connected to each other and to the mock node
+func (n *Node) network() (string, []Peer) {
+	count := n.dag.count()
+	result := make([]Peer, len(n.peers))
+	for i, p := range n.peers {
+		peers := []string{n.peerID}
+		for j, other := range n.peers {
+			if i != j {
+				peers = append(peers, other.id)
+			}
+		}
+		result[i] = Peer{
+			ID:               p.id,
+			Address:          p.address,
+			NodeDID:          p.nodeDID,
+			TransactionCount: max(count-p.lag, 0),
+			Peers:            peers,
+			SoftwareVersion:  softwareVersion,
+		}
+	}
+	return n.peerID, result
+}
+
+func (n *Node) stop() {
+	if n.http != nil {
+		_ = n.http.Close()
+	}
+	if n.conn != nil {
+		n.conn.Close()
+	}
+	if n.nats != nil {
+		n.nats.Shutdown()
+		n.nats.WaitForShutdown()
+	}
+	if n.storeDir != "" {
+		_ = os.RemoveAll(n.storeDir)
+	}
+}
+
+// transactionEvent is the message published on NATS for every transaction, like the Nuts node does
+type transactionEvent struct {
+	// Transaction is in compacted JWS format
+	Transaction string `json:"transaction"`
+	// Payload is base64
+	Payload string `json:"payload"`
+}
+
+// publishNext adds a transaction to the DAG and publishes it
+func (n *Node) publishNext() error {
+	tx, err := n.dag.next(time.Now())
+	if err != nil {
+		return err
+	}
+	return Publish(n.conn, tx)
+}
+
+// Publish publishes the transaction and its payload on NATS, like the Nuts node does
+func Publish(conn *nats.Conn, tx Transaction) error {
+	data, _ := json.Marshal(transactionEvent{
+		Transaction: tx.JWS,
+		Payload:     base64.StdEncoding.EncodeToString(tx.Payload),
+	})
+	return conn.Publish("TRANSACTIONS."+tx.Ref, data)
+}
+
+// splitAddress splits a host:port address, port 0 selects a random port
+func splitAddress(address string) (string, int, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port in %s: %w", address, err)
+	}
+	if port == 0 {
+		port = natsserver.RANDOM_PORT
+	}
+	return host, port, nil
+}
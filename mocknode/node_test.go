@@ -0,0 +1,161 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package mocknode
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nuts-foundation/nuts-monitor/client"
+	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/data"
+)
+
+func startNode(t *testing.T, interval time.Duration) (*Node, client.HTTPClient) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	node, err := Start(ctx, config.MockNodeConfig{Address: "127.0.0.1:0", StreamAddress: "127.0.0.1:0", TransactionInterval: interval})
+	require.NoError(t, err)
+	httpClient, err := client.NewHTTPClient(config.Config{NutsNodeAddr: node.URL()})
+	require.NoError(t, err)
+	return node, httpClient
+}
+
+func TestNode_API(t *testing.T) {
+	_, httpClient := startNode(t, time.Hour)
+	ctx := context.Background()
+
+	t.Run("health", func(t *testing.T) {
+		health, err := httpClient.CheckHealth(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, "UP", health.Status)
+	})
+	t.Run("diagnostics", func(t *testing.T) {
+		diagnostics, err := httpClient.Diagnostics(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, historySize, diagnostics.Network.State.TransactionCount)
+		assert.Equal(t, historySize-1, diagnostics.Network.State.DagLcHigh)
+		assert.Len(t, diagnostics.Network.Connections.ConnectedPeers, 3)
+		assert.NotEmpty(t, diagnostics.Network.State.DagXor)
+	})
+	t.Run("transactions", func(t *testing.T) {
+		transactions, err := httpClient.ListTransactions(ctx, 0, 100)
+
+		require.NoError(t, err)
+		require.Len(t, transactions, 100)
+		var previous time.Time
+		for i, jws := range transactions {
+			transaction, err := data.FromJWS(jws)
+			require.NoError(t, err)
+			assert.Equal(t, i, transaction.LC)
+			assert.NotEmpty(t, transaction.Signer)
+			assert.False(t, transaction.SigTime.Before(previous), "signature times must increase with the LC value")
+			previous = transaction.SigTime
+		}
+	})
+	t.Run("transactions past the end", func(t *testing.T) {
+		transactions, err := httpClient.ListTransactions(ctx, historySize, historySize+100)
+
+		require.NoError(t, err)
+		assert.Empty(t, transactions)
+	})
+	t.Run("transaction by reference", func(t *testing.T) {
+		transactions, err := httpClient.ListTransactions(ctx, 5, 6)
+		require.NoError(t, err)
+
+		transaction, err := httpClient.Transaction(ctx, hash([]byte(transactions[0])))
+
+		require.NoError(t, err)
+		assert.Equal(t, transactions[0], transaction)
+	})
+	t.Run("peer diagnostics", func(t *testing.T) {
+		peers, err := httpClient.PeerDiagnostics(ctx)
+
+		require.NoError(t, err)
+		require.Len(t, peers, 3)
+		counts := map[float32]int{}
+		for _, peer := range peers {
+			counts[*peer.TransactionNum]++
+		}
+		assert.Equal(t, map[float32]int{historySize: 2, historySize - 3: 1}, counts)
+	})
+	t.Run("DID documents of the signers", func(t *testing.T) {
+		transactions, err := httpClient.ListTransactions(ctx, 0, historySize)
+		require.NoError(t, err)
+
+		for _, jws := range transactions {
+			transaction, err := data.FromJWS(jws)
+			require.NoError(t, err)
+			result, err := httpClient.DIDDocument(ctx, transaction.Signer)
+			require.NoError(t, err)
+			assert.Equal(t, transaction.Signer, result.Document.ID.String())
+			assert.Len(t, result.Document.VerificationMethod, 1)
+			assert.NotEmpty(t, result.DocumentMetadata.Txs)
+		}
+	})
+	t.Run("unknown DID", func(t *testing.T) {
+		_, err := httpClient.DIDDocument(ctx, "did:nuts:unknown")
+
+		assert.Error(t, err)
+	})
+	t.Run("DAG", func(t *testing.T) {
+		dag, err := httpClient.RenderDAG(ctx, 10, 20)
+
+		require.NoError(t, err)
+		assert.Len(t, dag.Nodes, 10)
+		assert.Len(t, dag.Edges, 9)
+		assert.Equal(t, 1, dag.Branches)
+	})
+}
+
+func TestNode_publish(t *testing.T) {
+	node, _ := startNode(t, 10*time.Millisecond)
+	conn, err := nats.Connect(node.StreamURL())
+	require.NoError(t, err)
+	defer conn.Close()
+	// subscribe like the monitor does, through a JetStream memory stream
+	js, err := conn.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "test", Subjects: []string{"TRANSACTIONS.*"}, Storage: nats.MemoryStorage})
+	require.NoError(t, err)
+	messages := make(chan *nats.Msg, 10)
+	subscription, err := js.ChanSubscribe("TRANSACTIONS.*", messages, nats.DeliverNew())
+	require.NoError(t, err)
+	defer subscription.Unsubscribe()
+
+	select {
+	case msg := <-messages:
+		event := transactionEvent{}
+		require.NoError(t, json.Unmarshal(msg.Data, &event))
+		transaction, err := data.FromJWS(event.Transaction)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, transaction.LC, historySize)
+		assert.Equal(t, "TRANSACTIONS."+hash([]byte(event.Transaction)), msg.Subject)
+		assert.NotEmpty(t, event.Payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no transaction published")
+	}
+}
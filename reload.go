@@ -0,0 +1,85 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"nuts-foundation/nuts-monitor/api"
+	"nuts-foundation/nuts-monitor/client"
+	"nuts-foundation/nuts-monitor/config"
+	"sync"
+)
+
+// nodeReloader applies a reloaded config to the clients and background processes of the nodes
+type nodeReloader struct {
+	ctx   context.Context
+	nodes []api.Node
+
+	mutex sync.Mutex
+	// stopConsumers contains the function that stops the NATS consumer of every node
+	stopConsumers []context.CancelFunc
+}
+
+func (r *nodeReloader) add(node api.Node, stopConsumer context.CancelFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.nodes = append(r.nodes, node)
+	r.stopConsumers = append(r.stopConsumers, stopConsumer)
+}
+
+// apply updates the clients, consistency checkers and NATS consumers of the nodes.
+// The nodes of the config must match the nodes of the reloader, the config watcher makes sure nodes aren't added or removed
+// and their addresses don't change.
+// If the config of any node is invalid, none of the nodes is changed.
+func (r *nodeReloader) apply(cfg config.Config) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(cfg.Nodes) != len(r.nodes) {
+		return fmt.Errorf("expected %d nodes, got %d", len(r.nodes), len(cfg.Nodes))
+	}
+	nodeConfigs := make([]config.Config, len(r.nodes))
+	for i := range r.nodes {
+		nodeConfigs[i] = cfg.ForNode(cfg.Nodes[i])
+		// check the TLS and API security settings before changing anything
+		if _, err := client.CreateHTTPClient(nodeConfigs[i]); err != nil {
+			return fmt.Errorf("node %s: %w", r.nodes[i].Name, err)
+		}
+	}
+
+	for i, node := range r.nodes {
+		c := nodeConfigs[i]
+		previous := node.Client.CurrentConfig()
+		if err := node.Client.Reload(c); err != nil {
+			// not expected after the check above
+			return fmt.Errorf("node %s: %w", node.Name, err)
+		}
+		node.Consistency.Reconfigure(c.ConsistencyInterval, c.ConsistencyThreshold, c.ConsistencyGracePeriod)
+		node.DataStore.Reconfigure(c)
+		if previous.NutsNodeStreamAddr != c.NutsNodeStreamAddr {
+			log.Printf("NATS address of node %s changed, reconnecting to %s", node.Name, c.NutsNodeStreamAddr)
+			r.stopConsumers[i]()
+			r.stopConsumers[i] = startConsumer(r.ctx, node.DataStore, c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"nuts-foundation/nuts-monitor/config"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateReloader provides the server certificate for TLS handshakes.
+// It reloads the certificate when the modification time of the certificate or key file changes,
+// so renewed certificates are picked up without a restart.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+	// modTime is the latest modification time of the certificate and key file when they were last loaded
+	modTime time.Time
+}
+
+// NewCertificateReloader loads the certificate and key, it returns an error if they can't be loaded
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	cr := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := cr.load(modTime); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// GetCertificate can be used as tls.Config.GetCertificate.
+// If reloading fails, for instance because only one of the files has been replaced yet, the previous certificate is used.
+func (cr *CertificateReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	modTime, err := cr.latestModTime()
+	if err == nil && !modTime.Equal(cr.modTime) {
+		if err = cr.load(modTime); err == nil {
+			log.Printf("reloaded TLS certificate from %s", cr.certFile)
+		}
+	}
+	if err != nil {
+		log.Printf("failed to reload TLS certificate, using previous certificate: %s", err)
+	}
+	return cr.certificate, nil
+}
+
+func (cr *CertificateReloader) load(modTime time.Time) error {
+	certificate, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	cr.certificate = &certificate
+	cr.modTime = modTime
+	return nil
+}
+
+func (cr *CertificateReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// TLSConfig creates the TLS configuration of the HTTP server.
+// If a client CA file is configured, clients must authenticate with a certificate issued by one of its CAs.
+func TLSConfig(c config.ServerTLSConfig) (*tls.Config, error) {
+	if len(c.CertKeyFile) == 0 {
+		return nil, errors.New("server.tls.certkeyfile is required with server.tls.certfile")
+	}
+	reloader, err := NewCertificateReloader(c.CertFile, c.CertKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if len(c.ClientCAFile) > 0 {
+		bytes, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bytes) {
+			return nil, fmt.Errorf("no certificates found in client CA file: %s", c.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"nuts-foundation/nuts-monitor/config"
+	"nuts-foundation/nuts-monitor/test"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateReloader(t *testing.T) {
+	pki := test.NewPKI(t)
+	certFile, keyFile := pki.Issue(t, "server")
+
+	reloader, err := NewCertificateReloader(certFile, keyFile)
+	require.NoError(t, err)
+	first, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+
+	t.Run("unchanged", func(t *testing.T) {
+		certificate, err := reloader.GetCertificate(nil)
+
+		require.NoError(t, err)
+		assert.Same(t, first, certificate)
+	})
+	t.Run("reloaded on change", func(t *testing.T) {
+		pki.Issue(t, "server")
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(certFile, later, later))
+
+		certificate, err := reloader.GetCertificate(nil)
+
+		require.NoError(t, err)
+		assert.NotEqual(t, first.Certificate[0], certificate.Certificate[0])
+	})
+	t.Run("invalid file keeps previous certificate", func(t *testing.T) {
+		previous, _ := reloader.GetCertificate(nil)
+		require.NoError(t, os.WriteFile(certFile, []byte("invalid"), 0600))
+		later := time.Now().Add(2 * time.Minute)
+		require.NoError(t, os.Chtimes(certFile, later, later))
+
+		certificate, err := reloader.GetCertificate(nil)
+
+		require.NoError(t, err)
+		assert.Same(t, previous, certificate)
+	})
+	t.Run("missing file", func(t *testing.T) {
+		_, err := NewCertificateReloader("non-existing.pem", keyFile)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestTLSConfig(t *testing.T) {
+	pki := test.NewPKI(t)
+	certFile, keyFile := pki.Issue(t, "server")
+	clientCertFile, clientKeyFile := pki.Issue(t, "client")
+	caBytes, _ := os.ReadFile(pki.CAFile)
+	rootCAs := x509.NewCertPool()
+	rootCAs.AppendCertsFromPEM(caBytes)
+
+	startServer := func(t *testing.T, c config.ServerTLSConfig) string {
+		tlsConfig, err := TLSConfig(c)
+		require.NoError(t, err)
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		// StartTLS would replace the certificate with its own, so wrap the listener instead
+		ts.Listener = tls.NewListener(ts.Listener, tlsConfig)
+		ts.Start()
+		t.Cleanup(ts.Close)
+		return strings.Replace(ts.URL, "http://", "https://", 1)
+	}
+	client := func(certificates ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs, Certificates: certificates}}}
+	}
+
+	t.Run("TLS", func(t *testing.T) {
+		url := startServer(t, config.ServerTLSConfig{CertFile: certFile, CertKeyFile: keyFile})
+
+		resp, err := client().Get(url)
+
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+	t.Run("mTLS", func(t *testing.T) {
+		url := startServer(t, config.ServerTLSConfig{CertFile: certFile, CertKeyFile: keyFile, ClientCAFile: pki.CAFile})
+		clientCertificate, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+		require.NoError(t, err)
+
+		t.Run("with client certificate", func(t *testing.T) {
+			resp, err := client(clientCertificate).Get(url)
+
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+		t.Run("without client certificate", func(t *testing.T) {
+			_, err := client().Get(url)
+
+			assert.Error(t, err)
+		})
+	})
+	t.Run("missing key file", func(t *testing.T) {
+		_, err := TLSConfig(config.ServerTLSConfig{CertFile: certFile})
+
+		assert.EqualError(t, err, "server.tls.certkeyfile is required with server.tls.certfile")
+	})
+	t.Run("invalid client CA file", func(t *testing.T) {
+		_, err := TLSConfig(config.ServerTLSConfig{CertFile: certFile, CertKeyFile: keyFile, ClientCAFile: keyFile})
+
+		assert.ErrorContains(t, err, "no certificates found in client CA file")
+	})
+}
@@ -0,0 +1,401 @@
+/*
+ * Copyright (C) 2023 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	ssi "github.com/nuts-foundation/go-did"
+	"github.com/nuts-foundation/go-did/did"
+	"nuts-foundation/nuts-monitor/mocknode"
+)
+
+// DIDDocumentType is the content type of transactions that create or update a DID document
+const DIDDocumentType = "application/did+json"
+
+// CredentialType is the content type of transactions that issue a credential
+const CredentialType = "application/vc+json"
+
+// RevocationType is the content type of transactions that revoke a credential
+const RevocationType = "application/ld+json;type=revocation"
+
+// Failure replaces or delays the responses of the simulator for a path
+type Failure struct {
+	// Status is the status code of the response, 0 keeps the status of the normal response
+	Status int
+	// Body replaces the body of the response if not empty
+	Body string
+	// Delay is waited before responding. Use a delay longer than the client timeout to simulate a timeout.
+	Delay time.Duration
+	// Times is the number of requests that fail, after that the normal response is returned. 0 means all requests fail.
+	Times int
+}
+
+// ServerError returns a failure that responds with the given 5xx status code
+func ServerError(status int) Failure {
+	return Failure{Status: status, Body: fmt.Sprintf(`{"title":"%s","status":%d}`, http.StatusText(status), status)}
+}
+
+// MalformedJSON returns a failure that responds with a body that isn't valid JSON
+func MalformedJSON() Failure {
+	return Failure{Status: http.StatusOK, Body: `{"malformed":`}
+}
+
+// Timeout returns a failure that doesn't respond before the given delay
+func Timeout(delay time.Duration) Failure {
+	return Failure{Delay: delay}
+}
+
+// SimulatedDID is a DID created by the simulator, it signs transactions with its key
+type SimulatedDID struct {
+	// ID is the DID
+	ID string
+	// KeyID is the ID of the key that signs transactions, the DID with the key thumbprint as fragment
+	KeyID string
+}
+
+// SimulatedPeer is a peer of the simulator, it's reported in the diagnostics of the simulator and in the peer diagnostics
+type SimulatedPeer struct {
+	// ID is the peer ID, generated if empty
+	ID string
+	// Address is the address of the peer
+	Address string
+	// NodeDID is the DID of the peer, the connection is authenticated if set
+	NodeDID string
+	// TransactionCount is the number of transactions on the DAG of the peer
+	TransactionCount int
+	// Peers contains the IDs of the peers of the peer
+	Peers []string
+	// CommonName is the CN of the certificate of the peer, a certificate is issued by the PKI of the simulator if set
+	CommonName string
+	// SoftwareVersion is the version of the Nuts node of the peer
+	SoftwareVersion string
+	certificate     string
+}
+
+// SimulatedTransaction is a transaction on the DAG of the simulator
+type SimulatedTransaction = mocknode.Transaction
+
+// Simulator is a scriptable Nuts node for tests. Tests create DIDs, transactions and peers,
+// and the simulator serves them through the Nuts node API of the mock node.
+// Every transaction is signed and refers to the previous transaction, so the DAG is consistent.
+type Simulator struct {
+	TestNode
+	// PeerID is the peer ID of the simulated node
+	PeerID string
+	// Now returns the signature time of new DID documents, it defaults to time.Now
+	Now func() time.Time
+
+	pki       *PKI
+	dag       *mocknode.DAG
+	mutex     sync.Mutex
+	peers     []SimulatedPeer
+	failures  map[string]*Failure
+	latencies map[string]time.Duration
+	nats      *nats.Conn
+}
+
+// NewSimulator starts a simulated Nuts node without DIDs, transactions or peers. It's stopped when the test ends.
+func NewSimulator(t *testing.T) *Simulator {
+	s := &Simulator{
+		PeerID:    uuid.NewString(),
+		Now:       time.Now,
+		pki:       NewPKI(t),
+		dag:       mocknode.NewDAG(),
+		failures:  map[string]*Failure{},
+		latencies: map[string]time.Duration{},
+	}
+	s.mux = http.NewServeMux()
+	s.mux.Handle("/", mocknode.NewHandler(s.dag, "simulator", s.network))
+	s.server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// CreateDID creates a DID document controlled by the given DIDs, or by itself if none are given.
+// The transaction that creates the document contains the public key of the DID.
+func (s *Simulator) CreateDID(t *testing.T, controllers ...*SimulatedDID) *SimulatedDID {
+	controllerIDs := make([]string, len(controllers))
+	for i, controller := range controllers {
+		controllerIDs[i] = controller.ID
+	}
+	tx, err := s.dag.CreateDID(s.Now(), controllerIDs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish(t, tx)
+	return &SimulatedDID{ID: tx.Signer, KeyID: s.dag.KeyID(tx.Signer)}
+}
+
+// AddService adds a service to the DID document and adds the update of the document to the DAG
+func (s *Simulator) AddService(t *testing.T, subject *SimulatedDID, serviceType string, endpoint interface{}) {
+	tx, err := s.dag.UpdateDID(subject.ID, s.Now(), func(document *did.Document) {
+		serviceID := ssi.MustParseURI(fmt.Sprintf("%s#service-%d", subject.ID, len(document.Service)+1))
+		document.Service = append(document.Service, did.Service{ID: serviceID, Type: serviceType, ServiceEndpoint: endpoint})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish(t, tx)
+}
+
+// SetControllers replaces the controllers of the DID document and adds the update of the document, signed by the DID itself, to the DAG
+func (s *Simulator) SetControllers(t *testing.T, subject *SimulatedDID, controllers ...*SimulatedDID) {
+	tx, err := s.dag.UpdateDID(subject.ID, s.Now(), func(document *did.Document) {
+		document.Controller = nil
+		for _, controller := range controllers {
+			document.Controller = append(document.Controller, did.MustParseDID(controller.ID))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish(t, tx)
+}
+
+// Deactivate removes the keys, services and controllers from the DID document and adds the update of the document,
+// signed by the DID itself, to the DAG
+func (s *Simulator) Deactivate(t *testing.T, subject *SimulatedDID) {
+	tx, err := s.dag.UpdateDID(subject.ID, s.Now(), func(document *did.Document) {
+		document.Controller = nil
+		document.VerificationMethod = nil
+		document.CapabilityInvocation = nil
+		document.AssertionMethod = nil
+		document.Service = nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish(t, tx)
+}
+
+// RotateKey replaces the key of the DID with a new key and adds the update of the document, signed with the old key, to the DAG.
+// It returns a copy of the DID with the old key, to sign transactions with the removed key.
+func (s *Simulator) RotateKey(t *testing.T, subject *SimulatedDID) *SimulatedDID {
+	previous := *subject
+	tx, err := s.dag.RotateKey(subject.ID, s.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish(t, tx)
+	subject.KeyID = s.dag.KeyID(subject.ID)
+	return &previous
+}
+
+// AddTransaction adds a transaction signed by the signer at the given time to the DAG and returns it.
+// The transaction is published on NATS if StartNATS was called.
+func (s *Simulator) AddTransaction(t *testing.T, signer *SimulatedDID, contentType string, sigTime time.Time) SimulatedTransaction {
+	payload, _ := json.Marshal(map[string]interface{}{"id": uuid.NewString(), "issuer": signer.ID})
+	tx, err := s.dag.Sign(signer.KeyID, contentType, payload, sigTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish(t, tx)
+	return tx
+}
+
+// IssueCredential adds a credential of the given type, issued by the issuer at the given time, to the DAG and returns the ID of the credential
+func (s *Simulator) IssueCredential(t *testing.T, issuer *SimulatedDID, credentialType string, sigTime time.Time) string {
+	id := issuer.ID + "#" + uuid.NewString()
+	payload, _ := json.Marshal(map[string]interface{}{"id": id, "issuer": issuer.ID, "type": []string{"VerifiableCredential", credentialType}})
+	tx, err := s.dag.Sign(issuer.KeyID, CredentialType, payload, sigTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish(t, tx)
+	return id
+}
+
+// RevokeCredential adds the revocation of the credential by the issuer at the given time to the DAG
+func (s *Simulator) RevokeCredential(t *testing.T, issuer *SimulatedDID, credentialID string, sigTime time.Time) {
+	payload, _ := json.Marshal(map[string]interface{}{"issuer": issuer.ID, "subject": credentialID})
+	tx, err := s.dag.Sign(issuer.KeyID, RevocationType, payload, sigTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.publish(t, tx)
+}
+
+// publish publishes the transaction on NATS if StartNATS was called
+func (s *Simulator) publish(t *testing.T, tx SimulatedTransaction) {
+	s.mutex.Lock()
+	conn := s.nats
+	s.mutex.Unlock()
+	if conn == nil {
+		return
+	}
+	if err := mocknode.Publish(conn, tx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Transactions returns the transactions on the DAG, ordered by LC value
+func (s *Simulator) Transactions() []SimulatedTransaction {
+	return s.dag.Transactions()
+}
+
+// AddPeer adds a peer that is connected to the simulated node. A certificate is issued for the peer if it has a CommonName.
+func (s *Simulator) AddPeer(t *testing.T, peer SimulatedPeer) SimulatedPeer {
+	if peer.ID == "" {
+		peer.ID = uuid.NewString()
+	}
+	if peer.CommonName != "" {
+		certFile, _ := s.pki.Issue(t, peer.CommonName)
+		certificate, err := os.ReadFile(certFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		peer.certificate = string(certificate)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.peers = append(s.peers, peer)
+	return peer
+}
+
+// network returns the peer ID and peers of the simulated node for the mock node API
+func (s *Simulator) network() (string, []mocknode.Peer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]mocknode.Peer, len(s.peers))
+	for i, peer := range s.peers {
+		result[i] = mocknode.Peer{
+			ID:               peer.ID,
+			Address:          peer.Address,
+			NodeDID:          peer.NodeDID,
+			TransactionCount: peer.TransactionCount,
+			Peers:            append([]string{}, peer.Peers...),
+			SoftwareVersion:  peer.SoftwareVersion,
+			Certificate:      peer.certificate,
+		}
+	}
+	return s.PeerID, result
+}
+
+// Fail makes the requests to paths starting with the prefix fail, an empty prefix matches all requests
+func (s *Simulator) Fail(prefix string, failure Failure) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.failures[prefix] = &failure
+}
+
+// SetLatency delays the responses to paths starting with the prefix, an empty prefix matches all requests
+func (s *Simulator) SetLatency(prefix string, latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.latencies[prefix] = latency
+}
+
+// Reset removes all failures and latencies
+func (s *Simulator) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.failures = map[string]*Failure{}
+	s.latencies = map[string]time.Duration{}
+}
+
+// StartNATS starts an embedded NATS server with JetStream, transactions added after this call are published on it.
+// It returns the address of the server, the server is stopped when the test ends.
+func (s *Simulator) StartNATS(t *testing.T) string {
+	server, err := natsserver.NewServer(&natsserver.Options{
+		Host:      "127.0.0.1",
+		Port:      natsserver.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoSigs:    true,
+		NoLog:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Start()
+	t.Cleanup(server.Shutdown)
+	if !server.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server didn't start")
+	}
+	conn, err := nats.Connect(server.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	s.mutex.Lock()
+	s.nats = conn
+	s.mutex.Unlock()
+	return server.ClientURL()
+}
+
+// serve applies the latencies and failures before handling the request
+func (s *Simulator) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	var latency time.Duration
+	for prefix, value := range s.latencies {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			latency += value
+		}
+	}
+	// the failure with the longest matching prefix applies
+	var failure *Failure
+	failurePrefix := ""
+	for prefix, value := range s.failures {
+		if strings.HasPrefix(r.URL.Path, prefix) && (failure == nil || len(prefix) > len(failurePrefix)) {
+			failure, failurePrefix = value, prefix
+		}
+	}
+	if failure != nil && failure.Times > 0 {
+		failure.Times--
+		if failure.Times == 0 {
+			// this is the last request that fails
+			delete(s.failures, failurePrefix)
+		}
+	}
+	s.mutex.Unlock()
+
+	if failure != nil {
+		latency += failure.Delay
+	}
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if failure == nil || (failure.Status == 0 && failure.Body == "") {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(max(failure.Status, http.StatusOK))
+	_, _ = w.Write([]byte(failure.Body))
+}